@@ -0,0 +1,202 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// checkPackageName returns an error if the name cannot be used
+// as the name of a package directory.
+func checkPackageName(pkgName string) error {
+	if pkgName == "" {
+		return errors.New("package name cannot be empty")
+	}
+	if pkgName == "." || pkgName == ".." ||
+		strings.ContainsAny(pkgName, "/\\") {
+		return errors.New("invalid package name '" + pkgName + "'")
+	}
+	return nil
+}
+
+// starterSources returns the source files that the add command
+// creates for a new package of the specified type. The built-in
+// templates require at least these files to generate the package.
+func starterSources(pkgName, packageType string) []embeddedTemplateFile {
+	mainFunction := []byte("int main()\n{\n\treturn 0;\n}\n")
+
+	if canonicalPackageType(packageType) == "app" {
+		return []embeddedTemplateFile{
+			{"src/main.cc", 0664, mainFunction}}
+	}
+
+	return []embeddedTemplateFile{
+		{"src/" + pkgName + ".cc", 0664, []byte{}},
+		{"tests/test_" + pkgName + ".cc", 0664, mainFunction}}
+}
+
+// addPackage writes a starter definition of a new package into
+// the first directory of the package path along with the source
+// files listed in its 'sources' parameter.
+func addPackage(pkgName, packageType string, requires []string) error {
+	if err := checkPackageName(pkgName); err != nil {
+		return err
+	}
+
+	if getEmbeddedTemplate(packageType) == nil {
+		return errors.New("unknown package type '" + packageType +
+			"' (must be either 'application' or 'library')")
+	}
+
+	wp := &workspaceParams{}
+	if flags.pkgPath == "" {
+		ws, err := loadWorkspace()
+		if err != nil {
+			return err
+		}
+		wp = ws.wp
+	}
+
+	pi, err := readPackageDefinitions(wp)
+	if err != nil {
+		return err
+	}
+
+	if pi.packageByName[pkgName] != nil {
+		return errors.New("package '" + pkgName + "' already exists")
+	}
+
+	for _, dep := range requires {
+		if pi.packageByName[dep] == nil {
+			return errors.New("required package '" + dep +
+				"' could not be found")
+		}
+	}
+
+	pkgpath := wp.PkgPath
+	if flags.pkgPath != "" {
+		if pkgpath, err = getPkgPathFlag(); err != nil {
+			return err
+		}
+	}
+
+	pkgpathDir := strings.Split(pkgpath, ":")[0]
+
+	if fileInfo, err := os.Stat(pkgpathDir); err != nil {
+		return err
+	} else if !fileInfo.IsDir() {
+		return errors.New(pkgpathDir + ": not a directory")
+	}
+
+	pathname := path.Join(pkgpathDir, pkgName, wp.definitionFilename())
+
+	if _, err = os.Stat(pathname); err == nil {
+		return errors.New(pathname + ": file already exists")
+	}
+
+	if requires == nil {
+		requires = []string{}
+	}
+
+	sources := starterSources(pkgName, packageType)
+
+	var sourceList []string
+	for _, source := range sources {
+		sourceList = append(sourceList, source.pathname)
+	}
+
+	out, err := yaml.Marshal(yaml.MapSlice{
+		{Key: "name", Value: pkgName},
+		{Key: "description", Value: "The " + pkgName + " " +
+			packageType},
+		{Key: "type", Value: packageType},
+		{Key: "version", Value: "1.0.0"},
+		{Key: "requires", Value: requires},
+		{Key: "sources", Value: sourceList}})
+	if err != nil {
+		return err
+	}
+
+	writeFile := func(pathname string, mode os.FileMode,
+		contents []byte) error {
+		err := os.MkdirAll(path.Dir(pathname), os.FileMode(0775))
+		if err != nil {
+			return err
+		}
+
+		err = ioutil.WriteFile(pathname, contents, mode)
+		if err != nil {
+			return err
+		}
+
+		if !flags.quiet {
+			fmt.Println("A", pathname)
+		}
+
+		return nil
+	}
+
+	if err = writeFile(pathname, os.FileMode(0664), out); err != nil {
+		return err
+	}
+
+	// Source files that already exist in the package
+	// directory are left intact.
+	for _, source := range sources {
+		sourcePathname := path.Join(path.Dir(pathname),
+			source.pathname)
+
+		if _, err = os.Stat(sourcePathname); err == nil {
+			continue
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+
+		if err = writeFile(sourcePathname, source.mode,
+			source.contents); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addCmd represents the add command
+var addCmd = &cobra.Command{
+	Use:   "add [flags] package_name",
+	Short: "Create a definition file for a new package",
+	Long: wrapText("The 'add' command creates a starter " +
+		"definition of a new package in the first directory " +
+		"of the package search path together with the " +
+		"source files that the built-in templates require. " +
+		"An existing package is never overwritten."),
+	Args: cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		if err := addPackage(args[0], flags.packageType,
+			flags.requires); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(addCmd)
+
+	addCmd.Flags().SortFlags = false
+	addQuietFlag(addCmd)
+	addPkgPathFlag(addCmd)
+	addWorkspaceDirFlag(addCmd)
+	addPackageTypeFlag(addCmd)
+	addRequiresFlag(addCmd)
+}
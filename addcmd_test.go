@@ -0,0 +1,113 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestAddPackage(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "add")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeFileForTesting(t, path.Join(tempDir, "base",
+		packageDefinitionFilename), "name: base\n"+
+		"description: Base library\ntype: library\nversion: 0.1.0\n")
+
+	defer func(origPkgPath string, origQuiet bool) {
+		flags.pkgPath = origPkgPath
+		flags.quiet = origQuiet
+	}(flags.pkgPath, flags.quiet)
+	flags.pkgPath = tempDir
+	flags.quiet = true
+
+	if err = addPackage("hello", "application",
+		[]string{"base"}); err != nil {
+		t.Fatal(err)
+	}
+
+	pi, err := readPackageDefinitions(&workspaceParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pd := pi.packageByName["hello"]
+	if pd == nil {
+		t.Fatal("The new package is not found in the package path")
+	}
+	if pd.packageType != "application" ||
+		pd.params["version"] != "1.0.0" ||
+		packageNames(pd.required) != "base" {
+		t.Error("Unexpected definition of the new package")
+	}
+
+	if sources := fmt.Sprint(pd.params["sources"]); sources !=
+		"[src/main.cc]" {
+		t.Error("Unexpected list of starter sources: " + sources)
+	}
+	if _, err = os.Stat(path.Join(tempDir, "hello",
+		"src", "main.cc")); err != nil {
+		t.Error(err)
+	}
+
+	// The templates of the new package type must find
+	// all the source files that they require.
+	if err = addPackage("greeting", "library", nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, source := range []string{"src/greeting.cc",
+		"tests/test_greeting.cc"} {
+		if _, err = os.Stat(path.Join(tempDir, "greeting",
+			source)); err != nil {
+			t.Error(err)
+		}
+	}
+	if pi, err = readPackageDefinitions(&workspaceParams{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = generateBuildFilesFromEmbeddedTemplate(
+		getEmbeddedTemplate("library"), path.Join(tempDir, "output"),
+		pi.packageByName["greeting"], pi); err != nil {
+		t.Error(err)
+	}
+
+	if defaultType := addCmd.Flags().Lookup("type").DefValue; defaultType !=
+		"application" {
+		t.Error("Unexpected default package type: " + defaultType)
+	}
+
+	for _, testCase := range []struct {
+		pkgName, packageType string
+		requires             []string
+		expectedErr          string
+	}{
+		{"hello", "application", nil, "already exists"},
+		{"base", "library", nil, "already exists"},
+		{"world", "plugin", nil, "unknown package type"},
+		{"world", "library", []string{"none"},
+			"required package 'none' could not be found"},
+		{"", "library", nil, "package name cannot be empty"},
+		{"nested/world", "library", nil, "invalid package name"},
+		{"nested\\world", "library", nil, "invalid package name"},
+		{"..", "library", nil, "invalid package name"},
+	} {
+		err = addPackage(testCase.pkgName, testCase.packageType,
+			testCase.requires)
+		if err == nil || !strings.Contains(err.Error(),
+			testCase.expectedErr) {
+			t.Error("Expected error containing '" +
+				testCase.expectedErr + "' for " +
+				testCase.pkgName)
+		}
+	}
+}
@@ -60,11 +60,11 @@ AC_CHECK_LIB([{{.name}}], [{{.function}}],,
 	[{{.other_libs}}]{{end}}){{end}}
 {{end}}{{if .requires}}
 PKG_PROG_PKG_CONFIG()
-{{range .requires}}
+{{range .requires}}{{with RequiredPackage .}}
 PKG_CHECK_MODULES([{{VarNameUC .}}], [{{VarName .}}])
 CXXFLAGS="$CXXFLAGS ${{VarNameUC .}}_CFLAGS"
 LIBS="$LIBS ${{VarNameUC .}}_LIBS"
-{{end}}{{end -}}
+{{end}}{{end}}{{end -}}
 {{template "Snippet" .}}
 AC_CONFIG_FILES([Makefile
 src/Makefile])
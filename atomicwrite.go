@@ -0,0 +1,53 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// writeTempFile writes the data to the temporary file and flushes
+// it to the disk. Tests replace it to simulate write failures.
+var writeTempFile = func(file *os.File, data []byte) error {
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// writeFileAtomically writes the data to a temporary file in the
+// directory of 'filename' and renames it into place, so that an
+// interrupted write never leaves the file truncated. Unlike
+// ioutil.WriteFile, it sets the permissions of the file even if
+// the file exists.
+func writeFileAtomically(filename string, data []byte,
+	perm os.FileMode) error {
+	tempFile, err := ioutil.TempFile(filepath.Dir(filename),
+		"."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	tempFilename := tempFile.Name()
+
+	err = writeTempFile(tempFile, data)
+	if err == nil {
+		err = tempFile.Chmod(perm)
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFilename, filename)
+	}
+
+	if err != nil {
+		os.Remove(tempFilename)
+	}
+
+	return err
+}
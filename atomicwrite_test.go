@@ -0,0 +1,72 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestAtomicWrite(t *testing.T) {
+	privateDir, err := ioutil.TempDir("", "atomic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(privateDir)
+
+	selectedPathname := path.Join(privateDir, filenameForSelectedPackages)
+
+	writeSelection := func(contents string) error {
+		_, err := writeGeneratedFiles(privateDir, []filenameAndContents{
+			{filenameForSelectedPackages, []byte(contents)}}, 0644)
+		return err
+	}
+
+	checkSelection := func(expected string) {
+		contents, err := ioutil.ReadFile(selectedPathname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(contents) != expected {
+			t.Error("Unexpected contents: " + string(contents))
+		}
+		// Temporary files are hidden, so the directory
+		// is listed directly.
+		entries, err := ioutil.ReadDir(privateDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Error("Temporary file was not removed")
+		}
+	}
+
+	if err = writeSelection("base\nclient\n"); err != nil {
+		t.Fatal(err)
+	}
+	checkSelection("base\nclient\n")
+
+	// Simulate a failure in the middle of writing.
+	defer func(origWriteTempFile func(*os.File, []byte) error) {
+		writeTempFile = origWriteTempFile
+	}(writeTempFile)
+	writeTempFile = func(file *os.File, data []byte) error {
+		if _, err := file.Write(data[:len(data)/2]); err != nil {
+			return err
+		}
+		return errors.New("no space left on device")
+	}
+
+	if err = writeSelection("base\nclient\nserver\n"); err == nil {
+		t.Error("Write failure was not reported")
+	}
+
+	// The original file is intact and the
+	// temporary file has been removed.
+	checkSelection("base\nclient\n")
+}
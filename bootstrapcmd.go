@@ -15,16 +15,36 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func bootstrapPackage(packageDir string, pd *packageDefinition) error {
+// defaultBootstrapCommand is the command that creates the 'configure'
+// script unless the package definition or the workspace overrides it.
+var defaultBootstrapCommand = "./autogen.sh"
+
+// bootstrapCommand returns the shell command that bootstraps the
+// package: the 'bootstrap_command' parameter of the package, or
+// the workspace default, or the autogen.sh script.
+func bootstrapCommand(ws *workspace, pd *packageDefinition) string {
+	if pd.bootstrap != "" {
+		return pd.bootstrap
+	}
+	if ws.wp.BootstrapCommand != "" {
+		return ws.wp.BootstrapCommand
+	}
+	return defaultBootstrapCommand
+}
+
+func bootstrapPackage(ws *workspace, packageDir string,
+	pd *packageDefinition) error {
 	fmt.Println("[bootstrap] " + pd.PackageName)
 
-	bootstrapCmd := exec.Command("./autogen.sh")
+	command := bootstrapCommand(ws, pd)
+
+	bootstrapCmd := exec.Command("/bin/sh", "-c", command)
 	bootstrapCmd.Dir = packageDir
 	bootstrapCmd.Stdout = os.Stdout
 	bootstrapCmd.Stderr = os.Stderr
 	if err := bootstrapCmd.Run(); err != nil {
-		return errors.New(path.Join(packageDir,
-			"autogen.sh") + ": " + err.Error())
+		return errors.New(packageDir + ": " + command + ": " +
+			err.Error())
 	}
 
 	return nil
@@ -55,7 +75,7 @@ func bootstrapPackages(args []string) error {
 	pkgRootDir := ws.generatedPkgRootDir()
 
 	for _, pd := range selection {
-		err = bootstrapPackage(
+		err = bootstrapPackage(ws,
 			path.Join(pkgRootDir, pd.PackageName), pd)
 		if err != nil {
 			return err
@@ -0,0 +1,78 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestBootstrapCommand(t *testing.T) {
+	ws := makeWorkspaceForTesting("/ws")
+
+	pd, _, err := newPackageDefinition("a/"+packageDefinitionFilename,
+		templateParams{"name": "a", "description": "Package a",
+			"type": "library", "version": "1.0.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if command := bootstrapCommand(ws, pd); command != "./autogen.sh" {
+		t.Error("Unexpected default command: " + command)
+	}
+
+	ws.wp.BootstrapCommand = "autoreconf -i"
+	if command := bootstrapCommand(ws, pd); command != "autoreconf -i" {
+		t.Error("Unexpected workspace default: " + command)
+	}
+
+	pd, _, err = newPackageDefinition("b/"+packageDefinitionFilename,
+		templateParams{"name": "b", "description": "Package b",
+			"type": "library", "version": "1.0.0",
+			"bootstrap_command": "./bootstrap > bootstrap.log"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if command := bootstrapCommand(ws, pd); command !=
+		"./bootstrap > bootstrap.log" {
+		t.Error("Unexpected package override: " + command)
+	}
+
+	// The command runs in the package directory.
+	tempDir, err := ioutil.TempDir("", "bootstrap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	writeFileForTesting(t, path.Join(tempDir, "bootstrap"),
+		"#!/bin/sh\necho done\n")
+	if err = os.Chmod(path.Join(tempDir, "bootstrap"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = bootstrapPackage(ws, tempDir, pd); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := ioutil.ReadFile(path.Join(tempDir, "bootstrap.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != "done\n" {
+		t.Error("Unexpected bootstrap output: " + string(output))
+	}
+
+	_, _, err = newPackageDefinition("c/"+packageDefinitionFilename,
+		templateParams{"name": "c", "description": "Package c",
+			"type": "library", "version": "1.0.0",
+			"bootstrap_command": []interface{}{"./bootstrap"}})
+	if err == nil {
+		t.Error("A non-string bootstrap_command must be rejected")
+	}
+}
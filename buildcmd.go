@@ -0,0 +1,256 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var buildStateFilename = "buildstate"
+
+// packageState maps package names to the hashes of their inputs.
+type packageState map[string]string
+
+// hashPackageInputs computes a digest of the package definition file
+// and all source files of the package.
+func hashPackageInputs(pd *packageDefinition) (string, error) {
+	h := sha256.New()
+
+	addFile := func(sourcePathname, relativePathname string) error {
+		file, err := os.Open(sourcePathname)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		io.WriteString(h, relativePathname+"\x00")
+		_, err = io.Copy(h, file)
+		io.WriteString(h, "\x00")
+		return err
+	}
+
+	err := addFile(pd.pathname, filepath.Base(pd.pathname))
+	if err != nil {
+		return "", err
+	}
+
+	err = processPackageSources(pd,
+		func(sourcePathname, relativePathname string,
+			_ os.FileInfo) error {
+			return addFile(sourcePathname, relativePathname)
+		})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashSelectedPackages(selection packageDefinitionList) (packageState,
+	error) {
+	state := packageState{}
+
+	for _, pd := range selection {
+		hash, err := hashPackageInputs(pd)
+		if err != nil {
+			return nil, err
+		}
+		state[pd.PackageName] = hash
+	}
+
+	return state, nil
+}
+
+// readPackageState loads a package state file. Each line
+// of the file contains a hash followed by a package name.
+func readPackageState(pathname string) (packageState, error) {
+	file, err := os.Open(pathname)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	state := packageState{}
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			return nil, errors.New(pathname +
+				": invalid package state format")
+		}
+		state[fields[1]] = fields[0]
+	}
+
+	return state, scanner.Err()
+}
+
+func writePackageState(pathname string, state packageState) error {
+	var pkgNames []string
+	for pkgName := range state {
+		pkgNames = append(pkgNames, pkgName)
+	}
+	sort.Strings(pkgNames)
+
+	var contents string
+	for _, pkgName := range pkgNames {
+		contents += state[pkgName] + " " + pkgName + "\n"
+	}
+
+	return ioutil.WriteFile(pathname, []byte(contents), 0664)
+}
+
+// changedPackages narrows the selection down to the packages whose
+// inputs differ from the previously recorded state, and the selected
+// packages that depend on them.
+func changedPackages(selection packageDefinitionList,
+	previous, current packageState) packageDefinitionList {
+	affected := make(map[*packageDefinition]bool)
+
+	markAffected := func(pd *packageDefinition) {
+		affected[pd] = true
+	}
+
+	for _, pd := range selection {
+		if !affected[pd] && previous[pd.PackageName] !=
+			current[pd.PackageName] {
+			applyToSubtree(markAffected, pd, getDependent)
+		}
+	}
+
+	var narrowed packageDefinitionList
+
+	for _, pd := range selection {
+		if affected[pd] {
+			narrowed = append(narrowed, pd)
+		}
+	}
+
+	return narrowed
+}
+
+func buildPackages(args []string) error {
+	ws, err := loadWorkspace()
+	if err != nil {
+		return err
+	}
+
+	wl, err := ws.lock()
+	if err != nil {
+		return err
+	}
+	defer wl.unlock()
+
+	pi, err := readPackageDefinitions(ws.wp)
+	if err != nil {
+		return err
+	}
+
+	var selection packageDefinitionList
+
+	if len(args) > 0 {
+		selection, err = packageRangesToFlatSelection(pi, args)
+	} else {
+		selection, err = readPackageSelection(pi, ws.absPrivateDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	current, err := hashSelectedPackages(selection)
+	if err != nil {
+		return err
+	}
+
+	buildStatePathname := path.Join(ws.absPrivateDir, buildStateFilename)
+
+	state, err := readPackageState(buildStatePathname)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		// Without a previous state, build everything.
+		state = packageState{}
+	} else if flags.changed {
+		selection = changedPackages(selection, state, current)
+
+		if len(selection) == 0 {
+			if !flags.quiet {
+				fmt.Println("All packages are up to date")
+			}
+			return nil
+		}
+	}
+
+	generator, _, makefile, err := ws.buildFileSettings()
+	if err != nil {
+		return err
+	}
+
+	var buildCmd *exec.Cmd
+	if generator == "ninja" {
+		buildCmd = exec.Command("ninja", "-f", makefile)
+	} else if generator == "cmake" {
+		return errors.New("the CMake superproject in " + makefile +
+			" must be built with cmake")
+	} else {
+		buildCmd = exec.Command("make", "-f", makefile)
+	}
+
+	for _, pd := range selection {
+		buildCmd.Args = append(buildCmd.Args, pd.PackageName)
+	}
+
+	buildCmd.Dir = ws.absDir
+	buildCmd.Env = ws.lockEnv()
+	buildCmd.Stdout = os.Stdout
+	buildCmd.Stderr = os.Stderr
+	if err := buildCmd.Run(); err != nil {
+		return errors.New(buildCmd.Args[0] + ": " + err.Error())
+	}
+
+	for _, pd := range selection {
+		state[pd.PackageName] = current[pd.PackageName]
+	}
+
+	return writePackageState(buildStatePathname, state)
+}
+
+// buildCmd represents the build command
+var buildCmd = &cobra.Command{
+	Use: "build [package_range...]",
+	Short: "Build all selected packages " +
+		"or the specified package range",
+	Run: func(_ *cobra.Command, args []string) {
+		if err := buildPackages(args); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(buildCmd)
+
+	buildCmd.Flags().SortFlags = false
+	addQuietFlag(buildCmd)
+	addWorkspaceDirFlag(buildCmd)
+	addChangedFlag(buildCmd)
+}
@@ -0,0 +1,90 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+// writeFileForTesting creates a file with the specified
+// contents along with all its missing parent directories.
+func writeFileForTesting(t *testing.T, pathname, contents string) {
+	if err := os.MkdirAll(path.Dir(pathname), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(pathname, []byte(contents),
+		0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChangedPackages(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "buildstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	pi, err := makePackageIndexForTesting([]string{
+		"a", "b:a", "c:b", "d"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pd := range pi.orderedPackages {
+		pd.pathname = path.Join(tempDir, pd.PackageName,
+			packageDefinitionFilename)
+		writeFileForTesting(t, pd.pathname, "name: "+pd.PackageName)
+		writeFileForTesting(t, path.Join(tempDir, pd.PackageName,
+			"src", "main.cc"), "int main() {}\n")
+	}
+
+	previous, err := hashSelectedPackages(pi.orderedPackages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stateFile := path.Join(tempDir, buildStateFilename)
+	if err = writePackageState(stateFile, previous); err != nil {
+		t.Fatal(err)
+	}
+	if previous, err = readPackageState(stateFile); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := hashSelectedPackages(pi.orderedPackages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	narrowed := changedPackages(pi.orderedPackages, previous, current)
+	if len(narrowed) != 0 {
+		t.Error("Unchanged packages selected: " +
+			packageNames(narrowed))
+	}
+
+	writeFileForTesting(t, path.Join(tempDir, "b", "src", "main.cc"),
+		"int main() { return 0; }\n")
+
+	if current, err = hashSelectedPackages(
+		pi.orderedPackages); err != nil {
+		t.Fatal(err)
+	}
+
+	narrowed = changedPackages(pi.orderedPackages, previous, current)
+	if names := packageNames(narrowed); names != "b, c" {
+		t.Error("Unexpected narrowed selection: " + names)
+	}
+
+	narrowed = changedPackages(pi.orderedPackages, packageState{},
+		current)
+	if names := packageNames(narrowed); names != "a, b, c, d" {
+		t.Error("Packages without recorded state must be rebuilt: " +
+			names)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// fileChangeStats counts the files changed during a single run by the
+// kind of change. The counters can be updated from multiple goroutines.
+type fileChangeStats struct {
+	mutex    sync.Mutex
+	added    int
+	updated  int
+	replaced int
+	linked   int
+}
+
+// changeStats accumulates the changes made by the current command.
+var changeStats fileChangeStats
+
+// reportFileChange prints the change mode letter ('A' for added,
+// 'U' for updated, 'R' for replaced, 'L' for linked, or 'D' for
+// deleted) followed by the pathname and counts the change. Deleted
+// files are not counted.
+func reportFileChange(mode, pathname string) {
+	fmt.Println(mode, pathname)
+
+	changeStats.mutex.Lock()
+	defer changeStats.mutex.Unlock()
+
+	switch mode {
+	case "A":
+		changeStats.added++
+	case "U":
+		changeStats.updated++
+	case "R":
+		changeStats.replaced++
+	case "L":
+		changeStats.linked++
+	}
+}
+
+// reset zeroes all counters.
+func (stats *fileChangeStats) reset() {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	stats.added, stats.updated, stats.replaced, stats.linked = 0, 0, 0, 0
+}
+
+// summary returns a one-line description of the changes counted.
+func (stats *fileChangeStats) summary() string {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	return "Files added: " + strconv.Itoa(stats.added) +
+		", updated: " + strconv.Itoa(stats.updated) +
+		", replaced: " + strconv.Itoa(stats.replaced) +
+		", linked: " + strconv.Itoa(stats.linked)
+}
+
+// printChangeSummary prints the summary of the changes
+// made by the current command unless --quiet is given.
+func printChangeSummary() {
+	if !flags.quiet {
+		fmt.Println(changeStats.summary())
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"testing"
+)
+
+func TestChangeSummary(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templateDir := path.Join(tempDir, "template")
+	sourceDir := path.Join(tempDir, "src")
+	projectDir := path.Join(tempDir, "project")
+
+	writeFileForTesting(t, path.Join(templateDir, "Makefile.am"),
+		"bin_PROGRAMS = {{.name}}\n")
+	writeFileForTesting(t, path.Join(templateDir, "configure.ac"),
+		"AC_INIT([{{.name}}], [{{.version}}])\n")
+	writeFileForTesting(t, path.Join(sourceDir, "main.c"), "")
+	writeFileForTesting(t, path.Join(sourceDir, "util.c"), "")
+
+	pd := &packageDefinition{
+		PackageName: "a",
+		pathname:    path.Join(sourceDir, packageDefinitionFilename),
+		params:      templateParams{"name": "hello", "version": "1.0"}}
+
+	generate := func(expected string) {
+		changeStats.reset()
+		if _, err := generateBuildFilesFromProjectTemplate(
+			templateDir, projectDir, pd, nil); err != nil {
+			t.Fatal(err)
+		}
+		if summary := changeStats.summary(); summary != expected {
+			t.Error("Unexpected summary: " + summary)
+		}
+	}
+
+	generate("Files added: 2, updated: 0, replaced: 0, linked: 2")
+	generate("Files added: 0, updated: 0, replaced: 0, linked: 0")
+
+	pd.params["version"] = "2.0"
+	makefileAm := path.Join(projectDir, "Makefile.am")
+	if err = os.Remove(makefileAm); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink(path.Join(sourceDir, "main.c"),
+		makefileAm); err != nil {
+		t.Fatal(err)
+	}
+
+	generate("Files added: 0, updated: 1, replaced: 1, linked: 0")
+
+	// Concurrent updates must not be lost.
+	changeStats.reset()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reportFileChange("L", "link")
+		}()
+	}
+	wg.Wait()
+
+	if summary := changeStats.summary(); summary !=
+		"Files added: 0, updated: 0, replaced: 0, linked: 50" {
+		t.Error("Unexpected summary: " + summary)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// cmakeProject describes a selected package as
+// an external project of the CMake superproject.
+type cmakeProject struct {
+	Name          string
+	SourceDir     string
+	BinaryDir     string
+	Configure     string
+	ConfigureArgs []string
+	Depends       []string
+}
+
+// cmakePath returns a CMake expression for the specified absolute
+// pathname. Pathnames inside the workspace are expressed relative
+// to the directory of the superproject.
+func cmakePath(ws *workspace, absPath string) string {
+	relPath := ws.relativeToWorkspace(absPath)
+	if filepath.IsAbs(relPath) {
+		return relPath
+	}
+	if relPath == "." {
+		return "${CMAKE_CURRENT_SOURCE_DIR}"
+	}
+	return "${CMAKE_CURRENT_SOURCE_DIR}/" + relPath
+}
+
+// cmakeProjects returns the external projects for the selected
+// packages. The dependencies between the projects reflect the
+// dependencies between the selected packages.
+func cmakeProjects(ws *workspace, selection packageDefinitionList,
+	pi *packageIndex, conftab *Conftab) []cmakeProject {
+	selectedDeps := establishDependenciesInSelection(selection, pi)
+
+	pkgRootDir := ws.generatedPkgRootDir()
+
+	var projects []cmakeProject
+
+	for _, pd := range selection {
+		packageDir := path.Join(pkgRootDir, pd.PackageName)
+
+		configureArgs := append(conftab.getConfigureArgs(
+			pd.PackageName), "--prefix="+cmakePath(ws,
+			ws.installDir()))
+
+		var depends []string
+		for _, dep := range selectedDeps[pd] {
+			depends = append(depends, dep.PackageName)
+		}
+
+		projects = append(projects, cmakeProject{pd.PackageName,
+			cmakePath(ws, packageDir),
+			cmakePath(ws, pd.pkgBuildDir(ws.buildDir(), pkgRootDir)),
+			cmakePath(ws, pd.configurePathname(packageDir)),
+			configureArgs, depends})
+	}
+
+	return projects
+}
+
+// cmakeQuote turns the argument into a quoted CMake argument.
+// Variable references in the argument are expanded by CMake.
+func cmakeQuote(arg string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) +
+		`"`
+}
+
+var cmakeFuncMap = template.FuncMap{
+	"CMakeQuote": cmakeQuote,
+}
+
+var cmakeTemplate = embeddedTemplateFile{"{makefile}", 0644,
+	[]byte(`cmake_minimum_required(VERSION 3.5)
+
+project(workspace NONE)
+
+include(ExternalProject)
+{{range .projects}}
+ExternalProject_Add({{.Name}}
+	SOURCE_DIR {{CMakeQuote .SourceDir}}
+	BINARY_DIR {{CMakeQuote .BinaryDir}}
+	CONFIGURE_COMMAND {{CMakeQuote .Configure}}
+{{- range .ConfigureArgs}} {{CMakeQuote .}}{{end}}
+	BUILD_COMMAND make
+	INSTALL_COMMAND make install
+{{- if .Depends}}
+	DEPENDS{{range .Depends}} {{.}}{{end}}{{end}})
+{{end}}`)}
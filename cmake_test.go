@@ -0,0 +1,78 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCMakeSuperproject(t *testing.T) {
+	pi, err := makePackageIndexForTesting([]string{
+		"a", "b:a", "c:a", "d:b,c,a"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conftab := newConftab()
+	conftab.section("b", true).setOption(
+		optionKey{optFeat, "debug"}, "--enable-debug")
+
+	ws := makeWorkspaceForTesting("/ws")
+
+	result, err := parseAndExecuteTemplate(cmakeTemplate.pathname,
+		cmakeTemplate.contents, cmakeFuncMap, nil,
+		[]outputFileParams{{"CMakeLists.txt", templateParams{
+			"projects": cmakeProjects(ws, pi.orderedPackages,
+				pi, conftab)}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := string(result[0].contents)
+
+	projectRegexp := regexp.MustCompile(
+		`(?s)ExternalProject_Add\((\w+)\n(.*?)\)\n`)
+
+	depends := make(map[string]string)
+	configure := make(map[string]string)
+
+	for _, match := range projectRegexp.FindAllStringSubmatch(
+		contents, -1) {
+		for _, line := range strings.Split(match[2], "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "DEPENDS ") {
+				depends[match[1]] = line[len("DEPENDS "):]
+			} else if strings.HasPrefix(line, "CONFIGURE_COMMAND ") {
+				configure[match[1]] =
+					line[len("CONFIGURE_COMMAND "):]
+			}
+		}
+	}
+
+	if len(configure) != 4 {
+		t.Fatal("Unexpected number of projects:\n" + contents)
+	}
+
+	// Redundant dependencies are not repeated.
+	for pkgName, expected := range map[string]string{
+		"a": "", "b": "a", "c": "a", "d": "b c"} {
+		if depends[pkgName] != expected {
+			t.Error("Unexpected dependencies of " + pkgName +
+				": " + depends[pkgName])
+		}
+	}
+
+	expected := `"${CMAKE_CURRENT_SOURCE_DIR}/.autoforge/packages/b/` +
+		`configure" "--enable-debug" ` +
+		`"--prefix=${CMAKE_CURRENT_SOURCE_DIR}"`
+	if configure["b"] != expected {
+		t.Error("Unexpected configure command: " + configure["b"])
+	}
+
+	if cmakeQuote(`say "\hi"`) != `"say \"\\hi\""` {
+		t.Error("Unexpected quoting: " + cmakeQuote(`say "\hi"`))
+	}
+}
@@ -54,8 +54,8 @@ func prepareConfigureEnv(buildDir string) *configureEnv {
 	return ce
 }
 
-func (ce *configureEnv) addPackageBuildDir(pkgName string) {
-	ce.pkgBuildDir[pkgName] = path.Join(ce.buildDir, pkgName)
+func (ce *configureEnv) addPackageBuildDir(pkgName, pkgBuildDir string) {
+	ce.pkgBuildDir[pkgName] = pkgBuildDir
 }
 
 func (ce *configureEnv) makeEnv(pd *packageDefinition) []string {
@@ -85,20 +85,57 @@ func (ce *configureEnv) makeEnv(pd *packageDefinition) []string {
 		pkgConfigPathVarName+"="+pkgConfigPath)
 }
 
+// mergeConfigureArgs applies command line overrides in the "KEY=VALUE"
+// format on top of the configure arguments that come from the conftab.
+// An override replaces the argument that configures the same option;
+// overrides for options that are not in the list are appended to it.
+func mergeConfigureArgs(args, overrides []string) ([]string, error) {
+	classifier := createOptClassifier()
+
+	merged := append([]string{}, args...)
+
+	for _, override := range overrides {
+		key, definition, err := parseConftabOption(override)
+		if err != nil {
+			return nil, err
+		}
+
+		replaced := false
+		for i, arg := range merged {
+			argKey, ok := optionKeyOfLine(arg, &classifier)
+			if ok && argKey == key {
+				merged[i] = definition
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, definition)
+		}
+	}
+
+	return merged, nil
+}
+
 func configurePackage(installDir, pkgRootDir string, pd *packageDefinition,
-	cfgEnv *configureEnv, conftab *Conftab) error {
+	cfgEnv *configureEnv, conftab *Conftab, overrides []string) error {
 	fmt.Println("[configure] " + pd.PackageName)
 
-	configurePathname := path.Join(pkgRootDir, pd.PackageName, "configure")
+	configurePathname := pd.configurePathname(
+		path.Join(pkgRootDir, pd.PackageName))
 
-	pkgBuildDir := path.Join(cfgEnv.buildDir, pd.PackageName)
+	pkgBuildDir := pd.pkgBuildDir(cfgEnv.buildDir, pkgRootDir)
 
 	err := os.MkdirAll(pkgBuildDir, os.FileMode(0775))
 	if err != nil {
 		return nil
 	}
 
-	configureArgs := conftab.getConfigureArgs(pd.PackageName)
+	configureArgs, err := mergeConfigureArgs(
+		conftab.getConfigureArgs(pd.PackageName), overrides)
+	if err != nil {
+		return err
+	}
 	configureArgs = append(configureArgs, "--quiet", "--prefix="+installDir)
 
 	configureCmd := exec.Command(configurePathname, configureArgs...)
@@ -119,6 +156,15 @@ func configurePackages(args []string) error {
 		return err
 	}
 
+	// The generated makefile configures independent packages
+	// in parallel, so the lock must not exclude other 'configure'
+	// processes.
+	wl, err := ws.sharedLock()
+	if err != nil {
+		return err
+	}
+	defer wl.unlock()
+
 	pi, err := readPackageDefinitions(ws.wp)
 	if err != nil {
 		return err
@@ -132,7 +178,8 @@ func configurePackages(args []string) error {
 		// Register packages that already exist
 		// in the build directory.
 		for _, dir := range configuredPackageDirs {
-			cfgEnv.addPackageBuildDir(dir.Name())
+			cfgEnv.addPackageBuildDir(dir.Name(),
+				path.Join(buildDir, dir.Name()))
 		}
 	}
 
@@ -147,8 +194,11 @@ func configurePackages(args []string) error {
 		return err
 	}
 
+	pkgRootDir := ws.generatedPkgRootDir()
+
 	for _, pd := range selection {
-		cfgEnv.addPackageBuildDir(pd.PackageName)
+		cfgEnv.addPackageBuildDir(pd.PackageName,
+			pd.pkgBuildDir(buildDir, pkgRootDir))
 	}
 
 	conftab, err := readConftab(
@@ -157,12 +207,27 @@ func configurePackages(args []string) error {
 		return err
 	}
 
+	// Command line overrides apply either to the packages
+	// given with --pkg or, by default, to all packages.
+	overridePkgs := make(map[*packageDefinition]bool)
+	for _, pkgName := range flags.overridePkgs {
+		pd, err := pi.getPackageByName(pkgName)
+		if err != nil {
+			return err
+		}
+		overridePkgs[pd] = true
+	}
+
 	installDir := ws.installDir()
-	pkgRootDir := ws.generatedPkgRootDir()
 
 	for _, pd := range selection {
+		var overrides []string
+		if len(overridePkgs) == 0 || overridePkgs[pd] {
+			overrides = flags.configureOverrides
+		}
+
 		err := configurePackage(installDir, pkgRootDir, pd,
-			cfgEnv, conftab)
+			cfgEnv, conftab, overrides)
 		if err != nil {
 			return err
 		}
@@ -189,4 +254,5 @@ func init() {
 	configureCmd.Flags().SortFlags = false
 	addQuietFlag(configureCmd)
 	addWorkspaceDirFlag(configureCmd)
+	addConfigureOverrideFlags(configureCmd)
 }
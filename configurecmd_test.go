@@ -0,0 +1,111 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestMergeConfigureArgs(t *testing.T) {
+	merged, err := mergeConfigureArgs([]string{
+		"--enable-shared",
+		"--with-zlib=/usr",
+		"--enable-debug"},
+		[]string{"disable-shared", "with-ssl=/opt/ssl",
+			"--without-zlib"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := strings.Join(merged, " ")
+	expected := "--disable-shared --without-zlib --enable-debug " +
+		"--with-ssl=/opt/ssl"
+
+	if result != expected {
+		t.Error("Unexpected merged arguments: \"" + result +
+			"\"; expected: \"" + expected + "\"")
+	}
+
+	if _, err = mergeConfigureArgs(nil, []string{"a b"}); err == nil {
+		t.Error("Invalid override accepted")
+	}
+}
+
+func TestConcurrentConfigure(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	tempDir, err := ioutil.TempDir("", "configure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	pkgDir := path.Join(tempDir, "pkg")
+	for _, pkgName := range []string{"b", "c"} {
+		writeFileForTesting(t, path.Join(pkgDir, pkgName,
+			packageDefinitionFilename), "name: "+pkgName+"\n"+
+			"description: Package "+pkgName+"\n"+
+			"type: library\nversion: 1.0.0\n")
+	}
+
+	origWorkspaceDir, origPkgPath, origQuiet :=
+		flags.workspaceDir, flags.pkgPath, flags.quiet
+	defer func() {
+		flags.workspaceDir = origWorkspaceDir
+		flags.pkgPath = origPkgPath
+		flags.quiet = origQuiet
+	}()
+
+	flags.workspaceDir = path.Join(tempDir, "ws")
+	flags.pkgPath = pkgDir
+	flags.quiet = true
+
+	if err = initWorkspace(); err != nil {
+		t.Fatal(err)
+	}
+
+	ws, err := loadWorkspace()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each configure script waits for the other one to start,
+	// so both must be running at the same time to succeed.
+	markerDir := path.Join(tempDir, "markers")
+	if err = os.MkdirAll(markerDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, pair := range [][2]string{{"b", "c"}, {"c", "b"}} {
+		configurePathname := path.Join(ws.generatedPkgRootDir(),
+			pair[0], "configure")
+		writeFileForTesting(t, configurePathname, "#!/bin/sh\n"+
+			"touch "+path.Join(markerDir, pair[0])+"\n"+
+			"for i in 1 2 3 4 5 6 7 8 9 10; do\n"+
+			"\ttest -f "+path.Join(markerDir, pair[1])+
+			" && exit 0\n\tsleep 1\ndone\nexit 1\n")
+		if err = os.Chmod(configurePathname, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results := make(chan error)
+	for _, pkgName := range []string{"b", "c"} {
+		go func(pkgName string) {
+			results <- configurePackages([]string{pkgName})
+		}(pkgName)
+	}
+	for i := 0; i < 2; i++ {
+		if err = <-results; err != nil {
+			t.Error(err)
+		}
+	}
+}
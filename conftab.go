@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 	"unicode"
 )
@@ -54,6 +55,10 @@ type conftabReader struct {
 	optClassifier optClassifier
 }
 
+// optDefinitionRegexp extracts the option name from
+// an option definition in the "--opt=value" format.
+var optDefinitionRegexp = regexp.MustCompile(`^--([^\s\[=]+)`)
+
 func (reader *conftabReader) Err(message string) error {
 	return fmt.Errorf("%s:%d: %s", reader.filename,
 		reader.lineNumber, message)
@@ -90,7 +95,9 @@ func (reader *conftabReader) readSection(pkgName string) (*ConftabSection,
 
 	for reader.scanner.Scan() {
 		reader.lineNumber++
-		line := strings.TrimSpace(reader.scanner.Text())
+		verbatimLine := strings.TrimRightFunc(reader.scanner.Text(),
+			unicode.IsSpace)
+		line := strings.TrimSpace(verbatimLine)
 
 		if line == "" {
 			section.Definition += "\n"
@@ -106,7 +113,9 @@ func (reader *conftabReader) readSection(pkgName string) (*ConftabSection,
 			return section, strings.TrimSpace(line), nil
 		}
 
-		section.Definition += line + "\n"
+		// Comments and indentation are preserved verbatim
+		// so that they survive rewriting of the conftab file.
+		section.Definition += verbatimLine + "\n"
 
 		var optDefinition string
 
@@ -117,6 +126,7 @@ func (reader *conftabReader) readSection(pkgName string) (*ConftabSection,
 			return nil, "", reader.Err("invalid option format " +
 				"(must start with a dash)")
 		} else {
+			line, _ = splitInlineComment(line)
 			optDefinition = line
 		}
 
@@ -148,8 +158,7 @@ func readConftab(pathname string) (conftab *Conftab, err error) {
 	conftabScanner := bufio.NewScanner(conftabFile)
 
 	reader := conftabReader{pathname, conftabScanner, 0,
-		regexp.MustCompile(`^--([^\s\[=]+)`),
-		createOptClassifier()}
+		optDefinitionRegexp, createOptClassifier()}
 
 	section, nextPkgName, err := reader.readSection("")
 
@@ -217,6 +226,199 @@ func (conftab *Conftab) addOption(pkgName string,
 	return true
 }
 
+// section returns the section for the specified package or
+// the global section if 'pkgName' is empty. If the package has
+// no section, the returned value is nil unless 'create' is true,
+// in which case a new empty section is added to the conftab.
+func (conftab *Conftab) section(pkgName string,
+	create bool) *ConftabSection {
+	if pkgName == "" {
+		return conftab.GlobalSection
+	}
+
+	section := conftab.sectionByPackageName[pkgName]
+	if section == nil && create {
+		section = newSection(pkgName, "\n")
+
+		conftab.PackageSections = append(conftab.PackageSections,
+			section)
+		conftab.sectionByPackageName[pkgName] = section
+	}
+
+	return section
+}
+
+// splitInlineComment separates an option definition from
+// the comment that may follow it on the same line.
+func splitInlineComment(line string) (string, string) {
+	for i := 1; i < len(line); i++ {
+		if line[i] == '#' && (line[i-1] == ' ' || line[i-1] == '\t') {
+			return strings.TrimRightFunc(line[:i], unicode.IsSpace),
+				line[i:]
+		}
+	}
+	return line, ""
+}
+
+// optionKeyOfLine returns the key of the option that the given
+// line of a section definition defines or mentions in a comment.
+func optionKeyOfLine(line string, classifier *optClassifier) (
+	optionKey, bool) {
+	line = strings.TrimSpace(line)
+	line = strings.TrimLeft(line, "#")
+	line = strings.TrimLeftFunc(line, unicode.IsSpace)
+
+	matches := optDefinitionRegexp.FindStringSubmatch(line)
+	if len(matches) < 2 {
+		return optionKey{}, false
+	}
+
+	return classifier.classify(matches[1]), true
+}
+
+// setOption activates the option identified by 'key' with the given
+// definition. The first line of the section definition that mentions
+// the option (whether it is commented out or not) is replaced with the
+// new definition, and other lines mentioning the option are dropped.
+// An inline comment that follows an active definition is retained.
+// If the section does not mention the option, the new definition is
+// inserted at the beginning of the section.
+func (section *ConftabSection) setOption(key optionKey, definition string) {
+	classifier := createOptClassifier()
+
+	var updated string
+	replaced := false
+
+	for _, line := range strings.SplitAfter(section.Definition, "\n") {
+		if lineKey, ok := optionKeyOfLine(line,
+			&classifier); ok && lineKey == key {
+			if replaced {
+				continue
+			}
+			newLine := definition
+			trimmedLine := strings.TrimSpace(line)
+			if trimmedLine[0] != '#' {
+				_, comment := splitInlineComment(trimmedLine)
+				if comment != "" {
+					newLine += " " + comment
+				}
+			}
+			line = newLine + "\n"
+			replaced = true
+		}
+		updated += line
+	}
+
+	if !replaced {
+		updated = definition + "\n\n" + updated
+	}
+
+	section.Definition = updated
+	section.options[key] = definition
+}
+
+// unsetOption removes the active definitions of the option identified
+// by 'key' from the section. Commented out mentions of the option are
+// kept. The function returns false if the option was not active.
+func (section *ConftabSection) unsetOption(key optionKey) bool {
+	if section.options[key] == "" {
+		return false
+	}
+
+	classifier := createOptClassifier()
+
+	var updated string
+	commented := false
+
+	for _, line := range strings.SplitAfter(section.Definition, "\n") {
+		if lineKey, ok := optionKeyOfLine(line,
+			&classifier); ok && lineKey == key {
+			if strings.TrimSpace(line)[0] != '#' {
+				continue
+			}
+			commented = true
+		}
+		updated += line
+	}
+
+	section.Definition = updated
+	if commented {
+		section.options[key] = ""
+	} else {
+		delete(section.options, key)
+	}
+
+	return true
+}
+
+// removeSection deletes the section of the specified package.
+func (conftab *Conftab) removeSection(pkgName string) {
+	for i, section := range conftab.PackageSections {
+		if section.PkgName == pkgName {
+			conftab.PackageSections = append(
+				conftab.PackageSections[:i],
+				conftab.PackageSections[i+1:]...)
+			break
+		}
+	}
+	delete(conftab.sectionByPackageName, pkgName)
+}
+
+// effectiveOption returns the definition of the option identified
+// by 'key' that will be passed to the configure script of the
+// specified package, or of the global section if 'pkgName' is empty.
+func (conftab *Conftab) effectiveOption(pkgName string,
+	key optionKey) (string, bool) {
+	section := conftab.section(pkgName, false)
+	if section == nil {
+		return "", false
+	}
+
+	val, found := section.options[key]
+	if !found {
+		return "", false
+	}
+
+	if val == "" && pkgName != "" {
+		val = conftab.GlobalSection.options[key]
+	}
+
+	return val, val != ""
+}
+
+// globalOptionValue returns the value of the active '--name=value'
+// option in the global section or an empty string if the option is
+// not set. Such options configure the tool itself: they are never
+// passed to configure unless a package section mentions them.
+func (conftab *Conftab) globalOptionValue(name string) string {
+	classifier := createOptClassifier()
+
+	definition := conftab.GlobalSection.options[classifier.classify(name)]
+	if !strings.HasPrefix(definition, "--"+name+"=") {
+		return ""
+	}
+
+	return definition[len(name)+3:]
+}
+
+// sortedKeys returns the keys of the section options in a stable
+// order, so that the result does not depend on map iteration.
+func (section *ConftabSection) sortedKeys() []optionKey {
+	keys := make([]optionKey, 0, len(section.options))
+	for key := range section.options {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].optType != keys[j].optType {
+			return keys[i].optType < keys[j].optType
+		}
+		return keys[i].optName < keys[j].optName
+	})
+
+	return keys
+}
+
 func (conftab *Conftab) getConfigureArgs(pkgName string) []string {
 	var args []string
 
@@ -226,8 +428,8 @@ func (conftab *Conftab) getConfigureArgs(pkgName string) []string {
 		return args
 	}
 
-	for key, val := range section.options {
-		if val != "" {
+	for _, key := range section.sortedKeys() {
+		if val := section.options[key]; val != "" {
 			args = append(args, val)
 		} else if val = conftab.GlobalSection.options[key]; val != "" {
 			args = append(args, val)
@@ -237,6 +439,119 @@ func (conftab *Conftab) getConfigureArgs(pkgName string) []string {
 	return args
 }
 
+// parseSectionDefinition builds a section from its plain text
+// definition, which must not contain section titles.
+func parseSectionDefinition(pkgName, definition string) (*ConftabSection,
+	error) {
+	reader := conftabReader{"[" + pkgName + "]",
+		bufio.NewScanner(strings.NewReader(definition)), 0,
+		optDefinitionRegexp, createOptClassifier()}
+
+	section, _, err := reader.readSection(pkgName)
+	return section, err
+}
+
+// removeDuplicateDefinitions keeps only the last active definition
+// of each option in the section, which is the one that takes effect.
+// The function returns the definitions that have been dropped.
+func (section *ConftabSection) removeDuplicateDefinitions() []string {
+	classifier := createOptClassifier()
+
+	lines := strings.SplitAfter(section.Definition, "\n")
+	seen := make(map[optionKey]bool)
+
+	var kept, dropped []string
+
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := lines[i]
+		trimmedLine := strings.TrimSpace(line)
+		if trimmedLine != "" && trimmedLine[0] != '#' {
+			if key, ok := optionKeyOfLine(line, &classifier); ok {
+				if seen[key] {
+					dropped = append([]string{trimmedLine},
+						dropped...)
+					continue
+				}
+				seen[key] = true
+			}
+		}
+		kept = append([]string{line}, kept...)
+	}
+
+	section.Definition = strings.Join(kept, "")
+
+	return dropped
+}
+
+// normalize converts the conftab to the canonical form, in which
+// every package has at most one section, every option is defined
+// at most once per section, and sections are separated by blank
+// lines. Sections of the same package are merged in the order of
+// their appearance. The function returns the descriptions of the
+// changes made.
+func (conftab *Conftab) normalize() ([]string, error) {
+	var changes []string
+
+	var sections []*ConftabSection
+	sectionByPackageName := make(map[string]*ConftabSection)
+	merged := make(map[string]bool)
+
+	for _, section := range conftab.PackageSections {
+		first := sectionByPackageName[section.PkgName]
+		if first == nil {
+			sections = append(sections, section)
+			sectionByPackageName[section.PkgName] = section
+			continue
+		}
+
+		if !strings.HasSuffix(first.Definition, "\n\n") {
+			first.Definition += "\n"
+		}
+		first.Definition += section.Definition
+
+		if !merged[section.PkgName] {
+			merged[section.PkgName] = true
+			changes = append(changes, "Merged duplicate sections ["+
+				section.PkgName+"]")
+		}
+	}
+
+	allSections := append([]*ConftabSection{conftab.GlobalSection},
+		sections...)
+
+	for i, section := range allSections {
+		// Separate the section from the next one with a blank line.
+		if i < len(allSections)-1 &&
+			!strings.HasSuffix(section.Definition, "\n\n") {
+			section.Definition += "\n"
+		}
+
+		sectionName := "the global section"
+		if section.PkgName != "" {
+			sectionName = "[" + section.PkgName + "]"
+		}
+
+		for _, definition := range section.removeDuplicateDefinitions() {
+			changes = append(changes, "Removed duplicate "+
+				"definition from "+sectionName+": "+definition)
+		}
+
+		if merged[section.PkgName] {
+			parsed, err := parseSectionDefinition(section.PkgName,
+				section.Definition)
+			if err != nil {
+				return nil, err
+			}
+			section.options = parsed.options
+		}
+	}
+
+	conftab.PackageSections = sections
+	conftab.sectionByPackageName = sectionByPackageName
+
+	return changes, nil
+}
+
 type sectionChange struct {
 	deleted, added string
 }
@@ -246,8 +561,8 @@ func (conftab *Conftab) diff(otherConftab *Conftab) (
 	changedSections map[string][]sectionChange,
 	addedSections []string) {
 
-	for pkgName, origSection := range conftab.sectionByPackageName {
-		section := otherConftab.sectionByPackageName[pkgName]
+	for _, origSection := range conftab.PackageSections {
+		section := otherConftab.sectionByPackageName[origSection.PkgName]
 		if section == nil {
 			deletedSections = append(deletedSections,
 				origSection.PkgName)
@@ -256,8 +571,8 @@ func (conftab *Conftab) diff(otherConftab *Conftab) (
 
 	changedSections = make(map[string][]sectionChange)
 
-	for pkgName, section := range otherConftab.sectionByPackageName {
-		origSection := conftab.sectionByPackageName[pkgName]
+	for _, section := range otherConftab.PackageSections {
+		origSection := conftab.sectionByPackageName[section.PkgName]
 
 		if origSection == nil {
 			addedSections = append(addedSections, section.PkgName)
@@ -266,7 +581,8 @@ func (conftab *Conftab) diff(otherConftab *Conftab) (
 
 		changes := changedSections[section.PkgName]
 
-		for key, val := range origSection.options {
+		for _, key := range origSection.sortedKeys() {
+			val := origSection.options[key]
 			if val == "" {
 				val = conftab.GlobalSection.options[key]
 				if val == "" {
@@ -281,7 +597,8 @@ func (conftab *Conftab) diff(otherConftab *Conftab) (
 			}
 		}
 
-		for key, val := range section.options {
+		for _, key := range section.sortedKeys() {
+			val := section.options[key]
 			if val == "" {
 				val = otherConftab.GlobalSection.options[key]
 				// Deletions are discovered
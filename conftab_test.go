@@ -0,0 +1,368 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// makeConftabWorkspaceForTesting creates a temporary workspace
+// directory containing the default conftab and points the
+// --workspacedir flag to it.
+func makeConftabWorkspaceForTesting(t *testing.T) (string, func()) {
+	workspaceDir, err := ioutil.TempDir("", "conftab")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = writeConftab(workspaceDir, newConftab()); err != nil {
+		t.Fatal(err)
+	}
+
+	origWorkspaceDir := flags.workspaceDir
+	flags.workspaceDir = workspaceDir
+
+	return workspaceDir, func() {
+		flags.workspaceDir = origWorkspaceDir
+		os.RemoveAll(workspaceDir)
+	}
+}
+
+func checkConftabOption(t *testing.T, args []string, expected string) {
+	pkgName, option := splitConftabArgs(args)
+
+	key, _, err := parseConftabOption(option)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conftab, _, err := loadConftab()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	definition, found := conftab.effectiveOption(pkgName, key)
+	if expected == "" {
+		if found {
+			t.Error("Unexpected option definition: " + definition)
+		}
+	} else if definition != expected {
+		t.Error("Unexpected option definition: \"" + definition +
+			"\"; expected: \"" + expected + "\"")
+	}
+}
+
+func TestConftabSetAndGet(t *testing.T) {
+	workspaceDir, cleanup := makeConftabWorkspaceForTesting(t)
+	defer cleanup()
+
+	// The default conftab mentions --disable-shared
+	// in the global section but does not enable it.
+	checkConftabOption(t, []string{"disable-shared"}, "")
+
+	if err := setConftabOption([]string{"enable-shared"}); err != nil {
+		t.Fatal(err)
+	}
+	checkConftabOption(t, []string{"disable-shared"}, "--enable-shared")
+
+	if err := setConftabOption([]string{"client",
+		"with-zlib=/opt/zlib"}); err != nil {
+		t.Fatal(err)
+	}
+	checkConftabOption(t, []string{"client", "with-zlib"},
+		"--with-zlib=/opt/zlib")
+	checkConftabOption(t, []string{"base", "with-zlib"}, "")
+	checkConftabOption(t, []string{"with-zlib"}, "")
+
+	// Overwrite the existing option.
+	if err := setConftabOption([]string{"client",
+		"--without-zlib"}); err != nil {
+		t.Fatal(err)
+	}
+	checkConftabOption(t, []string{"client", "with-zlib"},
+		"--without-zlib")
+
+	contents, err := ioutil.ReadFile(path.Join(
+		getPrivateDir(workspaceDir), conftabFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# Global defaults go here.
+--enable-shared
+
+
+[client]
+--without-zlib
+
+
+`
+	if string(contents) != expected {
+		t.Error("Unexpected conftab contents:\n" + string(contents))
+	}
+
+	if err := getConftabOption([]string{"client", "with-x"}); err == nil {
+		t.Error("Getting a missing option must fail")
+	}
+
+	for _, invalid := range []string{"", "a b", "=value", "key value"} {
+		if _, _, err := parseConftabOption(invalid); err == nil {
+			t.Error("Invalid option accepted: " + invalid)
+		}
+	}
+}
+
+func TestConftabUnset(t *testing.T) {
+	workspaceDir, cleanup := makeConftabWorkspaceForTesting(t)
+	defer cleanup()
+
+	for _, args := range [][]string{{"enable-shared"},
+		{"client", "with-zlib"}, {"client", "enable-debug"}} {
+		if err := setConftabOption(args); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	readContents := func() string {
+		contents, err := ioutil.ReadFile(path.Join(
+			getPrivateDir(workspaceDir), conftabFilename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(contents)
+	}
+
+	// Remove one of the keys of the package section.
+	if err := unsetConftabOption([]string{"client",
+		"with-zlib"}); err != nil {
+		t.Fatal(err)
+	}
+	checkConftabOption(t, []string{"client", "with-zlib"}, "")
+	checkConftabOption(t, []string{"client", "enable-debug"},
+		"--enable-debug")
+
+	// Removing the last key removes the section.
+	if err := unsetConftabOption([]string{"client",
+		"--disable-debug"}); err != nil {
+		t.Fatal(err)
+	}
+	if contents := readContents(); strings.Contains(contents,
+		"[client]") {
+		t.Error("Empty section was not removed:\n" + contents)
+	}
+
+	if err := unsetConftabOption([]string{"disable-shared"}); err != nil {
+		t.Fatal(err)
+	}
+	checkConftabOption(t, []string{"enable-shared"}, "")
+
+	expected := readContents()
+	if strings.Contains(expected, "--enable-shared") {
+		t.Error("Global option was not removed:\n" + expected)
+	}
+
+	// Unsetting a nonexistent key is a no-op.
+	for _, args := range [][]string{{"with-x"}, {"client", "with-x"},
+		{"base", "enable-shared"}} {
+		if err := unsetConftabOption(args); err != nil {
+			t.Error(err)
+		}
+	}
+	if contents := readContents(); contents != expected {
+		t.Error("Unexpected conftab contents:\n" + contents)
+	}
+}
+
+func TestConftabCommentPreservation(t *testing.T) {
+	workspaceDir, cleanup := makeConftabWorkspaceForTesting(t)
+	defer cleanup()
+
+	conftabContents := `# Shared libraries are not needed
+# for the statically linked tools.
+--disable-shared
+
+[client]
+  # Indented comment.
+--with-zlib=/opt/zlib  # The system zlib is too old.
+#--enable-debug
+
+# Trailing comment.
+[base]
+--enable-debug	# Needed to reproduce issue 12.
+`
+	conftabPathname := path.Join(getPrivateDir(workspaceDir),
+		conftabFilename)
+
+	if err := ioutil.WriteFile(conftabPathname,
+		[]byte(conftabContents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conftab, _, err := loadConftab()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := conftab.getConfigureArgs("client")
+	if len(args) != 1 || args[0] != "--with-zlib=/opt/zlib" {
+		t.Error("Inline comment is passed to configure")
+	}
+
+	if err = writeConftab(workspaceDir, conftab); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(conftabPathname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != conftabContents {
+		t.Error("Comments are not preserved:\n" + string(contents))
+	}
+
+	if err = setConftabOption([]string{"base",
+		"disable-debug"}); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err = ioutil.ReadFile(conftabPathname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(contents),
+		"[base]\n--disable-debug # Needed to reproduce issue 12.\n") {
+		t.Error("Inline comment is lost:\n" + string(contents))
+	}
+}
+
+func TestConfigureArgsOrder(t *testing.T) {
+	workspaceDir, cleanup := makeConftabWorkspaceForTesting(t)
+	defer cleanup()
+
+	if err := ioutil.WriteFile(path.Join(getPrivateDir(workspaceDir),
+		conftabFilename), []byte(`--disable-shared
+
+[a]
+--with-zlib
+--prefix=/opt/a
+--enable-debug
+--with-bzip2
+--enable-asserts
+# --enable-shared
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conftab, _, err := loadConftab()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "--enable-asserts --enable-debug --disable-shared " +
+		"--with-bzip2 --with-zlib --prefix=/opt/a"
+
+	for i := 0; i < 10; i++ {
+		args := strings.Join(conftab.getConfigureArgs("a"), " ")
+		if args != expected {
+			t.Fatal("Unexpected configure arguments: " + args)
+		}
+	}
+}
+
+func TestConftabMigrate(t *testing.T) {
+	workspaceDir, cleanup := makeConftabWorkspaceForTesting(t)
+	defer cleanup()
+
+	conftabPathname := path.Join(getPrivateDir(workspaceDir),
+		conftabFilename)
+
+	writeFileForTesting(t, conftabPathname, `# Global defaults
+--disable-shared   
+--disable-shared
+
+[ a ]
+--with-zlib=/opt/zlib
+--enable-debug # for now
+--with-zlib=/usr
+
+[b]
+--enable-static
+
+[a]
+# Added by hand later
+--disable-nls
+--enable-debug
+`)
+
+	if err := migrateConftab(); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(conftabPathname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(contents) != `# Global defaults
+--disable-shared
+
+[a]
+--with-zlib=/usr
+
+# Added by hand later
+--disable-nls
+--enable-debug
+
+[b]
+--enable-static
+
+` {
+		t.Error("Unexpected migration result:\n" + string(contents))
+	}
+
+	checkConftabOption(t, []string{"a", "with-zlib"}, "--with-zlib=/usr")
+	checkConftabOption(t, []string{"a", "disable-nls"}, "--disable-nls")
+	checkConftabOption(t, []string{"b", "enable-static"},
+		"--enable-static")
+
+	// Migrating an up-to-date file changes nothing.
+	info, err := os.Stat(conftabPathname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conftab, _, err := loadConftab()
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := conftab.normalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Error("Unexpected changes: " + strings.Join(changes, "; "))
+	}
+
+	if err = migrateConftab(); err != nil {
+		t.Fatal(err)
+	}
+
+	secondContents, err := ioutil.ReadFile(conftabPathname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(secondContents) != string(contents) {
+		t.Error("Second migration changed the file")
+	}
+	if newInfo, err := os.Stat(conftabPathname); err != nil {
+		t.Fatal(err)
+	} else if !newInfo.ModTime().Equal(info.ModTime()) {
+		t.Error("Second migration rewrote the file")
+	}
+}
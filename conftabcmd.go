@@ -11,6 +11,7 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -71,8 +72,12 @@ func editConftab() error {
 		fmt.Println("New section: [" + pkgName + "]")
 	}
 
-	for pkgName, changes := range changedSections {
-		fmt.Println("Changes in [" + pkgName + "]:")
+	for _, section := range updatedConftab.PackageSections {
+		changes := changedSections[section.PkgName]
+		if len(changes) == 0 {
+			continue
+		}
+		fmt.Println("Changes in [" + section.PkgName + "]:")
 		for _, chg := range changes {
 			if chg.added != "" {
 				if chg.deleted != "" {
@@ -91,6 +96,145 @@ func editConftab() error {
 	return nil
 }
 
+func loadConftab() (*Conftab, string, error) {
+	workspaceDir, err := getWorkspaceDir()
+	if err != nil {
+		return nil, "", err
+	}
+
+	conftab, err := readConftab(path.Join(getPrivateDir(workspaceDir),
+		conftabFilename))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return conftab, workspaceDir, nil
+}
+
+// parseConftabOption converts a command line argument in the
+// "KEY=VALUE" or "KEY" format into a conftab option definition.
+func parseConftabOption(arg string) (optionKey, string, error) {
+	definition := "--" + strings.TrimPrefix(arg, "--")
+
+	matches := optDefinitionRegexp.FindStringSubmatch(definition)
+	if len(matches) < 2 || strings.ContainsAny(definition, "\n\r") ||
+		(len(matches[0]) < len(definition) &&
+			definition[len(matches[0])] != '=') {
+		return optionKey{}, "", errors.New(
+			"invalid option format: " + arg)
+	}
+
+	classifier := createOptClassifier()
+
+	return classifier.classify(matches[1]), definition, nil
+}
+
+// splitConftabArgs separates the optional package name
+// from the last command line argument.
+func splitConftabArgs(args []string) (string, string) {
+	if len(args) > 1 {
+		return args[0], args[1]
+	}
+	return "", args[0]
+}
+
+func setConftabOption(args []string) error {
+	pkgName, option := splitConftabArgs(args)
+
+	key, definition, err := parseConftabOption(option)
+	if err != nil {
+		return err
+	}
+
+	conftab, workspaceDir, err := loadConftab()
+	if err != nil {
+		return err
+	}
+
+	conftab.section(pkgName, true).setOption(key, definition)
+
+	return writeConftab(workspaceDir, conftab)
+}
+
+func unsetConftabOption(args []string) error {
+	pkgName, option := splitConftabArgs(args)
+
+	key, _, err := parseConftabOption(option)
+	if err != nil {
+		return err
+	}
+
+	conftab, workspaceDir, err := loadConftab()
+	if err != nil {
+		return err
+	}
+
+	section := conftab.section(pkgName, false)
+	if section == nil || !section.unsetOption(key) {
+		return nil
+	}
+
+	// Drop the package section if nothing but
+	// blank lines is left in it.
+	if pkgName != "" && strings.TrimSpace(section.Definition) == "" {
+		conftab.removeSection(pkgName)
+	}
+
+	return writeConftab(workspaceDir, conftab)
+}
+
+func getConftabOption(args []string) error {
+	pkgName, option := splitConftabArgs(args)
+
+	key, _, err := parseConftabOption(option)
+	if err != nil {
+		return err
+	}
+
+	conftab, _, err := loadConftab()
+	if err != nil {
+		return err
+	}
+
+	definition, found := conftab.effectiveOption(pkgName, key)
+	if !found {
+		return errors.New("option '" + option + "' is not set")
+	}
+
+	fmt.Println(definition)
+
+	return nil
+}
+
+// migrateConftab rewrites the conftab file in the canonical form
+// and prints the changes made.
+func migrateConftab() error {
+	conftab, workspaceDir, err := loadConftab()
+	if err != nil {
+		return err
+	}
+
+	changes, err := conftab.normalize()
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+
+	changed, err := updateConftab(workspaceDir, conftab)
+	if err != nil {
+		return err
+	}
+
+	if !changed {
+		fmt.Println("The conftab file is up to date")
+	}
+
+	return nil
+}
+
 var conftabCmdName = "conftab"
 
 var conftabCmd = &cobra.Command{
@@ -104,9 +248,84 @@ var conftabCmd = &cobra.Command{
 	},
 }
 
+var conftabSetCmd = &cobra.Command{
+	Use:   "set [package] KEY[=VALUE]",
+	Short: "Set a configure option in the conftab file",
+	Long: wrapText("The 'set' command activates the '--KEY=VALUE' " +
+		"configure option in the section of the specified package " +
+		"or, if the package is omitted, in the global section of " +
+		"the conftab file. An existing definition of the option " +
+		"is overwritten."),
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(_ *cobra.Command, args []string) {
+		if err := setConftabOption(args); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var conftabUnsetCmd = &cobra.Command{
+	Use:   "unset [package] KEY",
+	Short: "Remove a configure option from the conftab file",
+	Long: wrapText("The 'unset' command removes the definition of " +
+		"the configure option from the section of the specified " +
+		"package or, if the package is omitted, from the global " +
+		"section of the conftab file. A package section that " +
+		"becomes empty is removed. Unsetting an option that is " +
+		"not set is not an error."),
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(_ *cobra.Command, args []string) {
+		if err := unsetConftabOption(args); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var conftabGetCmd = &cobra.Command{
+	Use:   "get [package] KEY",
+	Short: "Print a configure option from the conftab file",
+	Long: wrapText("The 'get' command prints the definition of the " +
+		"configure option that will be passed to the configure " +
+		"script of the specified package or, if the package is " +
+		"omitted, the definition from the global section. The " +
+		"command fails if the option is not set."),
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(_ *cobra.Command, args []string) {
+		if err := getConftabOption(args); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var conftabMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Rewrite the conftab file in the current format",
+	Long: wrapText("The 'migrate' command rewrites the conftab " +
+		"file in the canonical form: sections of the same package " +
+		"are merged, only the effective definition of each option " +
+		"is kept, and section titles and trailing whitespace are " +
+		"normalized. Comments are preserved. Running the command " +
+		"on an up-to-date file changes nothing."),
+	Args: cobra.MaximumNArgs(0),
+	Run: func(_ *cobra.Command, _ []string) {
+		if err := migrateConftab(); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(conftabCmd)
 
 	conftabCmd.Flags().SortFlags = false
 	addWorkspaceDirFlag(conftabCmd)
+
+	for _, subcommand := range []*cobra.Command{
+		conftabSetCmd, conftabUnsetCmd, conftabGetCmd,
+		conftabMigrateCmd} {
+		conftabCmd.AddCommand(subcommand)
+
+		subcommand.Flags().SortFlags = false
+		addWorkspaceDirFlag(subcommand)
+	}
 }
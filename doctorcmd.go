@@ -0,0 +1,242 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// workspaceProblem describes an inconsistency found in
+// the workspace along with a suggestion on how to fix it.
+type workspaceProblem struct {
+	description string
+	suggestion  string
+}
+
+// diagnoseWorkspace checks the integrity of the workspace and
+// returns the list of problems found. An error is returned only
+// if the checks themselves cannot be performed.
+func diagnoseWorkspace(ws *workspace) ([]workspaceProblem, error) {
+	pi, err := readPackageDefinitions(ws.wp)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []workspaceProblem
+
+	report := func(description, suggestion string) {
+		problems = append(problems,
+			workspaceProblem{description, suggestion})
+	}
+
+	selection, err := checkSelectedFile(ws, pi, report)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkPackageLinks(ws, report); err != nil {
+		return nil, err
+	}
+
+	checkConftabSections(ws, selection, report)
+
+	if err = checkMakefileAge(ws, selection, report); err != nil {
+		return nil, err
+	}
+
+	return problems, nil
+}
+
+// checkSelectedFile reports the packages in the 'selected' file that
+// cannot be found in the package path and returns the known ones.
+func checkSelectedFile(ws *workspace, pi *packageIndex,
+	report func(string, string)) (packageDefinitionList, error) {
+	file, err := os.Open(path.Join(ws.absPrivateDir,
+		filenameForSelectedPackages))
+	if err != nil {
+		if os.IsNotExist(err) {
+			report("no package selection found",
+				"run '"+appName+" select' to select packages")
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var selection packageDefinitionList
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		pkgName := scanner.Text()
+
+		if pd := pi.packageByName[pkgName]; pd != nil {
+			selection = append(selection, pd)
+		} else {
+			report("selected package '"+pkgName+
+				"' could not be found",
+				"check the package path or run '"+appName+
+					" select' with an updated list of packages")
+		}
+	}
+
+	return selection, scanner.Err()
+}
+
+// checkPackageLinks reports symbolic links in the directory with
+// generated package sources that point to nonexistent files.
+func checkPackageLinks(ws *workspace, report func(string, string)) error {
+	pkgRootDir := ws.generatedPkgRootDir()
+
+	if _, err := os.Stat(pkgRootDir); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.Walk(pkgRootDir, func(pathname string,
+		info os.FileInfo, err error) error {
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			return err
+		}
+
+		if _, err = os.Stat(pathname); err != nil {
+			target, _ := os.Readlink(pathname)
+			report(ws.relativeToWorkspace(pathname)+
+				": broken link to "+target,
+				"run '"+appName+" refresh' to relink the "+
+					"package sources")
+		}
+
+		return nil
+	})
+}
+
+// checkConftabSections reports conftab syntax errors and
+// conftab sections of the packages that are not selected.
+func checkConftabSections(ws *workspace, selection packageDefinitionList,
+	report func(string, string)) {
+	conftabPathname := path.Join(ws.absPrivateDir, conftabFilename)
+
+	conftab, err := readConftab(conftabPathname)
+	if err != nil {
+		if os.IsNotExist(err) {
+			report("conftab is missing", "run '"+appName+
+				" refresh' to recreate it")
+		} else {
+			report(err.Error(), "correct the syntax in "+
+				ws.relativeToWorkspace(conftabPathname))
+		}
+		return
+	}
+
+	selected := make(map[string]bool)
+	for _, pd := range selection {
+		selected[pd.PackageName] = true
+	}
+
+	for _, section := range conftab.PackageSections {
+		if !selected[section.PkgName] {
+			report("conftab contains a section for package '"+
+				section.PkgName+"', which is not selected",
+				"remove the section or select the package")
+		}
+	}
+}
+
+// checkMakefileAge reports a missing top-level build file or one
+// that is older than the definitions of the selected packages.
+func checkMakefileAge(ws *workspace, selection packageDefinitionList,
+	report func(string, string)) error {
+	_, _, makefile, err := ws.buildFileSettings()
+	if err != nil {
+		return err
+	}
+
+	suggestion := "run '" + appName + " refresh' to regenerate it"
+
+	makefileInfo, err := os.Stat(path.Join(ws.absDir, makefile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			report(makefile+" does not exist", suggestion)
+			return nil
+		}
+		return err
+	}
+
+	for _, pd := range selection {
+		pdInfo, err := os.Stat(pd.pathname)
+		if err != nil {
+			return err
+		}
+		if pdInfo.ModTime().After(makefileInfo.ModTime()) {
+			report(makefile+" is older than "+pd.pathname,
+				suggestion)
+		}
+	}
+
+	return nil
+}
+
+func runDoctor() error {
+	ws, err := loadWorkspace()
+	if err != nil {
+		return err
+	}
+
+	problems, err := diagnoseWorkspace(ws)
+	if err != nil {
+		return err
+	}
+
+	for _, problem := range problems {
+		fmt.Println(problem.description)
+		fmt.Println("    Suggested fix:", problem.suggestion)
+	}
+
+	if len(problems) > 0 {
+		return errors.New("problems found: " +
+			strconv.Itoa(len(problems)))
+	}
+
+	if !flags.quiet {
+		fmt.Println("No problems found.")
+	}
+
+	return nil
+}
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the workspace for inconsistencies",
+	Long: wrapText("The 'doctor' command verifies that the " +
+		"package selection, the generated package sources, " +
+		"the conftab, and the top-level Makefile of the " +
+		"workspace are consistent with each other. Each " +
+		"problem is reported with a suggested fix."),
+	Args: cobra.MaximumNArgs(0),
+	Run: func(_ *cobra.Command, _ []string) {
+		if err := runDoctor(); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().SortFlags = false
+	addQuietFlag(doctorCmd)
+	addWorkspaceDirFlag(doctorCmd)
+	addPkgPathFlag(doctorCmd)
+}
@@ -0,0 +1,138 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+// makeHealthyWorkspaceForTesting creates a workspace with two packages
+// in the package path, one of which is selected and generated.
+func makeHealthyWorkspaceForTesting(t *testing.T, tempDir string) *workspace {
+	pkgDir := path.Join(tempDir, "pkg")
+
+	for _, name := range []string{"a", "b"} {
+		writeFileForTesting(t, path.Join(pkgDir, name,
+			packageDefinitionFilename), "name: "+name+
+			"\ndescription: Package "+name+
+			"\ntype: library\nversion: 1.0.0\n")
+	}
+	writeFileForTesting(t, path.Join(pkgDir, "a", "a.c"), "")
+
+	ws := makeWorkspaceForTesting(path.Join(tempDir, "ws"))
+	ws.wp.PkgPath = pkgDir
+
+	writeFileForTesting(t, path.Join(ws.absPrivateDir,
+		filenameForSelectedPackages), "a\n")
+	writeFileForTesting(t, path.Join(ws.absPrivateDir, conftabFilename),
+		"--disable-shared\n\n[a]\n--enable-debug\n")
+
+	linkDir := path.Join(ws.generatedPkgRootDir(), "a")
+	if err := os.MkdirAll(linkDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(path.Join(pkgDir, "a", "a.c"),
+		path.Join(linkDir, "a.c")); err != nil {
+		t.Fatal(err)
+	}
+
+	makefile := path.Join(ws.absDir, "Makefile")
+	writeFileForTesting(t, makefile, "all:\n")
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(makefile, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	return ws
+}
+
+func diagnoseWorkspaceForTesting(t *testing.T, ws *workspace) string {
+	problems, err := diagnoseWorkspace(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var descriptions []string
+	for _, problem := range problems {
+		if problem.suggestion == "" {
+			t.Error("No suggestion for " + problem.description)
+		}
+		descriptions = append(descriptions, problem.description)
+	}
+
+	return strings.Join(descriptions, "\n")
+}
+
+func TestDoctor(t *testing.T) {
+	for _, testCase := range []struct {
+		name     string
+		breakWs  func(t *testing.T, ws *workspace)
+		expected string
+	}{
+		{"healthy", func(*testing.T, *workspace) {}, ""},
+		{"unknown selected package", func(t *testing.T, ws *workspace) {
+			writeFileForTesting(t, path.Join(ws.absPrivateDir,
+				filenameForSelectedPackages), "a\nz\n")
+		}, "selected package 'z' could not be found"},
+		{"broken link", func(t *testing.T, ws *workspace) {
+			if err := os.Remove(path.Join(ws.wp.PkgPath,
+				"a", "a.c")); err != nil {
+				t.Fatal(err)
+			}
+		}, ".autoforge/packages/a/a.c: broken link to "},
+		{"conftab syntax", func(t *testing.T, ws *workspace) {
+			writeFileForTesting(t, path.Join(ws.absPrivateDir,
+				conftabFilename), "[a\n")
+		}, "invalid section title format"},
+		{"unselected conftab section", func(t *testing.T,
+			ws *workspace) {
+			writeFileForTesting(t, path.Join(ws.absPrivateDir,
+				conftabFilename), "[a]\n[b]\n")
+		}, "conftab contains a section for package 'b', " +
+			"which is not selected"},
+		{"stale makefile", func(t *testing.T, ws *workspace) {
+			past := time.Now().Add(-time.Hour)
+			if err := os.Chtimes(path.Join(ws.absDir, "Makefile"),
+				past, past); err != nil {
+				t.Fatal(err)
+			}
+		}, "Makefile is older than "},
+		{"missing makefile", func(t *testing.T, ws *workspace) {
+			if err := os.Remove(path.Join(ws.absDir,
+				"Makefile")); err != nil {
+				t.Fatal(err)
+			}
+		}, "Makefile does not exist"},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			tempDir, err := ioutil.TempDir("", "doctor")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tempDir)
+
+			ws := makeHealthyWorkspaceForTesting(t, tempDir)
+			testCase.breakWs(t, ws)
+
+			problems := diagnoseWorkspaceForTesting(t, ws)
+
+			if testCase.expected == "" {
+				if problems != "" {
+					t.Error("Unexpected problems: " +
+						problems)
+				}
+			} else if strings.Count(problems, "\n") != 0 ||
+				!strings.Contains(problems, testCase.expected) {
+				t.Error("Expected a single problem '" +
+					testCase.expected + "', got: " + problems)
+			}
+		})
+	}
+}
@@ -6,14 +6,27 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"text/template"
+	"unicode"
+
+	"gopkg.in/yaml.v2"
 )
 
 type templateParams map[string]interface{}
@@ -40,52 +53,611 @@ func filterPathnames(pathnames, patterns []string, invert bool) []string {
 	return filtered
 }
 
-var commonFuncMap = template.FuncMap{
-	"VarName": func(arg string) string {
-		return strings.Map(func(r rune) rune {
-			if r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' ||
-				r >= '0' && r <= '9' {
-				return r
-			} else if r == '+' {
-				return 'x'
+// packageColor maps the name of a package to a pastel color in the
+// "#rrggbb" notation. The same name always produces the same color.
+func packageColor(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	sum := h.Sum32()
+
+	// Keep each channel in the upper half of the range
+	// so that the resulting color is always light.
+	return fmt.Sprintf("#%02x%02x%02x",
+		0x80|byte(sum>>16)>>1, 0x80|byte(sum>>8)>>1, 0x80|byte(sum)>>1)
+}
+
+// prereqList rebases each of the pathnames against 'base' and returns
+// them as a space-separated list of make prerequisites. Spaces and
+// dollar signs in the pathnames are escaped.
+func prereqList(base string, pathnames []string) string {
+	escaper := strings.NewReplacer(" ", "\\ ", "$", "$$")
+
+	var prereqs []string
+
+	for _, pathname := range pathnames {
+		if relPath, err := filepath.Rel(base, pathname); err == nil {
+			pathname = relPath
+		}
+		prereqs = append(prereqs, escaper.Replace(pathname))
+	}
+
+	return strings.Join(prereqs, " ")
+}
+
+// splitString splits s into the substrings separated by sep. If
+// sep consists of whitespace only, s is split around runs of
+// whitespace characters and no empty fields are returned.
+func splitString(sep, s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	if sep != "" && strings.TrimSpace(sep) == "" {
+		return strings.Fields(s)
+	}
+	return strings.Split(s, sep)
+}
+
+// groupByDir maps parent directories of the pathnames to the sorted
+// lists of their files. Templates can range over the returned map,
+// which visits the directories in sorted order.
+func groupByDir(pathnames []string) map[string][]string {
+	filesByDir := make(map[string][]string)
+
+	for _, pathname := range pathnames {
+		dir := path.Dir(pathname)
+		filesByDir[dir] = append(filesByDir[dir], path.Base(pathname))
+	}
+
+	for _, files := range filesByDir {
+		sort.Strings(files)
+	}
+
+	return filesByDir
+}
+
+func varName(arg string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' ||
+			r >= '0' && r <= '9' {
+			return r
+		} else if r == '+' {
+			return 'x'
+		}
+		return '_'
+	}, arg)
+}
+
+func varNameUC(arg string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		} else if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		} else if r == '+' {
+			return 'X'
+		}
+		return '_'
+	}, arg)
+}
+
+// amConditional returns an AM_CONDITIONAL macro call for configure.ac
+// that defines an Automake conditional, which is true when the feature
+// is enabled with '--enable-<feature>'.
+func amConditional(feature string) string {
+	return "AM_CONDITIONAL([" + varNameUC(feature) +
+		"], [test \"x$enable_" + strings.ToLower(varName(feature)) +
+		"\" = xyes])"
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// naturalLess compares two strings treating each run of decimal
+// digits as a number, so that "file2" goes before "file10".
+func naturalLess(a, b string) bool {
+	i, j := 0, 0
+
+	for i < len(a) && j < len(b) {
+		if !isDigit(a[i]) || !isDigit(b[j]) {
+			if a[i] != b[j] {
+				return a[i] < b[j]
+			}
+			i++
+			j++
+			continue
+		}
+
+		// Compare the numbers ignoring leading zeros.
+		numStartA, numStartB := i, j
+		for i < len(a) && isDigit(a[i]) {
+			i++
+		}
+		for j < len(b) && isDigit(b[j]) {
+			j++
+		}
+		numA := strings.TrimLeft(a[numStartA:i], "0")
+		numB := strings.TrimLeft(b[numStartB:j], "0")
+
+		if len(numA) != len(numB) {
+			return len(numA) < len(numB)
+		}
+		if numA != numB {
+			return numA < numB
+		}
+	}
+
+	if len(a)-i != len(b)-j {
+		return len(a)-i < len(b)-j
+	}
+
+	// The strings are equal up to the leading zeros.
+	return a < b
+}
+
+// naturalSort returns a sorted copy of the list
+// using the natural order of embedded numbers.
+func naturalSort(list []string) []string {
+	sorted := append([]string{}, list...)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return naturalLess(sorted[i], sorted[j])
+	})
+
+	return sorted
+}
+
+// indent prefixes every non-blank line of the text with n tabs.
+func indent(n int, text string) string {
+	prefix := strings.Repeat("\t", n)
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = prefix + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// templateEnv returns the value of the environment variable as seen
+// by the Env and EnvDefault template functions. The values given with
+// the --env option take precedence over the process environment.
+// Either way, the value is captured when the files are generated.
+func templateEnv(name string) (string, bool, error) {
+	value, found := os.LookupEnv(name)
+
+	for _, override := range flags.envOverrides {
+		eq := strings.IndexByte(override, '=')
+		if eq <= 0 {
+			return "", false, errors.New("--env: '" + override +
+				"' is not in the KEY=VALUE format")
+		}
+		if override[:eq] == name {
+			value, found = override[eq+1:], true
+		}
+	}
+
+	return value, found, nil
+}
+
+// coalesce returns the first of its arguments that is not nil,
+// not an empty string, and not an empty slice. If all arguments
+// are empty, the last one is returned.
+func coalesce(values ...interface{}) interface{} {
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+		v := reflect.ValueOf(value)
+		switch v.Kind() {
+		case reflect.String, reflect.Slice, reflect.Array:
+			if v.Len() == 0 {
+				continue
 			}
-			return '_'
-		}, arg)
-	},
-	"VarNameUC": func(arg string) string {
-		return strings.Map(func(r rune) rune {
-			if r >= 'a' && r <= 'z' {
-				return r - 'a' + 'A'
-			} else if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
-				return r
-			} else if r == '+' {
-				return 'X'
+		}
+		return value
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+	return values[len(values)-1]
+}
+
+// hashValues returns the first 16 hexadecimal digits of the SHA-256
+// digest of the string representations of its arguments.
+func hashValues(values ...interface{}) string {
+	h := sha256.New()
+	for _, value := range values {
+		// Separate the values so that ("ab", "c")
+		// and ("a", "bc") produce different hashes.
+		fmt.Fprint(h, value, "\x00")
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// compiledRegexps caches the regular expressions compiled
+// by the Matches function, which is often called in loops.
+var compiledRegexps = struct {
+	sync.Mutex
+	byPattern map[string]*regexp.Regexp
+}{byPattern: make(map[string]*regexp.Regexp)}
+
+// matches returns true if 's' contains a match of the regular
+// expression 'pattern'. An invalid pattern is a template error.
+func matches(pattern, s string) (bool, error) {
+	compiledRegexps.Lock()
+	defer compiledRegexps.Unlock()
+
+	re := compiledRegexps.byPattern[pattern]
+	if re == nil {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			return false, errors.New(templateErrorMarker +
+				"Matches: invalid pattern '" + pattern +
+				"': " + err.Error())
+		}
+		compiledRegexps.byPattern[pattern] = re
+	}
+
+	return re.MatchString(s), nil
+}
+
+// pluralize returns 'singular' if n is one and 'plural' otherwise.
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
+// capitalize converts the first letter of each whitespace-separated
+// word of 's' to title case, or only the first letter of 's' if
+// 'allWords' is false. Punctuation that precedes the first letter
+// of a word is skipped.
+func capitalize(s string, allWords bool) string {
+	var result strings.Builder
+	atWordStart := true
+
+	for _, r := range s {
+		switch {
+		case unicode.IsSpace(r):
+			if allWords {
+				atWordStart = true
 			}
-			return '_'
-		}, arg)
-	},
-	"LibName": func(arg string) string {
-		return strings.Map(func(r rune) rune {
-			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
-				r >= '0' && r <= '9' ||
-				r == '+' || r == '-' || r == '.' {
-				return r
+		case unicode.IsLetter(r):
+			if atWordStart {
+				r = unicode.ToTitle(r)
 			}
-			return '_'
-		}, arg)
+			atWordStart = false
+		case unicode.IsDigit(r):
+			atWordStart = false
+		}
+		result.WriteRune(r)
+	}
+
+	return result.String()
+}
+
+// countItems returns the number of elements in a slice, an array,
+// or a map. A nil value, such as that of a missing parameter, has
+// no elements.
+func countItems(items interface{}) (int, error) {
+	if items == nil {
+		return 0, nil
+	}
+
+	list := reflect.ValueOf(items)
+	switch list.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return list.Len(), nil
+	}
+
+	return 0, errors.New(templateErrorMarker + "Count: " +
+		fmt.Sprintf("%T", items) + " is not a list")
+}
+
+// seqRange returns the integers from 'start' up to but not including
+// 'end'. It is an error for 'end' to be less than 'start'.
+func seqRange(start, end int) ([]int, error) {
+	if end < start {
+		return nil, errors.New(templateErrorMarker + "SeqRange: end " +
+			strconv.Itoa(end) + " is less than start " +
+			strconv.Itoa(start))
+	}
+
+	seq := make([]int, 0, end-start)
+	for i := start; i < end; i++ {
+		seq = append(seq, i)
+	}
+
+	return seq, nil
+}
+
+// seq returns the integers from 0 to n-1 for use with 'range'.
+func seq(n int) ([]int, error) {
+	if n < 0 {
+		return nil, errors.New(templateErrorMarker +
+			"Seq: negative count " + strconv.Itoa(n))
+	}
+	return seqRange(0, n)
+}
+
+// mapField extracts the named field of struct elements or the value
+// of the named key of map elements of 'items', which must be a slice
+// or an array. The extracted values are converted to strings.
+func mapField(field string, items interface{}) ([]string, error) {
+	list := reflect.ValueOf(items)
+	if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+		return nil, errors.New(templateErrorMarker + "Map: " +
+			fmt.Sprintf("%T", items) + " is not a list")
+	}
+
+	result := []string{}
+
+	for i := 0; i < list.Len(); i++ {
+		elem := list.Index(i)
+		for elem.Kind() == reflect.Interface ||
+			elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+
+		var value reflect.Value
+
+		switch elem.Kind() {
+		case reflect.Map:
+			for _, key := range elem.MapKeys() {
+				if fmt.Sprint(key.Interface()) == field {
+					value = elem.MapIndex(key)
+					break
+				}
+			}
+		case reflect.Struct:
+			value = elem.FieldByName(field)
+		}
+
+		if !value.IsValid() || !value.CanInterface() {
+			return nil, errors.New(templateErrorMarker +
+				"Map: element " + strconv.Itoa(i) +
+				" has no field '" + field + "'")
+		}
+
+		result = append(result, fmt.Sprint(value.Interface()))
+	}
+
+	return result, nil
+}
+
+// libName replaces characters that cannot appear in a library
+// name with underscores.
+func libName(arg string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
+			r >= '0' && r <= '9' ||
+			r == '+' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, arg)
+}
+
+// libFileName returns the conventional filename of a static or
+// shared library for the given platform. An empty platform stands
+// for the platform autoforge is running on. Unix platforms other
+// than macOS follow the Linux naming convention.
+func libFileName(name, kind, platform string) (string, error) {
+	if platform == "" {
+		platform = runtime.GOOS
+	}
+
+	name = libName(name)
+
+	var prefix, suffix string
+
+	switch platform {
+	case "windows":
+		switch kind {
+		case "static":
+			suffix = ".lib"
+		case "shared":
+			suffix = ".dll"
+		}
+	case "darwin":
+		prefix = "lib"
+		switch kind {
+		case "static":
+			suffix = ".a"
+		case "shared":
+			suffix = ".dylib"
+		}
+	case "linux", "freebsd", "openbsd", "netbsd", "dragonfly",
+		"solaris", "illumos", "aix", "android":
+		prefix = "lib"
+		switch kind {
+		case "static":
+			suffix = ".a"
+		case "shared":
+			suffix = ".so"
+		}
+	default:
+		return "", fmt.Errorf("%sLibFileName: unsupported "+
+			"platform '%s'", templateErrorMarker, platform)
+	}
+
+	if suffix == "" {
+		return "", fmt.Errorf("%sLibFileName: library kind must "+
+			"be 'static' or 'shared', not '%s'",
+			templateErrorMarker, kind)
+	}
+
+	return prefix + name + suffix, nil
+}
+
+// trimExt removes the final extension from the pathname. The leading
+// dot of a hidden file does not start an extension.
+func trimExt(pathname string) string {
+	ext := filepath.Ext(pathname)
+	if ext == filepath.Base(pathname) {
+		return pathname
+	}
+	return pathname[:len(pathname)-len(ext)]
+}
+
+// withExt replaces the final extension of the pathname with 'ext',
+// which can be given with or without the leading dot.
+func withExt(ext, pathname string) string {
+	if ext != "" && ext[0] != '.' {
+		ext = "." + ext
+	}
+	return trimExt(pathname) + ext
+}
+
+// toJSON encodes the value in compact JSON. Map keys are sorted.
+func toJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(jsonCompatible(value))
+	if err != nil {
+		return "", errors.New(templateErrorMarker + "ToJSON: " +
+			err.Error())
+	}
+	return string(data), nil
+}
+
+// toYAML encodes the value in YAML without the trailing newline.
+// Map keys are sorted.
+func toYAML(value interface{}) (string, error) {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return "", errors.New(templateErrorMarker + "ToYAML: " +
+			err.Error())
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// listElem returns the element of 'items', which must be a non-empty
+// slice or array, at the given index. Negative indices count from
+// the end. 'funcName' is used in error messages.
+func listElem(funcName string, items interface{},
+	index int) (interface{}, error) {
+	list := reflect.ValueOf(items)
+	if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+		return nil, fmt.Errorf("%s%s: %T is not a list",
+			templateErrorMarker, funcName, items)
+	}
+
+	if list.Len() == 0 {
+		return nil, errors.New(templateErrorMarker + funcName +
+			": the list is empty")
+	}
+
+	if index < 0 {
+		index += list.Len()
+	}
+
+	return list.Index(index).Interface(), nil
+}
+
+var commonFuncMap = template.FuncMap{
+	"VarName":       varName,
+	"VarNameUC":     varNameUC,
+	"LibName":       libName,
+	"LibFileName":   libFileName,
+	"AmConditional": amConditional,
+	"AmIf": func(feature string) string {
+		return "if " + varNameUC(feature)
+	},
+	"AmEndif": func() string {
+		return "endif"
+	},
+	"TrimExt":              trimExt,
+	"WithExt":              withExt,
+	"PackageColor":         packageColor,
+	"RequiredPackage":      requiredPackageName,
+	"PkgConfigRequirement": pkgConfigRequirement,
+	"TrimPrefix": func(prefix, s string) string {
+		return strings.TrimPrefix(s, prefix)
+	},
+	"TrimSuffix": func(suffix, s string) string {
+		return strings.TrimSuffix(s, suffix)
+	},
+	"Trim": func(cutset, s string) string {
+		return strings.Trim(s, cutset)
 	},
-	"TrimExt": func(filename string) string {
-		return filename[:len(filename)-len(filepath.Ext(filename))]
+	"TrimSpace": strings.TrimSpace,
+	"HasPrefix": func(prefix, s string) bool {
+		return strings.HasPrefix(s, prefix)
+	},
+	"HasSuffix": func(suffix, s string) bool {
+		return strings.HasSuffix(s, suffix)
+	},
+	"ReplaceAll": func(old, new, s string) string {
+		return strings.ReplaceAll(s, old, new)
 	},
 	"StringList": func(elem ...string) []string {
 		return elem
 	},
+	"Split":       splitString,
+	"GroupByDir":  groupByDir,
+	"NaturalSort": naturalSort,
+	"Indent":      indent,
+	"ToolVersion": toolVersion,
+	"Map":         mapField,
+	"First": func(items interface{}) (interface{}, error) {
+		return listElem("First", items, 0)
+	},
+	"Last": func(items interface{}) (interface{}, error) {
+		return listElem("Last", items, -1)
+	},
+	"Join": func(sep string, elems []string) string {
+		return strings.Join(elems, sep)
+	},
+	"GeneratedFileMarker": func() string {
+		return generatedFileMarker
+	},
+	"Skip": func() string {
+		return skipFileMarker
+	},
+	"Coalesce":  coalesce,
+	"Hash":      hashValues,
+	"Pluralize": pluralize,
+	"Count":     countItems,
+	"Matches":   matches,
+	"ToJSON":    toJSON,
+	"ToYAML":    toYAML,
+	"Seq":       seq,
+	"SeqRange":  seqRange,
+	"Title": func(s string) string {
+		return capitalize(s, true)
+	},
+	"Capitalize": func(s string) string {
+		return capitalize(s, false)
+	},
+	"Env": func(name string) (string, error) {
+		value, _, err := templateEnv(name)
+		return value, err
+	},
+	"EnvDefault": func(name, fallback string) (string, error) {
+		value, _, err := templateEnv(name)
+		if value == "" && err == nil {
+			value = fallback
+		}
+		return value, err
+	},
 	"Select": func(pathnames, patterns []string) []string {
 		return filterPathnames(pathnames, patterns, false)
 	},
 	"Exclude": func(pathnames, patterns []string) []string {
 		return filterPathnames(pathnames, patterns, true)
 	},
+	"PrereqList": prereqList,
+	"ConfigFiles": func(pathnames []string) string {
+		if len(pathnames) == 0 {
+			return ""
+		}
+		return "AC_CONFIG_FILES([" +
+			strings.Join(pathnames, "\n") + "])"
+	},
 	"Comment": func(text string) string {
 		var result string
 
@@ -109,14 +681,41 @@ type filenameAndContents struct {
 	contents []byte
 }
 
+// altDelimsSuffix is the filename suffix of the template files that
+// use '<%' and '%>' as action delimiters instead of '{{' and '}}',
+// which is convenient for output files that contain the latter
+// literally. The suffix is removed from the output filename.
+var altDelimsSuffix = ".tmpl2"
+
+// templateDelims returns the action delimiters for the named template.
+// Empty strings stand for the default delimiters.
+func templateDelims(templateName string) (string, string) {
+	if strings.HasSuffix(templateName, altDelimsSuffix) {
+		return "<%", "%>"
+	}
+	return "", ""
+}
+
 func parseAndExecuteTemplate(templateName string, templateContents []byte,
 	funcMap template.FuncMap, associatedTemplates map[string]string,
 	fileParams []outputFileParams) ([]filenameAndContents, error) {
 
+	return executeTemplateWithOptions(templateName, templateContents,
+		funcMap, associatedTemplates, nil, fileParams)
+}
+
+// executeTemplateWithOptions is parseAndExecuteTemplate that also
+// sets the specified text/template options, such as "missingkey=error".
+func executeTemplateWithOptions(templateName string, templateContents []byte,
+	funcMap template.FuncMap, associatedTemplates map[string]string,
+	options []string,
+	fileParams []outputFileParams) ([]filenameAndContents, error) {
+
 	// Parse the template file. The parsed template will be
 	// reused multiple times if expandPathnameTemplate()
 	// returns more than one pathname expansion.
 	t := template.New(filepath.Base(templateName))
+	t.Option(options...)
 	t.Funcs(commonFuncMap)
 
 	t.Funcs(funcMap)
@@ -125,6 +724,9 @@ func parseAndExecuteTemplate(templateName string, templateContents []byte,
 		template.Must(t.New(name).Parse(text))
 	}
 
+	// Associated templates keep the default delimiters.
+	t.Delims(templateDelims(templateName))
+
 	if _, err := t.Parse(string(templateContents)); err != nil {
 		return nil, err
 	}
@@ -147,12 +749,59 @@ func parseAndExecuteTemplate(templateName string, templateContents []byte,
 
 var templateErrorMarker = "AFTMPLERR"
 
-func executePackageFileTemplate(templateName string,
-	templateContents []byte, pd *packageDefinition,
-	dirTree *directoryTree,
-	fileParams []outputFileParams) ([]filenameAndContents, error) {
+// skipFileMarker is returned by the Skip function. An output file
+// whose rendered contents consist of this marker is not written.
+var skipFileMarker = "AFSKIPFILE"
+
+// withoutSkippedFiles removes the files that were
+// skipped by their templates from the list.
+func withoutSkippedFiles(
+	outputFiles []filenameAndContents) []filenameAndContents {
+	var result []filenameAndContents
+
+	for _, outputFile := range outputFiles {
+		if string(bytes.TrimSpace(outputFile.contents)) !=
+			skipFileMarker {
+			result = append(result, outputFile)
+		}
+	}
+
+	return result
+}
 
-	funcMap := template.FuncMap{
+// copyrightHeaderTemplate is rendered by the CopyrightHeader function
+// with the parameters of the package unless the workspace settings
+// replace it.
+var copyrightHeaderTemplate = `{{if .holder -}}
+Copyright (C) {{if .year}}{{.year}} {{end}}{{.holder}}. All rights reserved.
+{{end}}`
+
+// headerTemplate returns the template of the text rendered by the
+// CopyrightHeader function: either the one from the workspace
+// settings or the default one. The index can be nil.
+func (pi *packageIndex) headerTemplate() string {
+	if pi != nil && pi.copyrightHeader != "" {
+		return pi.copyrightHeader
+	}
+	return copyrightHeaderTemplate
+}
+
+func renderCopyrightHeader(headerTemplate string,
+	params templateParams) (string, error) {
+	result, err := parseAndExecuteTemplate("CopyrightHeader",
+		[]byte(headerTemplate), nil, nil,
+		[]outputFileParams{{"", params}})
+	if err != nil {
+		return "", err
+	}
+	return string(result[0].contents), nil
+}
+
+// packageFuncMap returns the template functions
+// that are specific to the package being generated.
+func packageFuncMap(pd *packageDefinition, pi *packageIndex,
+	dirTree *directoryTree) template.FuncMap {
+	return template.FuncMap{
 		"Error": func(errorMessage string) (string, error) {
 			return "", errors.New(templateErrorMarker +
 				pd.PackageName + ": " + errorMessage)
@@ -162,14 +811,170 @@ func executePackageFileTemplate(templateName string,
 				return st.list()
 			}
 			return nil
+		},
+		"DirExcept": func(root, include, exclude string) []string {
+			if st := dirTree.subtree(root); st != nil {
+				return filterPathnames(filterPathnames(st.list(),
+					[]string{include}, false),
+					[]string{exclude}, true)
+			}
+			return nil
+		},
+		"CopyrightHeader": func() (string, error) {
+			return renderCopyrightHeader(pi.headerTemplate(),
+				pd.params)
+		},
+		"PkgParam": func(pkgName, key string) (interface{}, error) {
+			var other *packageDefinition
+			if pi != nil {
+				other = pi.packageByName[pkgName]
+			}
+			if other == nil {
+				return nil, errors.New("unknown package '" +
+					pkgName + "'")
+			}
+			value, ok := other.params[key]
+			if !ok {
+				return nil, errors.New("package '" + pkgName +
+					"' has no parameter '" + key + "'")
+			}
+			return value, nil
 		}}
+}
+
+// packageTemplateDefinitions returns the named templates that package
+// file templates can invoke: the common definitions and the partials
+// of the project template.
+func packageTemplateDefinitions(
+	partials map[string]string) map[string]string {
+	if len(partials) == 0 {
+		return commonDefinitions
+	}
+
+	definitions := make(map[string]string)
+
+	for name, text := range commonDefinitions {
+		definitions[name] = text
+	}
+	for name, text := range partials {
+		definitions[name] = text
+	}
+
+	return definitions
+}
+
+// includeFile returns the contents of the file with the pathname
+// relative to 'dir'. The file must be inside the 'root' directory.
+func includeFile(root, dir, relPath string) (string, error) {
+	if filepath.IsAbs(relPath) {
+		return "", errors.New(templateErrorMarker + "IncludeFile: '" +
+			relPath + "' is not a relative pathname")
+	}
+
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", errors.New(templateErrorMarker + "IncludeFile: " +
+			err.Error())
+	}
+
+	// Resolve symbolic links so that they cannot point outside.
+	pathname, err := filepath.EvalSymlinks(filepath.Join(dir, relPath))
+	if err != nil {
+		return "", errors.New(templateErrorMarker + "IncludeFile: " +
+			err.Error())
+	}
+
+	if rel, err := filepath.Rel(root, pathname); err != nil ||
+		rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", errors.New(templateErrorMarker + "IncludeFile: '" +
+			relPath + "' is outside of " + root)
+	}
 
-	return parseAndExecuteTemplate(templateName, templateContents,
-		funcMap, commonDefinitions, fileParams)
+	contents, err := ioutil.ReadFile(pathname)
+	if err != nil {
+		return "", errors.New(templateErrorMarker + "IncludeFile: " +
+			err.Error())
+	}
+
+	return string(contents), nil
+}
+
+// executePackageFileTemplate renders a template file for the package.
+// The IncludeFile function resolves pathnames relative to the directory
+// of the template file inside 'templateDir' or, for embedded templates
+// (when 'templateDir' is empty), relative to the package source
+// directory. With --strict-templates, a reference to a missing
+// parameter in a template from 'templateDir' is an error. Embedded
+// templates test optional parameters and are always lenient.
+func executePackageFileTemplate(templateDir, templateName string,
+	templateContents []byte, pd *packageDefinition, pi *packageIndex,
+	dirTree *directoryTree, partials map[string]string,
+	fileParams []outputFileParams) ([]filenameAndContents, error) {
+
+	includeRoot := templateDir
+	includeDir := path.Join(templateDir, path.Dir(templateName))
+	if templateDir == "" {
+		includeRoot = filepath.Dir(pd.pathname)
+		includeDir = includeRoot
+	}
+
+	funcMap := packageFuncMap(pd, pi, dirTree)
+	funcMap["IncludeFile"] = func(relPath string) (string, error) {
+		return includeFile(includeRoot, includeDir, relPath)
+	}
+
+	var options []string
+	if flags.strictTemplates && templateDir != "" {
+		options = append(options, "missingkey=error")
+	}
+
+	return executeTemplateWithOptions(templateName, templateContents,
+		funcMap, packageTemplateDefinitions(partials), options,
+		fileParams)
+}
+
+// generatedFileMarker is the text that the GeneratedFileMarker
+// template function returns. In safe mode, only the files that
+// contain this text or are listed in the manifest can be overwritten.
+var generatedFileMarker = "This file was generated by " + appName + "."
+
+// isGeneratedFile returns true if the file either contains
+// the generation marker or was generated by the previous run.
+func isGeneratedFile(pathname string, contents []byte) bool {
+	return manifest.wasGenerated(pathname) ||
+		bytes.Contains(contents, []byte(generatedFileMarker))
+}
+
+// keepUntrackedFile decides whether an existing file with different
+// contents can be overwritten. A file that was not generated is an
+// error in safe mode. Otherwise, if the manifest keeps untracked
+// files, a warning is printed and the function returns true to
+// leave the file intact.
+func keepUntrackedFile(projectFile, absProjectFile string,
+	contents []byte) (bool, error) {
+	if isGeneratedFile(absProjectFile, contents) {
+		return false, nil
+	}
+
+	if flags.safe {
+		return false, errors.New(projectFile +
+			": not a generated file; refusing to overwrite it")
+	}
+
+	if !manifest.keepsUntrackedFiles() {
+		return false, nil
+	}
+
+	fmt.Fprintln(os.Stderr, projectFile+": warning: not a generated "+
+		"file; use --overwrite to replace it")
+	manifest.remove(absProjectFile)
+
+	return true, nil
 }
 
 func writeGeneratedFiles(targetDir string, outputFiles []filenameAndContents,
 	templateFileMode os.FileMode) (bool, error) {
+	absTargetDir := targetDir
 	targetDir, err := relativeToCwd(targetDir)
 	if err != nil {
 		return false, err
@@ -181,10 +986,14 @@ func writeGeneratedFiles(targetDir string, outputFiles []filenameAndContents,
 
 		projectFile := path.Join(targetDir, outputFile.filename)
 
+		absProjectFile := path.Join(absTargetDir, outputFile.filename)
+		manifest.add(absProjectFile)
+
 		existingFileInfo, err := os.Lstat(projectFile)
 		if err != nil {
 			if os.IsNotExist(err) {
-				if err := os.MkdirAll(filepath.Dir(projectFile),
+				if err := mkdirAllWithRetries(
+					filepath.Dir(projectFile),
 					os.ModePerm); err != nil {
 					return false, err
 				}
@@ -194,15 +1003,35 @@ func writeGeneratedFiles(targetDir string, outputFiles []filenameAndContents,
 		} else if (existingFileInfo.Mode() & os.ModeSymlink) == 0 {
 			oldContents, err := ioutil.ReadFile(projectFile)
 			if err == nil {
-				if bytes.Compare(oldContents,
+				if !flags.force && bytes.Compare(oldContents,
 					outputFile.contents) == 0 {
+					// Restore the permissions if only
+					// they differ from the template.
+					if existingFileInfo.Mode().Perm() ==
+						templateFileMode.Perm() {
+						continue
+					}
+					reportFileChange("U", projectFile)
+					if err = os.Chmod(projectFile,
+						templateFileMode.Perm()); err != nil {
+						return false, err
+					}
+					changesMade = true
+					continue
+				}
+				keep, err := keepUntrackedFile(projectFile,
+					absProjectFile, oldContents)
+				if err != nil {
+					return false, err
+				}
+				if keep {
 					continue
 				}
 				mode = "U"
 			}
 		}
 
-		fmt.Println(mode, projectFile)
+		reportFileChange(mode, projectFile)
 		if mode == "R" {
 			if err = os.Remove(projectFile); err != nil {
 				return false, err
@@ -211,7 +1040,7 @@ func writeGeneratedFiles(targetDir string, outputFiles []filenameAndContents,
 
 		changesMade = true
 
-		if err = ioutil.WriteFile(projectFile, outputFile.contents,
+		if err = writeFileWithRetries(projectFile, outputFile.contents,
 			templateFileMode); err != nil {
 			return false, err
 		}
@@ -220,13 +1049,15 @@ func writeGeneratedFiles(targetDir string, outputFiles []filenameAndContents,
 	return changesMade, nil
 }
 
-func generateFilesFromProjectFileTemplate(projectDir, templateName string,
-	templateContents []byte, templateFileMode os.FileMode,
-	pd *packageDefinition, dirTree *directoryTree,
+func generateFilesFromProjectFileTemplate(projectDir, templateDir,
+	templateName string, templateContents []byte,
+	templateFileMode os.FileMode, pd *packageDefinition, pi *packageIndex,
+	dirTree *directoryTree, partials map[string]string,
 	fileParams []outputFileParams) (bool, error) {
 
-	outputFiles, err := executePackageFileTemplate(templateName,
-		templateContents, pd, dirTree, fileParams)
+	outputFiles, err := executePackageFileTemplate(templateDir,
+		templateName, templateContents, pd, pi, dirTree, partials,
+		fileParams)
 
 	if err != nil {
 		if err, ok := err.(template.ExecError); ok {
@@ -240,5 +1071,6 @@ func generateFilesFromProjectFileTemplate(projectDir, templateName string,
 		return false, err
 	}
 
-	return writeGeneratedFiles(projectDir, outputFiles, templateFileMode)
+	return writeGeneratedFiles(projectDir,
+		withoutSkippedFiles(outputFiles), templateFileMode)
 }
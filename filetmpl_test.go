@@ -5,6 +5,12 @@
 package main
 
 import (
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -28,3 +34,797 @@ func TestTemplateFunctions(t *testing.T) {
 	runTemplateFunctionTest(t, "LibName", "libc++11", "libc++11")
 	runTemplateFunctionTest(t, "LibName", "dash-dot.", "dash-dot.")
 }
+
+func TestPackageColor(t *testing.T) {
+	validColor := regexp.MustCompile(`^#[89a-f][0-9a-f][89a-f][0-9a-f]` +
+		`[89a-f][0-9a-f]$`)
+
+	for _, name := range []string{"", "base", "client", "libc++11"} {
+		color := packageColor(name)
+
+		if !validColor.MatchString(color) {
+			t.Error("Not a pastel color: \"" + color + "\"")
+		}
+
+		runTemplateFunctionTest(t, "PackageColor", name, color)
+	}
+
+	if packageColor("base") == packageColor("client") {
+		t.Error("Different packages are expected to have " +
+			"different colors")
+	}
+}
+
+func runTemplateTest(t *testing.T, text string, params templateParams,
+	expected string) {
+
+	result, err := parseAndExecuteTemplate("test", []byte(text),
+		nil, nil, []outputFileParams{{"test", params}})
+
+	if err != nil {
+		t.Error("Error: " + err.Error())
+	} else if string(result[0].contents) != expected {
+		t.Error("Error: \"" + string(result[0].contents) +
+			"\" != \"" + expected + "\"")
+	}
+}
+
+func TestTrimPrefixAndSuffix(t *testing.T) {
+	params := templateParams{"source": "src/main.cc"}
+
+	runTemplateTest(t, `{{.source | TrimSuffix ".cc"}}`,
+		params, "src/main")
+	runTemplateTest(t, `{{.source | TrimPrefix "src/"}}`,
+		params, "main.cc")
+	runTemplateTest(t, `{{.source | TrimPrefix "src/" | TrimSuffix ".cc"}}`,
+		params, "main")
+
+	runTemplateTest(t, `{{.source | TrimSuffix ".h"}}`,
+		params, "src/main.cc")
+	runTemplateTest(t, `{{.source | TrimPrefix "include/"}}`,
+		params, "src/main.cc")
+	runTemplateTest(t, `{{TrimPrefix "" .source}}`,
+		params, "src/main.cc")
+}
+
+func TestTrimExtAndWithExt(t *testing.T) {
+	for _, testCase := range []struct {
+		pathname, trimmed, withExt string
+	}{
+		{"src/main.cc", "src/main", "src/main.o"},
+		{"archive.tar.gz", "archive.tar", "archive.tar.o"},
+		{"src/v1.2/README", "src/v1.2/README", "src/v1.2/README.o"},
+		{".bashrc", ".bashrc", ".bashrc.o"},
+		{"etc/.profile.in", "etc/.profile", "etc/.profile.o"},
+		{"", "", ".o"},
+	} {
+		runTemplateFunctionTest(t, "TrimExt", testCase.pathname,
+			testCase.trimmed)
+
+		for _, ext := range []string{"o", ".o"} {
+			if result := withExt(ext, testCase.pathname); result !=
+				testCase.withExt {
+				t.Error("Unexpected WithExt result: " + result)
+			}
+		}
+	}
+
+	runTemplateTest(t, `{{.source | WithExt "h"}} {{WithExt "" .source}}`,
+		templateParams{"source": "src/main.cc"}, "src/main.h src/main")
+}
+
+func TestTrimAndTrimSpace(t *testing.T) {
+	params := templateParams{
+		"name":      "  libfoo\t\n",
+		"multiline": "\n\n  first line\n  second line  \n\n",
+		"path":      "/usr/local/",
+	}
+
+	runTemplateTest(t, `[{{.name | TrimSpace}}]`, params, "[libfoo]")
+	runTemplateTest(t, `[{{.multiline | TrimSpace}}]`, params,
+		"[first line\n  second line]")
+	runTemplateTest(t, `[{{TrimSpace ""}}]`, params, "[]")
+
+	runTemplateTest(t, `{{.path | Trim "/"}}`, params, "usr/local")
+	runTemplateTest(t, `{{Trim "-_" "--foo_bar__"}}`, params, "foo_bar")
+	runTemplateTest(t, `[{{.multiline | Trim "\n"}}]`, params,
+		"[  first line\n  second line  ]")
+	runTemplateTest(t, `{{.path | Trim ""}}`, params, "/usr/local/")
+	runTemplateTest(t, `{{.name | TrimSpace | Trim "lib"}}`, params,
+		"foo")
+}
+
+func TestConfigFiles(t *testing.T) {
+	runTemplateTest(t, `{{ConfigFiles (StringList "Makefile")}}`,
+		nil, "AC_CONFIG_FILES([Makefile])")
+
+	runTemplateTest(t, `{{ConfigFiles .makefiles}}
+AC_OUTPUT`,
+		templateParams{"makefiles": []string{
+			"Makefile", "src/Makefile", "tests/Makefile"}},
+		`AC_CONFIG_FILES([Makefile
+src/Makefile
+tests/Makefile])
+AC_OUTPUT`)
+
+	runTemplateTest(t, `{{ConfigFiles .makefiles}}`,
+		templateParams{"makefiles": []string{}}, "")
+}
+
+func TestPrereqList(t *testing.T) {
+	runTemplateTest(t, `{{PrereqList "src" .sources}}`,
+		templateParams{"sources": []string{
+			"src/main.cc", "src/util/str.cc", "include/str.h"}},
+		"main.cc util/str.cc ../include/str.h")
+
+	runTemplateTest(t, `{{PrereqList "/ws/src" .sources}}`,
+		templateParams{"sources": []string{
+			"/ws/src/my file.cc", "/ws/lib/$name.cc"}},
+		`my\ file.cc ../lib/$$name.cc`)
+
+	runTemplateTest(t, `{{PrereqList "." .sources}}`,
+		templateParams{"sources": []string{}}, "")
+}
+
+func TestSplit(t *testing.T) {
+	runTemplateTest(t, `{{range Split "," .list}}[{{.}}]{{end}}`,
+		templateParams{"list": "a,b,,c"}, "[a][b][][c]")
+
+	runTemplateTest(t, `{{range Split " " .list}}[{{.}}]{{end}}`,
+		templateParams{"list": " a  b\tc \n"}, "[a][b][c]")
+
+	runTemplateTest(t, `{{len (Split "," .list)}}`,
+		templateParams{"list": ""}, "0")
+	runTemplateTest(t, `{{len (Split " " .list)}}`,
+		templateParams{"list": ""}, "0")
+}
+
+func TestGroupByDir(t *testing.T) {
+	runTemplateTest(t, `{{range $dir, $files := GroupByDir .sources}}
+{{- $dir}}:{{range $files}} {{.}}{{end}}
+{{end}}`,
+		templateParams{"sources": []string{
+			"src/util/str.cc", "main.cc", "src/main.cc",
+			"include/str.h", "src/app.cc", "include/app.h"}},
+		`.: main.cc
+include: app.h str.h
+src: app.cc main.cc
+src/util: str.cc
+`)
+
+	runTemplateTest(t, `{{len (GroupByDir .sources)}}`,
+		templateParams{"sources": []string{}}, "0")
+}
+
+func TestGeneratedFileMode(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "filemode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	pathname := path.Join(tempDir, "autogen.sh")
+
+	checkMode := func(expected os.FileMode) {
+		fileInfo, err := os.Stat(pathname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fileInfo.Mode().Perm() != expected {
+			t.Errorf("Unexpected file mode: %v; expected: %v",
+				fileInfo.Mode().Perm(), expected)
+		}
+	}
+
+	writeFile := func(contents string, expectChanges bool) {
+		changesMade, err := writeGeneratedFiles(tempDir,
+			[]filenameAndContents{
+				{"autogen.sh", []byte(contents)}}, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changesMade != expectChanges {
+			t.Errorf("Unexpected change status: %v", changesMade)
+		}
+	}
+
+	// Contents differ.
+	writeFileForTesting(t, pathname, "#!/bin/sh\n")
+	writeFile("#!/bin/sh\nautoreconf -i\n", true)
+	checkMode(0755)
+
+	// Only the mode differs.
+	if err = os.Chmod(pathname, 0600); err != nil {
+		t.Fatal(err)
+	}
+	writeFile("#!/bin/sh\nautoreconf -i\n", true)
+	checkMode(0755)
+
+	// Neither differs.
+	writeFile("#!/bin/sh\nautoreconf -i\n", false)
+	checkMode(0755)
+}
+
+func TestCopyrightHeader(t *testing.T) {
+	pd := &packageDefinition{PackageName: "a", params: templateParams{
+		"holder": "Example Corp", "year": 2018}}
+
+	runHeaderTest := func(pi *packageIndex, expected string) {
+		result, err := executePackageFileTemplate("", "test",
+			[]byte(`{{CopyrightHeader | Comment}}main`), pd, pi,
+			nil, nil, []outputFileParams{{"test", pd.params}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(result[0].contents) != expected {
+			t.Error("Unexpected header: " +
+				string(result[0].contents))
+		}
+	}
+
+	runHeaderTest(nil, "# Copyright (C) 2018 Example Corp. "+
+		"All rights reserved.\n#\nmain")
+
+	customHeader := "(c) {{.holder}}, {{.year}}"
+
+	runHeaderTest(&packageIndex{copyrightHeader: customHeader},
+		"# (c) Example Corp, 2018\n#\nmain")
+
+	refs := paramRefs{}
+	if err := refs.addTemplate("test", []byte("{{CopyrightHeader}}"),
+		nil, customHeader); err != nil {
+		t.Fatal(err)
+	}
+	if !refs["holder"] || !refs["year"] {
+		t.Error("Parameters of the copyright header are not detected")
+	}
+}
+
+func TestAutomakeConditionals(t *testing.T) {
+	runTemplateTest(t, `{{AmConditional "zlib-support"}}`, nil,
+		`AM_CONDITIONAL([ZLIB_SUPPORT], `+
+			`[test "x$enable_zlib_support" = xyes])`)
+
+	runTemplateTest(t, `lib_SOURCES = core.cc
+{{AmIf "zlib-support"}}
+lib_SOURCES += compress.cc
+{{AmEndif}}
+`, nil, `lib_SOURCES = core.cc
+if ZLIB_SUPPORT
+lib_SOURCES += compress.cc
+endif
+`)
+}
+
+func TestNaturalSort(t *testing.T) {
+	runTemplateTest(t, `{{range NaturalSort .files}}{{.}} {{end}}`,
+		templateParams{"files": []string{
+			"file10.cc", "file2.cc", "file1.cc", "file02.cc",
+			"file100.cc", "file.cc", "a20b3.h", "a20b10.h",
+			"a3b.h"}},
+		"a3b.h a20b3.h a20b10.h file.cc file1.cc file02.cc "+
+			"file2.cc file10.cc file100.cc ")
+
+	list := []string{"b", "a"}
+	if sorted := naturalSort(list); sorted[0] != "a" || list[0] != "b" {
+		t.Error("NaturalSort must not modify its argument")
+	}
+}
+
+func TestDirExcept(t *testing.T) {
+	dirTree := newDirectoryTree()
+	for _, pathname := range []string{
+		"src/main.cc", "src/main_test.cc", "src/util/str.cc",
+		"src/util/str_test.cc", "src/util/str.h", "README"} {
+		dirTree.addFile(pathname)
+	}
+
+	pd := &packageDefinition{PackageName: "a"}
+
+	result, err := executePackageFileTemplate("", "test", []byte(
+		`{{range DirExcept "src" "*.cc" "*_test.cc"}}{{.}} {{end}}|`+
+			`{{len (DirExcept "doc" "*" "")}}`),
+		pd, nil, dirTree, nil, []outputFileParams{{"test", nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "main.cc util/str.cc |0"
+	if string(result[0].contents) != expected {
+		t.Error("Error: \"" + string(result[0].contents) +
+			"\" != \"" + expected + "\"")
+	}
+}
+
+func TestHasPrefixAndSuffix(t *testing.T) {
+	params := templateParams{"file": "src/main.cc", "title": "Größe"}
+
+	runTemplateTest(t, `{{if .file | HasSuffix ".cc"}}yes{{end}}`,
+		params, "yes")
+	runTemplateTest(t, `{{if .file | HasSuffix ".h"}}yes{{end}}`,
+		params, "")
+	runTemplateTest(t, `{{.file | HasPrefix "src/"}}`,
+		params, "true")
+	runTemplateTest(t, `{{.file | HasPrefix "include/"}}`,
+		params, "false")
+
+	runTemplateTest(t, `{{HasPrefix "" .file}} {{HasSuffix "" .file}}`,
+		params, "true true")
+
+	runTemplateTest(t, `{{.title | HasPrefix "Grö"}} `+
+		`{{.title | HasSuffix "ße"}} {{.title | HasSuffix "e"}}`,
+		params, "true true true")
+	runTemplateTest(t, `{{.title | HasPrefix "Gro"}}`,
+		params, "false")
+}
+
+func TestIndent(t *testing.T) {
+	runTemplateTest(t, `{{Indent 1 "echo done"}}`, nil, "\techo done")
+
+	runTemplateTest(t, `{{Indent 2 .script}}`,
+		templateParams{"script": "cd src\n\nmake\n  \nmake check"},
+		"\t\tcd src\n\n\t\tmake\n  \n\t\tmake check")
+
+	runTemplateTest(t, `{{.script | Indent 1}}`,
+		templateParams{"script": "one\ntwo\n"}, "\tone\n\ttwo\n")
+
+	runTemplateTest(t, `{{Indent 0 "text"}}`, nil, "text")
+}
+
+func TestMap(t *testing.T) {
+	externalLibs := []interface{}{
+		map[interface{}]interface{}{
+			"name": "z", "function": "deflate"},
+		map[interface{}]interface{}{
+			"name": "ssl", "function": "SSL_new"}}
+
+	runTemplateTest(t, `{{Join " " (Map "name" .external_libs)}}`,
+		templateParams{"external_libs": externalLibs}, "z ssl")
+
+	type library struct {
+		Name    string
+		Version int
+	}
+
+	runTemplateTest(t, `{{Map "Version" .libs | Join ", "}}`,
+		templateParams{"libs": []library{{"z", 1}, {"ssl", 3}}},
+		"1, 3")
+	runTemplateTest(t, `{{Map "Name" .libs | Join ", "}}`,
+		templateParams{"libs": []*library{{"z", 1}}}, "z")
+	runTemplateTest(t, `{{len (Map "Name" .libs)}}`,
+		templateParams{"libs": []library{}}, "0")
+
+	for _, testCase := range []struct {
+		params   templateParams
+		expected string
+	}{
+		{templateParams{"libs": externalLibs},
+			"Map: element 0 has no field 'Function'"},
+		{templateParams{"libs": []library{{"z", 1}}},
+			"Map: element 0 has no field 'Function'"},
+		{templateParams{"libs": "z"}, "Map: string is not a list"}} {
+		_, err := parseAndExecuteTemplate("test",
+			[]byte(`{{Map "Function" .libs}}`), nil, nil,
+			[]outputFileParams{{"test", testCase.params}})
+		if err == nil || !strings.Contains(err.Error(),
+			templateErrorMarker+testCase.expected) {
+			t.Error("Missing field is not reported")
+		}
+	}
+}
+
+func TestFirstAndLast(t *testing.T) {
+	params := templateParams{
+		"sources":  []interface{}{"main.cc", "util.cc", "net.cc"},
+		"versions": []string{"1.0"},
+		"empty":    []interface{}{},
+	}
+
+	runTemplateTest(t, `{{First .sources}} {{Last .sources}}`,
+		params, "main.cc net.cc")
+	runTemplateTest(t, `{{First .versions}} {{Last .versions}}`,
+		params, "1.0 1.0")
+	runTemplateTest(t, `{{.sources | First | TrimExt}}`, params, "main")
+
+	type release struct {
+		Version string
+		Date    string
+	}
+
+	runTemplateTest(t, `{{(Last .releases).Version}}`,
+		templateParams{"releases": []release{
+			{"1.0", "2017-05-01"}, {"1.1", "2018-02-14"}}}, "1.1")
+
+	for _, text := range []string{`{{First .empty}}`, `{{Last .empty}}`,
+		`{{First .missing}}`, `{{Last "text"}}`} {
+		_, err := parseAndExecuteTemplate("test", []byte(text),
+			nil, nil, []outputFileParams{{"test", params}})
+		if err == nil || !strings.Contains(err.Error(),
+			templateErrorMarker) {
+			t.Error("Invalid argument was not reported: " + text)
+		}
+	}
+}
+
+func TestPkgParam(t *testing.T) {
+	pi, err := makePackageIndexForTesting([]string{"base", "app:base"},
+		true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pi.packageByName["base"].params = templateParams{"version": "2.1"}
+
+	pd := pi.packageByName["app"]
+
+	execute := func(text string) (string, error) {
+		result, err := executePackageFileTemplate("", "test", []byte(text),
+			pd, pi, nil, nil, []outputFileParams{{"test", nil}})
+		if err != nil {
+			return "", err
+		}
+		return string(result[0].contents), nil
+	}
+
+	result, err := execute(`Requires: base >= {{PkgParam "base" "version"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "Requires: base >= 2.1" {
+		t.Error("Unexpected result: " + result)
+	}
+
+	_, err = execute(`{{PkgParam "base" "license"}}`)
+	if err == nil || !strings.Contains(err.Error(),
+		"package 'base' has no parameter 'license'") {
+		t.Error("Missing parameter was not reported")
+	}
+
+	_, err = execute(`{{PkgParam "other" "version"}}`)
+	if err == nil || !strings.Contains(err.Error(),
+		"unknown package 'other'") {
+		t.Error("Missing package was not reported")
+	}
+}
+
+func TestSkipFunction(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "skip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	pd := &packageDefinition{PackageName: "a"}
+
+	fileParams := expandPathnameTemplate("{kind}/harness.c",
+		templateParams{"kind": []string{"lib", "app", "test"}})
+
+	changesMade, err := generateFilesFromProjectFileTemplate(tempDir, "",
+		"{kind}/harness.c", []byte(
+			`{{if eq .kind "app"}}{{Skip}}
+{{else}}/* {{.kind}} */{{end}}`),
+		0644, pd, nil, nil, nil, fileParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changesMade {
+		t.Error("Files that are not skipped must be written")
+	}
+
+	// Not even the directory of the skipped file is created.
+	if files := listFilesForTesting(t, tempDir); files !=
+		"lib/harness.c, test/harness.c" {
+		t.Error("Unexpected file set: " + files)
+	}
+	if _, err = os.Stat(path.Join(tempDir, "app")); !os.IsNotExist(err) {
+		t.Error("Directory of the skipped file must not be created")
+	}
+}
+
+func TestReplaceAll(t *testing.T) {
+	params := templateParams{"version": "1.2.10"}
+
+	runTemplateTest(t, `{{.version | ReplaceAll "." "_"}}`,
+		params, "1_2_10")
+	runTemplateTest(t, `{{ReplaceAll "1" "" .version}}`, params, ".2.0")
+
+	// Matches do not overlap and are replaced left to right.
+	runTemplateTest(t, `{{ReplaceAll "aa" "b" "aaaaa"}}`, nil, "bba")
+	runTemplateTest(t, `{{ReplaceAll "aba" "x" "ababa"}}`, nil, "xba")
+
+	// An empty pattern matches at the start of
+	// the string and after each UTF-8 sequence.
+	runTemplateTest(t, `{{ReplaceAll "" "-" "añb"}}`, nil, "-a-ñ-b-")
+	runTemplateTest(t, `{{ReplaceAll "" "-" ""}}`, nil, "-")
+}
+
+func TestEnvFunctions(t *testing.T) {
+	const setVar, unsetVar = "AUTOFORGE_TEST_CC", "AUTOFORGE_TEST_UNSET"
+
+	origValue, wasSet := os.LookupEnv(setVar)
+	defer func(origOverrides []string) {
+		flags.envOverrides = origOverrides
+		if wasSet {
+			os.Setenv(setVar, origValue)
+		} else {
+			os.Unsetenv(setVar)
+		}
+	}(flags.envOverrides)
+
+	os.Setenv(setVar, "gcc")
+	os.Unsetenv(unsetVar)
+	flags.envOverrides = nil
+
+	runTemplateTest(t, `{{Env "`+setVar+`"}}`, nil, "gcc")
+	runTemplateTest(t, `{{EnvDefault "`+setVar+`" "cc"}}`, nil, "gcc")
+	runTemplateTest(t, `[{{Env "`+unsetVar+`"}}]`, nil, "[]")
+	runTemplateTest(t, `{{EnvDefault "`+unsetVar+`" "cc"}}`, nil, "cc")
+
+	flags.envOverrides = []string{setVar + "=clang", unsetVar + "=a=b"}
+
+	runTemplateTest(t, `{{Env "`+setVar+`"}}`, nil, "clang")
+	runTemplateTest(t, `{{EnvDefault "`+unsetVar+`" "cc"}}`, nil, "a=b")
+
+	if os.Getenv(setVar) != "gcc" {
+		t.Error("--env must not change the process environment")
+	}
+
+	flags.envOverrides = []string{"=value"}
+
+	if _, err := parseAndExecuteTemplate("test",
+		[]byte(`{{Env "`+setVar+`"}}`), nil, nil,
+		[]outputFileParams{{"test", nil}}); err == nil {
+		t.Error("Malformed --env value was not reported")
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	params := templateParams{
+		"summary":     "",
+		"description": "A library",
+		"sources":     []string{},
+		"headers":     []interface{}{"a.h"},
+		"count":       0}
+
+	runTemplateTest(t, `{{Coalesce .summary .description "n/a"}}`,
+		params, "A library")
+	runTemplateTest(t, `{{Coalesce .missing .summary "n/a"}}`,
+		params, "n/a")
+	runTemplateTest(t, `{{Coalesce .sources .headers}}`,
+		params, "[a.h]")
+
+	// Numbers, including zero, are never considered empty.
+	runTemplateTest(t, `{{Coalesce .summary .count "n/a"}}`,
+		params, "0")
+
+	// If all values are empty, the last one is returned.
+	runTemplateTest(t, `[{{Coalesce .missing .summary}}]`, params, "[]")
+	runTemplateTest(t, `{{len (Coalesce .summary .sources)}}`,
+		params, "0")
+}
+
+func TestHash(t *testing.T) {
+	params := templateParams{"name": "hello", "version": "1.0"}
+
+	runTemplateTest(t, `{{Hash .name .version}}`, params,
+		hashValues("hello", "1.0"))
+
+	hash := hashValues("hello", "1.0")
+	if !regexp.MustCompile(`^[0-9a-f]{16}$`).MatchString(hash) {
+		t.Error("Unexpected hash format: " + hash)
+	}
+	if hashValues("hello", "1.0") != hash {
+		t.Error("Hash is not deterministic")
+	}
+
+	for _, other := range [][]interface{}{
+		{"hello", "1.1"}, {"hello1", ".0"}, {"hello1.0"},
+		{"hello", "1.0", ""}, {"hello", 1.0}} {
+		if hashValues(other...) == hash {
+			t.Errorf("Hash collision for %v", other)
+		}
+	}
+
+	runTemplateTest(t, `{{VarNameUC (Hash .name .version)}}`, params,
+		strings.ToUpper(hash))
+}
+
+func TestBoolParam(t *testing.T) {
+	for _, headerOnly := range []bool{true, false} {
+		fileParams := expandPathnameTemplate("{name}-{header_only}.h",
+			templateParams{"name": "net", "header_only": headerOnly})
+
+		result, err := parseAndExecuteTemplate("test",
+			[]byte(`{{if .header_only}}inline{{else}}extern{{end}}`),
+			nil, nil, fileParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectedFilename, expectedContents := "net-false.h", "extern"
+		if headerOnly {
+			expectedFilename, expectedContents = "net-true.h", "inline"
+		}
+
+		if len(result) != 1 || result[0].filename != expectedFilename ||
+			string(result[0].contents) != expectedContents {
+			t.Error("Unexpected output; expected " +
+				expectedFilename + " containing " +
+				expectedContents)
+		}
+	}
+}
+
+func TestPluralizeAndCount(t *testing.T) {
+	params := templateParams{
+		"none":    []string{},
+		"one":     []interface{}{"a.c"},
+		"many":    []string{"a.c", "b.c", "c.c"},
+		"nothing": []string(nil),
+		"targets": map[interface{}]interface{}{"lint": "true"}}
+
+	for name, expected := range map[string]string{
+		"none":    "0 files",
+		"one":     "1 file",
+		"many":    "3 files",
+		"nothing": "0 files",
+		"missing": "0 files",
+		"targets": "1 file"} {
+		runTemplateTest(t, `{{$n := Count .`+name+`}}`+
+			`{{$n}} {{Pluralize $n "file" "files"}}`,
+			params, expected)
+	}
+
+	if _, err := countItems("abc"); err == nil || err.Error() !=
+		templateErrorMarker+"Count: string is not a list" {
+		t.Error("Count must fail for a string")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	params := templateParams{"version": "1.12.0-rc1"}
+
+	runTemplateTest(t, `{{if Matches "^[0-9]+(\\.[0-9]+)*$" .version}}`+
+		`release{{else}}pre-release{{end}}`, params, "pre-release")
+	runTemplateTest(t, `{{if Matches "-rc[0-9]+$" .version}}`+
+		`rc{{end}}`, params, "rc")
+	runTemplateTest(t, `{{Matches "^2\\." .version}}`, params, "false")
+
+	_, err := parseAndExecuteTemplate("test", []byte(
+		`{{Matches "(unclosed" .version}}`), nil, nil,
+		[]outputFileParams{{"test", params}})
+	if err == nil || !strings.Contains(err.Error(),
+		templateErrorMarker+"Matches: invalid pattern '(unclosed'") {
+		t.Error("Invalid pattern was not reported")
+	}
+}
+
+func TestToJSONAndToYAML(t *testing.T) {
+	params := templateParams{
+		"deps": map[interface{}]interface{}{
+			"zlib": "1.2", "openssl": []interface{}{"1.1", "3.0"},
+			"boost": map[interface{}]interface{}{"min": 1.6}},
+		"targets": []interface{}{"build", "check", 42, true},
+	}
+
+	// Repeated runs must produce identical output.
+	for i := 0; i < 5; i++ {
+		runTemplateTest(t, `{{ToJSON .deps}}`, params,
+			`{"boost":{"min":1.6},"openssl":["1.1","3.0"],`+
+				`"zlib":"1.2"}`)
+		runTemplateTest(t, `{{ToJSON .targets}}`, params,
+			`["build","check",42,true]`)
+		runTemplateTest(t, `{{ToYAML .deps}}`, params, "boost:\n"+
+			"  min: 1.6\nopenssl:\n- \"1.1\"\n- \"3.0\"\n"+
+			"zlib: \"1.2\"")
+		runTemplateTest(t, `{{ToYAML .targets}}`, params,
+			"- build\n- check\n- 42\n- true")
+	}
+
+	_, err := parseAndExecuteTemplate("test", []byte(`{{ToJSON .ch}}`),
+		nil, nil, []outputFileParams{
+			{"test", templateParams{"ch": make(chan int)}}})
+	if err == nil || !strings.Contains(err.Error(),
+		templateErrorMarker+"ToJSON: json: unsupported type") {
+		t.Error("Marshaling error was not reported")
+	}
+}
+
+func TestSeq(t *testing.T) {
+	params := templateParams{"count": 4, "names": []string{"a", "b", "c"}}
+
+	runTemplateTest(t, `{{range Seq 0}}x{{end}}`, params, "")
+	runTemplateTest(t, `{{range Seq .count}}[{{.}}]{{end}}`,
+		params, "[0][1][2][3]")
+	runTemplateTest(t, `{{range Seq (Count .names)}}`+
+		`{{index $.names .}}{{.}} {{end}}`, params, "a0 b1 c2 ")
+	runTemplateTest(t, `{{range SeqRange 2 5}}{{.}}{{end}}`,
+		params, "234")
+	runTemplateTest(t, `{{range SeqRange 3 3}}x{{end}}`, params, "")
+
+	for text, expected := range map[string]string{
+		`{{Seq -1}}`:       "Seq: negative count -1",
+		`{{SeqRange 5 2}}`: "SeqRange: end 2 is less than start 5"} {
+		_, err := parseAndExecuteTemplate("test", []byte(text),
+			nil, nil, []outputFileParams{{"test", params}})
+		if err == nil || !strings.Contains(err.Error(),
+			templateErrorMarker+expected) {
+			t.Error("Invalid sequence was not reported: " + text)
+		}
+	}
+}
+
+func TestTitleAndCapitalize(t *testing.T) {
+	for _, testCase := range []struct {
+		arg, title, capitalized string
+	}{
+		{"", "", ""},
+		{"hello world", "Hello World", "Hello world"},
+		{"  multiple   spaces\tand\ttabs",
+			"  Multiple   Spaces\tAnd\tTabs",
+			"  Multiple   spaces\tand\ttabs"},
+		{"(hello) 'world' -x", "(Hello) 'World' -X",
+			"(Hello) 'world' -x"},
+		{"libfoo-bar 2nd edition", "Libfoo-bar 2nd Edition",
+			"Libfoo-bar 2nd edition"},
+		{"éclair über ǆungla", "Éclair Über ǅungla",
+			"Éclair über ǆungla"},
+		{"ALREADY Upper", "ALREADY Upper", "ALREADY Upper"},
+	} {
+		runTemplateFunctionTest(t, "Title", testCase.arg,
+			testCase.title)
+		runTemplateFunctionTest(t, "Capitalize", testCase.arg,
+			testCase.capitalized)
+	}
+
+	runTemplateTest(t, `{{.name | Title}}`,
+		templateParams{"name": "the foo library"}, "The Foo Library")
+}
+
+func TestLibFileName(t *testing.T) {
+	for _, testCase := range []struct {
+		platform, kind, expected string
+	}{
+		{"linux", "static", "libc++11.a"},
+		{"linux", "shared", "libc++11.so"},
+		{"darwin", "static", "libc++11.a"},
+		{"darwin", "shared", "libc++11.dylib"},
+		{"windows", "static", "c++11.lib"},
+		{"windows", "shared", "c++11.dll"},
+		{"freebsd", "static", "libc++11.a"},
+		{"openbsd", "shared", "libc++11.so"},
+		{"netbsd", "shared", "libc++11.so"},
+	} {
+		fileName, err := libFileName("c++11", testCase.kind,
+			testCase.platform)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fileName != testCase.expected {
+			t.Error("Unexpected library filename: " + fileName)
+		}
+	}
+
+	native, err := libFileName("foo", "static", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, _ := libFileName("foo", "static",
+		runtime.GOOS); native != expected {
+		t.Error("Platform does not default to " + runtime.GOOS)
+	}
+
+	runTemplateTest(t, `{{LibFileName .name "shared" "linux"}}`,
+		templateParams{"name": "my lib"}, "libmy_lib.so")
+
+	for _, text := range []string{
+		`{{LibFileName "foo" "dynamic" "linux"}}`,
+		`{{LibFileName "foo" "static" "plan9"}}`} {
+		_, err := parseAndExecuteTemplate("test", []byte(text),
+			nil, nil, []outputFileParams{
+				{"test", templateParams{}}})
+		if err == nil || !strings.Contains(err.Error(),
+			templateErrorMarker+"LibFileName: ") {
+			t.Error("Invalid arguments were not reported: " + text)
+		}
+	}
+}
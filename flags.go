@@ -12,14 +12,45 @@ import (
 )
 
 var flags = struct {
-	quiet             bool
-	pkgPath           string
-	workspaceDir      string
-	makefile          string
-	defaultMakeTarget string
-	buildDir          string
-	installDir        string
-	noBootstrap       bool
+	quiet              bool
+	pkgPath            string
+	workspaceDir       string
+	makefile           string
+	generator          string
+	defaultMakeTarget  string
+	buildDir           string
+	installDir         string
+	noBootstrap        bool
+	changed            bool
+	configureOverrides []string
+	overridePkgs       []string
+	force              bool
+	warnUnusedParams   bool
+	command            string
+	outputDir          string
+	templateName       string
+	copyrightHeader    string
+	keepGoing          bool
+	packageType        string
+	requires           []string
+	fromFile           string
+	workspaceRelative  bool
+	envOverrides       []string
+	deep               bool
+	packageDefName     string
+	excludePatterns    []string
+	fsRetries          int
+	formatCommand      string
+	formatPatterns     []string
+	bootstrapCommand   string
+	json               bool
+	sourceMode         string
+	strictTemplates    bool
+	noLink             bool
+	safe               bool
+	overwrite          bool
+	incremental        bool
+	typeFilter         string
 }{}
 
 func addQuietFlag(c *cobra.Command) {
@@ -62,6 +93,12 @@ func addMakefileFlag(c *cobra.Command) {
 		"filename of the generated makefile (default \"Makefile\")")
 }
 
+func addGeneratorFlag(c *cobra.Command) {
+	c.Flags().StringVar(&flags.generator, "generator", "",
+		"type of the top-level build file: "+
+			"'make', 'ninja', or 'cmake' (default \"make\")")
+}
+
 const maketargetOption = "maketarget"
 
 func addDefaultMakeTargetFlag(c *cobra.Command) {
@@ -79,8 +116,189 @@ func addInstallDirFlag(c *cobra.Command) {
 		"target directory for 'make install'")
 }
 
+func addCommandFlag(c *cobra.Command) {
+	c.Flags().StringVar(&flags.command, "command", "",
+		"command to invoke "+appName+" in the generated makefile "+
+			"(default is the pathname of the running executable)")
+}
+
+func addCopyrightHeaderFlag(c *cobra.Command) {
+	c.Flags().StringVar(&flags.copyrightHeader, "copyright-header", "",
+		"template of the text returned by the CopyrightHeader "+
+			"template function")
+}
+
 func addNoBootstrapFlag(c *cobra.Command) {
 	c.Flags().BoolVarP(&flags.noBootstrap, "nobootstrap", "", false,
 		"do not bootstrap packages ("+conftabFilename+
 			" will not be updated)")
 }
+
+func addChangedFlag(c *cobra.Command) {
+	c.Flags().BoolVar(&flags.changed, "changed", false,
+		"build only the packages that changed since the last "+
+			"successful build and the packages that depend on them")
+}
+
+func addConfigureOverrideFlags(c *cobra.Command) {
+	c.Flags().StringArrayVar(&flags.configureOverrides, "with", nil,
+		"pass '--KEY=VALUE' to configure, overriding the conftab "+
+			"(can be repeated)")
+	c.Flags().StringArrayVar(&flags.overridePkgs, "pkg", nil,
+		"apply the --with overrides only to this package "+
+			"(can be repeated)")
+}
+
+func addForceFlag(c *cobra.Command) {
+	c.Flags().BoolVarP(&flags.force, "force", "f", false,
+		"rewrite all generated files and re-create symlinks "+
+			"even if they are up to date")
+}
+
+func addKeepGoingFlag(c *cobra.Command) {
+	c.Flags().BoolVarP(&flags.keepGoing, "keep-going", "k", false,
+		"make the generated makefile continue building other "+
+			"packages after a package fails")
+}
+
+func addWorkspaceRelativeFlag(c *cobra.Command) {
+	c.Flags().BoolVar(&flags.workspaceRelative, "workspace-relative",
+		false, "express all pathnames in the generated makefile "+
+			"relative to the workspace directory")
+}
+
+func addReinitFlag(c *cobra.Command) {
+	c.Flags().BoolVarP(&flags.force, "force", "f", false,
+		"reinitialize an existing workspace")
+}
+
+func addWarnUnusedParamsFlag(c *cobra.Command) {
+	c.Flags().BoolVar(&flags.warnUnusedParams, "warn-unused-params", false,
+		"warn about package parameters that no template refers to")
+}
+
+func addOutputDirFlag(c *cobra.Command) {
+	c.Flags().StringVarP(&flags.outputDir, "output", "C", ".",
+		"directory where to generate the package files")
+}
+
+func addTemplateFlag(c *cobra.Command) {
+	c.Flags().StringVar(&flags.templateName, "template", "",
+		"built-in template name ('app' or 'lib') or pathname of "+
+			"a template directory (default: the package type)")
+}
+
+func addPackageTypeFlag(c *cobra.Command) {
+	c.Flags().StringVar(&flags.packageType, "type", "application",
+		"type of the new package ('application' or 'library')")
+}
+
+func addTypeFilterFlag(c *cobra.Command) {
+	c.Flags().StringVar(&flags.typeFilter, "type", "",
+		"select only the packages of this type "+
+			"('application' or 'library')")
+}
+
+func addRequiresFlag(c *cobra.Command) {
+	c.Flags().StringSliceVar(&flags.requires, "requires", nil,
+		"comma-separated list of packages the new package requires")
+}
+
+func addFromFileFlag(c *cobra.Command) {
+	c.Flags().StringVar(&flags.fromFile, "from-file", "",
+		"read package ranges from a file (before the ones "+
+			"given on the command line)")
+}
+
+func addEnvFlag(c *cobra.Command) {
+	c.Flags().StringArrayVar(&flags.envOverrides, "env", nil,
+		"set KEY=VALUE for the Env and EnvDefault template "+
+			"functions without changing the environment "+
+			"(can be repeated)")
+}
+
+func addDeepFlag(c *cobra.Command) {
+	c.Flags().BoolVar(&flags.deep, "deep", false,
+		"also remove stale links to package sources")
+}
+
+func addPackageDefNameFlag(c *cobra.Command) {
+	c.Flags().StringVar(&flags.packageDefName, "package-def-name", "",
+		"file name of package definitions (default \""+
+			packageDefinitionFilename+"\")")
+}
+
+func addExcludePatternFlag(c *cobra.Command) {
+	c.Flags().StringArrayVar(&flags.excludePatterns, "exclude-pattern", nil,
+		"skip source and template files and directories that "+
+			"match the glob pattern (can be repeated)")
+}
+
+func addFsRetriesFlag(c *cobra.Command) {
+	c.Flags().IntVar(&flags.fsRetries, "fs-retries", 3,
+		"number of times to retry a filesystem write that "+
+			"fails with a transient error")
+}
+
+func addFormatCommandFlag(c *cobra.Command) {
+	c.Flags().StringVar(&flags.formatCommand, "format-command", "",
+		"command that the 'format' target runs on package "+
+			"sources (default \""+defaultFormatCommand+"\")")
+}
+
+func addFormatPatternsFlag(c *cobra.Command) {
+	c.Flags().StringSliceVar(&flags.formatPatterns, "format-patterns", nil,
+		"comma-separated list of file name patterns of the sources "+
+			"that the 'format' and 'lint' targets process "+
+			"(default \""+
+			strings.Join(defaultSourcePatterns, ",")+"\")")
+}
+
+func addBootstrapCommandFlag(c *cobra.Command) {
+	c.Flags().StringVar(&flags.bootstrapCommand, "bootstrap-command", "",
+		"command that creates the 'configure' script of packages "+
+			"that do not set 'bootstrap_command' (default \""+
+			defaultBootstrapCommand+"\")")
+}
+
+func addJSONFlag(c *cobra.Command) {
+	c.Flags().BoolVar(&flags.json, "json", false,
+		"print the output in JSON format")
+}
+
+func addSourceModeFlag(c *cobra.Command) {
+	c.Flags().StringVar(&flags.sourceMode, "source-mode", "",
+		"how package sources are placed in the generated package "+
+			"directory: 'symlink' or 'copy' (default \"symlink\")")
+}
+
+func addStrictTemplatesFlag(c *cobra.Command) {
+	c.Flags().BoolVar(&flags.strictTemplates, "strict-templates", false,
+		"fail if a project template references a parameter that "+
+			"is not defined")
+}
+
+func addNoLinkFlag(c *cobra.Command) {
+	c.Flags().BoolVar(&flags.noLink, "no-link", false,
+		"do not link or copy the package sources into the output "+
+			"directory; only generate the build files")
+}
+
+func addSafeFlag(c *cobra.Command) {
+	c.Flags().BoolVar(&flags.safe, "safe", false,
+		"refuse to overwrite existing files that are not in the "+
+			"manifest and do not contain the marker returned by "+
+			"GeneratedFileMarker")
+}
+
+func addOverwriteFlag(c *cobra.Command) {
+	c.Flags().BoolVar(&flags.overwrite, "overwrite", false,
+		"overwrite existing files that were not generated "+
+			"instead of leaving them intact")
+}
+
+func addIncrementalFlag(c *cobra.Command) {
+	c.Flags().BoolVar(&flags.incremental, "incremental", false,
+		"regenerate only the packages that changed since the last "+
+			"run and the packages that depend on them")
+}
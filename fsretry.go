@@ -0,0 +1,80 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// fileOps contains the mutating filesystem operations used during
+// package generation. Tests replace them to simulate failures.
+var fileOps = struct {
+	symlink   func(oldname, newname string) error
+	mkdirAll  func(pathname string, perm os.FileMode) error
+	writeFile func(filename string, data []byte, perm os.FileMode) error
+}{os.Symlink, os.MkdirAll, writeFileAtomically}
+
+// retryInitialDelay is the delay before the first retry. Each
+// subsequent retry waits twice as long as the previous one.
+var retryInitialDelay = 50 * time.Millisecond
+
+// retryMaxDelay caps the delay between retries.
+var retryMaxDelay = 2 * time.Second
+
+// isTransientError returns true if the error is caused by a condition
+// that can go away by itself, as it happens on networked filesystems.
+func isTransientError(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+
+	switch errno {
+	case syscall.EAGAIN, syscall.ETXTBSY, syscall.EBUSY, syscall.EINTR:
+		return true
+	}
+	return false
+}
+
+// withRetries calls 'op' until it succeeds, fails with a non-transient
+// error, or the number of retries set by --fs-retries is exhausted.
+func withRetries(op func() error) error {
+	delay := retryInitialDelay
+
+	for retries := 0; ; retries++ {
+		err := op()
+		if err == nil || retries >= flags.fsRetries ||
+			!isTransientError(err) {
+			return err
+		}
+
+		time.Sleep(delay)
+		if delay *= 2; delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+	}
+}
+
+func symlinkWithRetries(oldname, newname string) error {
+	return withRetries(func() error {
+		return fileOps.symlink(oldname, newname)
+	})
+}
+
+func mkdirAllWithRetries(pathname string, perm os.FileMode) error {
+	return withRetries(func() error {
+		return fileOps.mkdirAll(pathname, perm)
+	})
+}
+
+func writeFileWithRetries(filename string, data []byte,
+	perm os.FileMode) error {
+	return withRetries(func() error {
+		return fileOps.writeFile(filename, data, perm)
+	})
+}
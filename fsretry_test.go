@@ -0,0 +1,124 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+// makeFlakyFileOpsForTesting replaces the filesystem operations with
+// ones that fail with 'errno' the first 'failures' times they are
+// called. It returns a pointer to the number of calls made and
+// a function that restores the original operations.
+func makeFlakyFileOpsForTesting(failures int,
+	errno syscall.Errno) (*int, func()) {
+	origFileOps := fileOps
+	origDelay := retryInitialDelay
+	retryInitialDelay = 0
+
+	calls := 0
+	fail := func(op, pathname string) error {
+		calls++
+		if calls <= failures {
+			return &os.PathError{Op: op, Path: pathname, Err: errno}
+		}
+		return nil
+	}
+
+	fileOps.symlink = func(oldname, newname string) error {
+		if err := fail("symlink", newname); err != nil {
+			return err
+		}
+		return origFileOps.symlink(oldname, newname)
+	}
+	fileOps.mkdirAll = func(pathname string, perm os.FileMode) error {
+		if err := fail("mkdir", pathname); err != nil {
+			return err
+		}
+		return origFileOps.mkdirAll(pathname, perm)
+	}
+	fileOps.writeFile = func(filename string, data []byte,
+		perm os.FileMode) error {
+		if err := fail("open", filename); err != nil {
+			return err
+		}
+		return origFileOps.writeFile(filename, data, perm)
+	}
+
+	return &calls, func() {
+		fileOps = origFileOps
+		retryInitialDelay = origDelay
+	}
+}
+
+func TestFileOpRetries(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "retry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	defer func(origFsRetries int) {
+		flags.fsRetries = origFsRetries
+	}(flags.fsRetries)
+	flags.fsRetries = 3
+
+	for i, testCase := range []struct {
+		failures      int
+		errno         syscall.Errno
+		expectedCalls int
+		expectSuccess bool
+	}{
+		{0, syscall.EAGAIN, 1, true},
+		{2, syscall.EAGAIN, 3, true},
+		{3, syscall.ETXTBSY, 4, true},
+		{4, syscall.EAGAIN, 4, false},
+		{1, syscall.EACCES, 1, false},
+	} {
+		testName := "case " + strconv.Itoa(i)
+
+		for _, op := range []func(string) error{
+			func(pathname string) error {
+				return symlinkWithRetries("target", pathname)
+			},
+			func(pathname string) error {
+				return mkdirAllWithRetries(pathname, 0755)
+			},
+			func(pathname string) error {
+				return writeFileWithRetries(pathname,
+					[]byte("data"), 0644)
+			},
+		} {
+			pathname := path.Join(tempDir, "file")
+
+			calls, restore := makeFlakyFileOpsForTesting(
+				testCase.failures, testCase.errno)
+			err := op(pathname)
+			restore()
+
+			if *calls != testCase.expectedCalls {
+				t.Error(testName + ": unexpected number " +
+					"of calls: " + strconv.Itoa(*calls))
+			}
+			if testCase.expectSuccess {
+				if err != nil {
+					t.Error(testName + ": " + err.Error())
+				} else if _, err = os.Lstat(
+					pathname); err != nil {
+					t.Error(testName + ": " + err.Error())
+				}
+			} else if err == nil {
+				t.Error(testName + ": error expected")
+			}
+
+			os.RemoveAll(pathname)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// generatePackage generates the build files for the package
+// defined in 'pathname' in the output directory using either
+// a built-in template or a template directory.
+func generatePackage(pathname, outputDir, templateName string) error {
+	// Source files are linked using absolute pathnames.
+	pathname, err := filepath.Abs(pathname)
+	if err != nil {
+		return err
+	}
+
+	pd, _, err := loadPackageDefinition(pathname)
+	if err != nil {
+		return err
+	}
+
+	if templateName == "" {
+		templateName = pd.packageType
+	}
+
+	outputDir, err = filepath.Abs(outputDir)
+	if err != nil {
+		return err
+	}
+
+	// The manifest of the generated files is kept
+	// in the private directory of the output directory.
+	privateDir := getPrivateDir(outputDir)
+
+	changeStats.reset()
+
+	if err = manifest.load(privateDir); err != nil {
+		return err
+	}
+
+	// The output directory may contain files that the user owns.
+	manifest.keepUntrackedFiles(!flags.overwrite)
+
+	if t := getEmbeddedTemplate(templateName); t != nil {
+		_, err = generateBuildFilesFromEmbeddedTemplate(t,
+			outputDir, pd, nil)
+		if err != nil {
+			return err
+		}
+	} else {
+		if fileInfo, err := os.Stat(templateName); err != nil {
+			if !os.IsNotExist(err) {
+				return err
+			}
+			return errors.New("unknown template '" +
+				templateName + "'")
+		} else if !fileInfo.IsDir() {
+			return errors.New(templateName + ": not a directory")
+		}
+
+		_, err = generateBuildFilesFromProjectTemplate(templateName,
+			outputDir, pd, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = manifest.save(privateDir); err != nil {
+		return err
+	}
+
+	printChangeSummary()
+	return nil
+}
+
+// genCmd represents the gen command
+var genCmd = &cobra.Command{
+	Use:   "gen [flags] package_definition",
+	Short: "Generate build files for a single package",
+	Long: wrapText("The 'gen' command generates Autotools " +
+		"build files for the package defined in the specified " +
+		"file outside of any workspace. Source files of the " +
+		"package are linked into the output directory unless " +
+		"--no-link is given. Existing files that were not " +
+		"generated are left intact with a warning unless " +
+		"--overwrite is given. With --safe, such files cause " +
+		"an error instead."),
+	Args: cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		if err := generatePackage(args[0], flags.outputDir,
+			flags.templateName); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(genCmd)
+
+	genCmd.Flags().SortFlags = false
+	addOutputDirFlag(genCmd)
+	addTemplateFlag(genCmd)
+	addEnvFlag(genCmd)
+	addExcludePatternFlag(genCmd)
+	addFsRetriesFlag(genCmd)
+	addSourceModeFlag(genCmd)
+	addNoLinkFlag(genCmd)
+	addStrictTemplatesFlag(genCmd)
+	addSafeFlag(genCmd)
+	addOverwriteFlag(genCmd)
+}
@@ -0,0 +1,313 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// listFilesForTesting returns a comma-separated list of
+// relative pathnames of all files in the directory.
+func listFilesForTesting(t *testing.T, dir string) string {
+	var files []string
+
+	err := processAllFiles(dir, func(_, relativePathname string,
+		_ os.FileInfo) error {
+		files = append(files, relativePathname)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Join(files, ", ")
+}
+
+func TestGeneratePackage(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	sourceDir := path.Join(tempDir, "hello")
+	definitionPathname := path.Join(sourceDir, packageDefinitionFilename)
+
+	writeFileForTesting(t, definitionPathname, `name: hello
+type: app
+description: Test application
+version: "1.0"
+`)
+	writeFileForTesting(t, path.Join(sourceDir, "src", "main.cc"),
+		"int main() {}\n")
+
+	outputDir := path.Join(tempDir, "output")
+
+	if err = generatePackage(definitionPathname, outputDir,
+		""); err != nil {
+		t.Fatal(err)
+	}
+
+	files := listFilesForTesting(t, outputDir)
+	expected := "INSTALL, Makefile.am, autogen.sh, configure.ac, " +
+		"src/Makefile.am, src/main.cc"
+	if files != expected {
+		t.Error("Unexpected file set: " + files +
+			"; expected: " + expected)
+	}
+
+	// Generate from a template directory.
+	templateDir := path.Join(tempDir, "template")
+	writeFileForTesting(t, path.Join(templateDir, "README.{name}"),
+		"{{.description}}\n")
+
+	outputDir = path.Join(tempDir, "custom")
+
+	if err = generatePackage(definitionPathname, outputDir,
+		templateDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if files = listFilesForTesting(t,
+		outputDir); files != "README.hello, src/main.cc" {
+		t.Error("Unexpected file set: " + files)
+	}
+
+	if err = generatePackage(definitionPathname, outputDir,
+		path.Join(tempDir, "nonexistent")); err == nil {
+		t.Error("Nonexistent template must be reported")
+	}
+}
+
+func TestGenerateWithoutLinks(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "nolink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	sourceDir := path.Join(tempDir, "hello")
+	definitionPathname := path.Join(sourceDir, packageDefinitionFilename)
+
+	writeFileForTesting(t, definitionPathname, `name: hello
+type: app
+description: Test application
+version: "1.0"
+`)
+	writeFileForTesting(t, path.Join(sourceDir, "src", "main.cc"),
+		"int main() {}\n")
+	writeFileForTesting(t, path.Join(sourceDir, "src", "util.cc"), "\n")
+
+	templateDir := path.Join(tempDir, "template")
+	writeFileForTesting(t, path.Join(templateDir, "src", "Makefile.am"),
+		`hello_SOURCES ={{range Dir "src"}} {{.}}{{end}}`+"\n")
+
+	defer func(origNoLink bool) {
+		flags.noLink = origNoLink
+	}(flags.noLink)
+	flags.noLink = true
+
+	for _, outputDir := range []string{
+		path.Join(tempDir, "output"), sourceDir} {
+		if err = generatePackage(definitionPathname, outputDir,
+			templateDir); err != nil {
+			t.Fatal(err)
+		}
+
+		contents, err := ioutil.ReadFile(path.Join(outputDir,
+			"src", "Makefile.am"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(contents) != "hello_SOURCES = main.cc util.cc\n" {
+			t.Error("Unexpected file list: " + string(contents))
+		}
+
+		err = processAllFiles(outputDir, func(pathname, _ string,
+			info os.FileInfo) error {
+			if info.Mode()&os.ModeSymlink != 0 {
+				t.Error("Unexpected symbolic link: " + pathname)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if files := listFilesForTesting(t, path.Join(tempDir,
+		"output")); files != "src/Makefile.am" {
+		t.Error("Unexpected file set: " + files)
+	}
+}
+
+func TestSafeMode(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "safe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	sourceDir := path.Join(tempDir, "hello")
+	definitionPathname := path.Join(sourceDir, packageDefinitionFilename)
+
+	writeFileForTesting(t, definitionPathname, `name: hello
+type: app
+description: Test application
+version: "1.0"
+`)
+
+	templateDir := path.Join(tempDir, "template")
+	writeFileForTesting(t, path.Join(templateDir, "Makefile.am"),
+		"{{GeneratedFileMarker | Comment}}SUBDIRS = src\n")
+	writeFileForTesting(t, path.Join(templateDir, "README"),
+		"{{.name}}\n")
+
+	defer func(origSafe bool) {
+		flags.safe = origSafe
+	}(flags.safe)
+
+	outputDir := path.Join(tempDir, "output")
+	makefilePathname := path.Join(outputDir, "Makefile.am")
+	readmePathname := path.Join(outputDir, "README")
+
+	checkContents := func(pathname, expected string) {
+		contents, err := ioutil.ReadFile(pathname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(contents) != expected {
+			t.Error("Unexpected contents of " + pathname + ": " +
+				string(contents))
+		}
+	}
+
+	writeFileForTesting(t, readmePathname, "Hand-written\n")
+	writeFileForTesting(t, makefilePathname, "# "+generatedFileMarker+
+		"\nSUBDIRS = lib\n")
+
+	flags.safe = true
+	err = generatePackage(definitionPathname, outputDir, templateDir)
+	if err == nil || !strings.Contains(err.Error(), "README: "+
+		"not a generated file") {
+		t.Error("Overwriting a hand-written file was not prevented")
+	}
+	checkContents(readmePathname, "Hand-written\n")
+
+	// Files with the marker are still updated.
+	os.Remove(readmePathname)
+	if err = generatePackage(definitionPathname, outputDir,
+		templateDir); err != nil {
+		t.Fatal(err)
+	}
+	checkContents(makefilePathname,
+		"# "+generatedFileMarker+"\n#\nSUBDIRS = src\n")
+	checkContents(readmePathname, "hello\n")
+
+	writeFileForTesting(t, readmePathname, "Hand-written\n")
+
+	flags.safe = false
+	if err = generatePackage(definitionPathname, outputDir,
+		templateDir); err != nil {
+		t.Fatal(err)
+	}
+	checkContents(readmePathname, "hello\n")
+}
+
+func TestGenerateIntoPopulatedDir(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "populated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	definitionPathname := path.Join(tempDir, "hello",
+		packageDefinitionFilename)
+	writeFileForTesting(t, definitionPathname, `name: hello
+type: app
+description: Test application
+version: "1.0"
+`)
+
+	templateDir := path.Join(tempDir, "template")
+	writeFileForTesting(t, path.Join(templateDir, "configure.ac"),
+		"AC_INIT([{{.name}}], [{{.version}}])\n")
+	writeFileForTesting(t, path.Join(templateDir, "Makefile.am"),
+		"SUBDIRS = src\n")
+
+	outputDir := path.Join(tempDir, "project")
+	configureAc := path.Join(outputDir, "configure.ac")
+	makefileAm := path.Join(outputDir, "Makefile.am")
+	readme := path.Join(outputDir, "README")
+
+	checkContents := func(pathname, expected string) {
+		contents, err := ioutil.ReadFile(pathname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(contents) != expected {
+			t.Error("Unexpected contents of " + pathname + ": " +
+				string(contents))
+		}
+	}
+
+	defer func(origOverwrite bool) {
+		flags.overwrite = origOverwrite
+	}(flags.overwrite)
+	flags.overwrite = false
+
+	generate := func() {
+		if err := generatePackage(definitionPathname, outputDir,
+			templateDir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A tree generated before the manifest was introduced
+	// contains neither the manifest nor the marker. All its
+	// files are considered generated.
+	writeFileForTesting(t, configureAc, "AC_INIT([hello], [0.9])\n")
+	writeFileForTesting(t, makefileAm, "SUBDIRS = src\n")
+
+	generate()
+
+	checkContents(configureAc, "AC_INIT([hello], [1.0])\n")
+	checkContents(makefileAm, "SUBDIRS = src\n")
+
+	// Once the manifest exists, a file that the user
+	// created is left intact and is not adopted as
+	// a generated one.
+	writeFileForTesting(t, readme, "User notes\n")
+	writeFileForTesting(t, path.Join(templateDir, "README"),
+		"{{.name}}\n")
+
+	for _, overwrite := range []bool{false, true} {
+		flags.overwrite = overwrite
+
+		generate()
+
+		if overwrite {
+			checkContents(readme, "hello\n")
+		} else {
+			checkContents(readme, "User notes\n")
+			checkContents(path.Join(getPrivateDir(outputDir),
+				manifestFilename),
+				"Makefile.am\nconfigure.ac\n")
+		}
+	}
+
+	// Once generated, the file is no longer protected.
+	flags.overwrite = false
+	writeFileForTesting(t, path.Join(templateDir, "README"),
+		"{{.name}} {{.version}}\n")
+
+	generate()
+
+	checkContents(readme, "hello 1.0\n")
+}
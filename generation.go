@@ -38,10 +38,11 @@ func createConfigureHelpParser() configureHelpParser {
 		}}
 }
 
-func (helpParser *configureHelpParser) parseOptions(packageDir string) (
-	[]optDescription, error) {
-	configureHelpCmd := exec.Command("./configure", "--help")
-	configureHelpCmd.Dir = packageDir
+func (helpParser *configureHelpParser) parseOptions(
+	configurePathname string) ([]optDescription, error) {
+	configureHelpCmd := exec.Command("./"+path.Base(configurePathname),
+		"--help")
+	configureHelpCmd.Dir = path.Dir(configurePathname)
 	configureHelpStdout, err := configureHelpCmd.StdoutPipe()
 	if err != nil {
 		return nil, err
@@ -114,6 +115,32 @@ func generateAndBootstrapPackages(ws *workspace, pi *packageIndex,
 	selection packageDefinitionList, conftab *Conftab) error {
 	pkgRootDir := ws.generatedPkgRootDir()
 
+	changeStats.reset()
+
+	if err := manifest.load(ws.absPrivateDir); err != nil {
+		return err
+	}
+
+	current, err := computePackageHashes(ws, selection)
+	if err != nil {
+		return err
+	}
+
+	// In incremental mode, only the packages whose inputs have
+	// changed since the last generation are regenerated.
+	var outdated map[string]bool
+	if flags.incremental && !flags.force {
+		previous, err := readGenerationState(ws.absPrivateDir)
+		if err != nil {
+			return err
+		}
+		outdated, err = outdatedPackages(pi, selection, previous,
+			current, pkgRootDir)
+		if err != nil {
+			return err
+		}
+	}
+
 	type packageAndGenerator struct {
 		pd         *packageDefinition
 		packageDir string
@@ -125,7 +152,7 @@ func generateAndBootstrapPackages(ws *workspace, pi *packageIndex,
 	for _, pd := range selection {
 		packageDir := path.Join(pkgRootDir, pd.PackageName)
 
-		generator, err := pd.getPackageGeneratorFunc(packageDir)
+		generator, err := pd.getPackageGeneratorFunc(packageDir, pi)
 		if err != nil {
 			return err
 		}
@@ -138,12 +165,18 @@ func generateAndBootstrapPackages(ws *workspace, pi *packageIndex,
 
 	// Generate autoconf and automake sources for the selected packages.
 	for _, pg := range packagesAndGenerators {
-		changed, err := pg.generator()
-		if err != nil {
-			return err
+		changed := false
+
+		if outdated == nil || outdated[pg.pd.PackageName] {
+			changed, err = pg.generator()
+			if err != nil {
+				return err
+			}
+		} else {
+			manifest.retain(pg.packageDir)
 		}
 
-		_, err = os.Stat(path.Join(pg.packageDir, "configure"))
+		_, err = os.Stat(pg.pd.configurePathname(pg.packageDir))
 
 		if changed || os.IsNotExist(err) {
 			packagesToBootstrap = append(packagesToBootstrap, pg)
@@ -153,7 +186,7 @@ func generateAndBootstrapPackages(ws *workspace, pi *packageIndex,
 	if !flags.noBootstrap {
 		// Bootstrap the selected packages.
 		for _, pg := range packagesToBootstrap {
-			err := bootstrapPackage(pg.packageDir, pg.pd)
+			err := bootstrapPackage(ws, pg.packageDir, pg.pd)
 			if err != nil {
 				return err
 			}
@@ -162,7 +195,8 @@ func generateAndBootstrapPackages(ws *workspace, pi *packageIndex,
 		helpParser := createConfigureHelpParser()
 
 		for _, pg := range packagesAndGenerators {
-			options, err := helpParser.parseOptions(pg.packageDir)
+			options, err := helpParser.parseOptions(
+				pg.pd.configurePathname(pg.packageDir))
 			if err != nil {
 				return err
 			}
@@ -176,5 +210,20 @@ func generateAndBootstrapPackages(ws *workspace, pi *packageIndex,
 		}
 	}
 
-	return generateWorkspaceFiles(ws, pi, selection, conftab)
+	err = generateWorkspaceFiles(ws, pi, selection, conftab)
+	if err != nil {
+		return err
+	}
+
+	if err = manifest.save(ws.absPrivateDir); err != nil {
+		return err
+	}
+
+	if err = writeGenerationState(ws.absPrivateDir, current); err != nil {
+		return err
+	}
+
+	printChangeSummary()
+
+	return nil
 }
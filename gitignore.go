@@ -0,0 +1,87 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gitignoreEntries returns the patterns that exclude the files
+// generated by autoforge from version control: the private directory,
+// the build directory if it is elsewhere in the workspace, and the
+// top-level build file.
+func gitignoreEntries(ws *workspace, makefile string) []string {
+	entries := []string{"/" + privateDirName + "/"}
+
+	buildDir, err := filepath.Rel(ws.absDir, ws.buildDir())
+	if err == nil && buildDir != "." && buildDir != ".." &&
+		!strings.HasPrefix(buildDir, "../") &&
+		!strings.HasPrefix(buildDir, privateDirName+"/") {
+		entries = append(entries, "/"+buildDir+"/")
+	}
+
+	if !filepath.IsAbs(makefile) {
+		entries = append(entries, "/"+path.Clean(makefile))
+	}
+
+	return entries
+}
+
+// normalizeGitignoreEntry strips the slashes that do not
+// change which files in the workspace root the entry matches.
+func normalizeGitignoreEntry(entry string) string {
+	return strings.Trim(strings.TrimSpace(entry), "/")
+}
+
+// updateGitignore creates the .gitignore file in the workspace
+// directory or appends the entries that it lacks. Existing lines
+// are never changed.
+func updateGitignore(workspaceDir string, entries []string) error {
+	workspaceDir, err := relativeToCwd(workspaceDir)
+	if err != nil {
+		return err
+	}
+
+	pathname := path.Join(workspaceDir, ".gitignore")
+
+	mode := "U"
+
+	contents, err := ioutil.ReadFile(pathname)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		mode = "A"
+	}
+
+	present := make(map[string]bool)
+	for _, line := range strings.Split(string(contents), "\n") {
+		present[normalizeGitignoreEntry(line)] = true
+	}
+
+	var missing []string
+	for _, entry := range entries {
+		if !present[normalizeGitignoreEntry(entry)] {
+			missing = append(missing, entry)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if len(contents) > 0 && contents[len(contents)-1] != '\n' {
+		contents = append(contents, '\n')
+	}
+	contents = append(contents, strings.Join(missing, "\n")+"\n"...)
+
+	reportFileChange(mode, pathname)
+
+	return writeFileWithRetries(pathname, contents, 0644)
+}
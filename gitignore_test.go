@@ -0,0 +1,81 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestGitignoreEntries(t *testing.T) {
+	ws := makeWorkspaceForTesting("/ws")
+
+	if entries := strings.Join(gitignoreEntries(ws, "Makefile"),
+		" "); entries != "/.autoforge/ /Makefile" {
+		t.Error("Unexpected default entries: " + entries)
+	}
+
+	ws.wp.BuildDir = "/ws/out/build"
+	if entries := strings.Join(gitignoreEntries(ws, "build.ninja"),
+		" "); entries != "/.autoforge/ /out/build/ /build.ninja" {
+		t.Error("Unexpected entries: " + entries)
+	}
+
+	ws.wp.BuildDir = "/tmp/build"
+	if entries := strings.Join(gitignoreEntries(ws, "/tmp/Makefile"),
+		" "); entries != "/.autoforge/" {
+		t.Error("Entries outside the workspace: " + entries)
+	}
+}
+
+func readGitignoreForTesting(t *testing.T, workspaceDir string) string {
+	contents, err := ioutil.ReadFile(path.Join(workspaceDir, ".gitignore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(contents)
+}
+
+func TestUpdateGitignore(t *testing.T) {
+	workspaceDir, err := ioutil.TempDir("", "gitignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(workspaceDir)
+
+	entries := []string{"/.autoforge/", "/build/", "/Makefile"}
+
+	// A new file is created with all entries.
+	if err = updateGitignore(workspaceDir, entries); err != nil {
+		t.Fatal(err)
+	}
+	if contents := readGitignoreForTesting(t, workspaceDir); contents !=
+		"/.autoforge/\n/build/\n/Makefile\n" {
+		t.Error("Unexpected contents of a new file: " + contents)
+	}
+
+	// Entries that are already present are not repeated.
+	if err = updateGitignore(workspaceDir, entries); err != nil {
+		t.Fatal(err)
+	}
+	if contents := readGitignoreForTesting(t, workspaceDir); contents !=
+		"/.autoforge/\n/build/\n/Makefile\n" {
+		t.Error("Entries were duplicated: " + contents)
+	}
+
+	// User edits are preserved and only missing entries are added.
+	writeFileForTesting(t, path.Join(workspaceDir, ".gitignore"),
+		"# Editor files\n*.swp\nbuild\n.autoforge")
+	if err = updateGitignore(workspaceDir, entries); err != nil {
+		t.Fatal(err)
+	}
+	if contents := readGitignoreForTesting(t, workspaceDir); contents !=
+		"# Editor files\n*.swp\nbuild\n.autoforge\n/Makefile\n" {
+		t.Error("Unexpected contents after merge: " + contents)
+	}
+}
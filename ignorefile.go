@@ -0,0 +1,140 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ignoreFilename is the name of the file in the package source
+// directory that lists the files that must not be linked into
+// the generated package directory.
+var ignoreFilename = "." + appName + "ignore"
+
+// ignorePattern is a single compiled line of an ignore file.
+type ignorePattern struct {
+	re      *regexp.Regexp
+	negated bool
+	dirOnly bool
+}
+
+// ignorePatterns holds the patterns of an ignore file in the
+// order in which they appear in the file.
+type ignorePatterns []ignorePattern
+
+// compileIgnorePattern converts a gitignore-style pattern into a
+// regular expression that matches relative pathnames. A pattern
+// without a slash (other than a trailing one) matches at any depth.
+func compileIgnorePattern(pattern string) (ignorePattern, error) {
+	var result ignorePattern
+
+	if strings.HasPrefix(pattern, "!") {
+		result.negated = true
+		pattern = pattern[1:]
+	}
+
+	if strings.HasSuffix(pattern, "/") {
+		result.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var expr string
+	if !anchored {
+		expr = "(.*/)?"
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr += "(.*/)?"
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr += ".*"
+			i++
+		case pattern[i] == '*':
+			expr += "[^/]*"
+		case pattern[i] == '?':
+			expr += "[^/]"
+		default:
+			expr += regexp.QuoteMeta(pattern[i : i+1])
+		}
+	}
+
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return result, err
+	}
+	result.re = re
+
+	return result, nil
+}
+
+// readIgnoreFile reads the ignore file in the specified directory.
+// The absence of the file is not an error.
+func readIgnoreFile(dir string) (ignorePatterns, error) {
+	pathname := path.Join(dir, ignoreFilename)
+
+	file, err := os.Open(pathname)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns ignorePatterns
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		pattern, err := compileIgnorePattern(line)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns, scanner.Err()
+}
+
+// ignored returns true if the file with the specified relative
+// pathname is excluded by the patterns. A pattern that matches a
+// directory applies to all files in it. When several patterns
+// match, the last one wins, so a negated pattern can re-include
+// a file from an excluded directory.
+func (patterns ignorePatterns) ignored(relativePathname string) bool {
+	components := strings.Split(relativePathname, "/")
+
+	ignored := false
+
+	for _, pattern := range patterns {
+		for i := range components {
+			isDir := i < len(components)-1
+			if pattern.dirOnly && !isDir {
+				continue
+			}
+			if pattern.re.MatchString(
+				strings.Join(components[:i+1], "/")) {
+				ignored = !pattern.negated
+				break
+			}
+		}
+	}
+
+	return ignored
+}
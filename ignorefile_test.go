@@ -0,0 +1,83 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestIgnorePatterns(t *testing.T) {
+	var patterns ignorePatterns
+
+	for _, line := range []string{"*.log", "/build", "data/**/*.bin",
+		"tmp/", "!important.log"} {
+		pattern, err := compileIgnorePattern(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	for pathname, expected := range map[string]bool{
+		"run.log":             true,
+		"sub/dir/run.log":     true,
+		"important.log":       false,
+		"sub/important.log":   false,
+		"build":               true,
+		"build/out.o":         true,
+		"src/build":           false,
+		"data/a.bin":          true,
+		"data/x/y/a.bin":      true,
+		"data/a.txt":          false,
+		"tmp":                 false,
+		"tmp/scratch.c":       true,
+		"src/tmp/scratch.c":   true,
+		"src/main.c":          false,
+		"src/main.c.log.keep": false,
+	} {
+		if patterns.ignored(pathname) != expected {
+			t.Error("Unexpected result for " + pathname)
+		}
+	}
+}
+
+func TestIgnoreFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "ignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	sourceDir := path.Join(tempDir, "src")
+	projectDir := path.Join(tempDir, "project")
+
+	writeFileForTesting(t, path.Join(sourceDir, ignoreFilename),
+		"# Documentation is not needed for building.\n"+
+			"docs/\n!docs/api.h\n")
+	for _, pathname := range []string{"main.c", "docs/manual.txt",
+		"docs/api.h", "docs/images/logo.png"} {
+		writeFileForTesting(t, path.Join(sourceDir, pathname), "")
+	}
+
+	pd := &packageDefinition{
+		PackageName: "a",
+		pathname:    path.Join(sourceDir, packageDefinitionFilename)}
+
+	dirTree, _, err := linkFilesFromSourceDir(pd, projectDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := listFilesForTesting(t, projectDir); files !=
+		"docs/api.h, main.c" {
+		t.Error("Unexpected file set: " + files)
+	}
+	if dirTree.hasFile("docs/manual.txt") || !dirTree.hasFile("docs/api.h") {
+		t.Error("Ignored files must not be in the directory tree")
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"text/template/parse"
+)
+
+// generationStateFilename is the name of the file in the private
+// directory that records the input hashes of the packages as of
+// the last generation. The file has the same format as the build
+// state file.
+var generationStateFilename = "genstate"
+
+// volatileTemplateFuncs lists the template functions that return
+// values that the package input hashes do not cover.
+var volatileTemplateFuncs = map[string]bool{
+	"Env":        true,
+	"EnvDefault": true,
+	"PkgParam":   true,
+}
+
+// callsVolatileFuncs returns true if any of the templates or the named
+// templates that they can invoke, including 'headerTemplate', call
+// a function that is listed in volatileTemplateFuncs.
+func callsVolatileFuncs(templates []embeddedTemplateFile,
+	headerTemplate string) (bool, error) {
+	found := false
+
+	visit := func(node parse.Node) {
+		if ident, ok := node.(*parse.IdentifierNode); ok &&
+			volatileTemplateFuncs[ident.Ident] {
+			found = true
+		}
+	}
+
+	for _, fileInfo := range templates {
+		t, err := parsePackageTemplate(fileInfo.pathname,
+			fileInfo.contents, nil, headerTemplate)
+		if err != nil {
+			return false, err
+		}
+		for _, tmpl := range t.Templates() {
+			if tmpl.Tree != nil {
+				walkTemplateTree(tmpl.Tree.Root, visit)
+			}
+		}
+		if found {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// hashWorkspaceInputs returns a digest of the workspace settings and
+// the command line options that the generated package sources depend
+// on regardless of the package.
+func hashWorkspaceInputs(ws *workspace) string {
+	return hashValues(toolVersion(), ws.wp.CopyrightHeader,
+		ws.wp.PackageDefName, flags.sourceMode, ws.buildDir())
+}
+
+// computePackageHashes returns the input hashes of the packages in the
+// selection. In addition to the package definition file and the source
+// files covered by hashPackageInputs, the hash of a package covers the
+// package type, the parameters that the package has inherited or read
+// from parameter files, and the workspace-level inputs.
+func computePackageHashes(ws *workspace,
+	selection packageDefinitionList) (packageState, error) {
+	workspaceHash := hashWorkspaceInputs(ws)
+
+	state := packageState{}
+
+	for _, pd := range selection {
+		inputsHash, err := hashPackageInputs(pd)
+		if err != nil {
+			return nil, err
+		}
+
+		params, err := json.Marshal(jsonCompatible(pd.params))
+		if err != nil {
+			return nil, err
+		}
+
+		state[pd.PackageName] = hashValues(workspaceHash,
+			pd.packageType, string(params), inputsHash)
+	}
+
+	return state, nil
+}
+
+// readGenerationState returns the package hashes recorded by the last
+// generation. A missing file is not an error: nothing has been
+// recorded yet.
+func readGenerationState(privateDir string) (packageState, error) {
+	state, err := readPackageState(path.Join(privateDir,
+		generationStateFilename))
+	if os.IsNotExist(err) {
+		return packageState{}, nil
+	}
+	return state, err
+}
+
+// writeGenerationState saves the package hashes in the private
+// directory.
+func writeGenerationState(privateDir string, state packageState) error {
+	return writePackageState(path.Join(privateDir,
+		generationStateFilename), state)
+}
+
+// outdatedPackages returns the names of the packages that must be
+// regenerated: the packages whose input hashes differ from the
+// recorded ones along with the selected packages that depend on them,
+// the packages whose templates call volatile functions, and the
+// packages whose generated directories are missing under 'pkgRootDir'.
+func outdatedPackages(pi *packageIndex, selection packageDefinitionList,
+	previous, current packageState,
+	pkgRootDir string) (map[string]bool, error) {
+	outdated := make(map[string]bool)
+
+	for _, pd := range changedPackages(selection, previous, current) {
+		outdated[pd.PackageName] = true
+	}
+
+	volatileByType := make(map[string]bool)
+
+	for _, pd := range selection {
+		if outdated[pd.PackageName] {
+			continue
+		}
+
+		volatile, ok := volatileByType[pd.packageType]
+		if !ok {
+			var err error
+			volatile, err = callsVolatileFuncs(append(
+				getEmbeddedTemplate(pd.packageType),
+				commonTemplateFiles...), pi.headerTemplate())
+			if err != nil {
+				return nil, err
+			}
+			volatileByType[pd.packageType] = volatile
+		}
+
+		if volatile {
+			outdated[pd.PackageName] = true
+			continue
+		}
+
+		_, err := os.Stat(path.Join(pkgRootDir, pd.PackageName))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return nil, err
+			}
+			outdated[pd.PackageName] = true
+		}
+	}
+
+	return outdated, nil
+}
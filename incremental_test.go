@@ -0,0 +1,209 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestIncrementalGeneration(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "incremental")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	origWorkspaceDir, origPkgPath, origBuildDir := flags.workspaceDir,
+		flags.pkgPath, flags.buildDir
+	origNoBootstrap, origIncremental := flags.noBootstrap,
+		flags.incremental
+	defer func() {
+		flags.workspaceDir = origWorkspaceDir
+		flags.pkgPath = origPkgPath
+		flags.buildDir = origBuildDir
+		flags.noBootstrap = origNoBootstrap
+		flags.incremental = origIncremental
+	}()
+
+	pkgDir := path.Join(tempDir, "pkg")
+
+	writeDefinition := func(pkgName, requires, version string) {
+		writeFileForTesting(t, path.Join(pkgDir, pkgName,
+			packageDefinitionFilename), "name: "+pkgName+"\n"+
+			"description: Test package\ntype: app\n"+
+			"version: "+version+"\nrequires: ["+requires+"]\n")
+	}
+
+	// 'c' depends on 'b', which depends on 'a';
+	// 'd' is independent.
+	writeDefinition("a", "", "1.0.0")
+	writeDefinition("b", "a", "1.0.0")
+	writeDefinition("c", "b", "1.0.0")
+	writeDefinition("d", "", "1.0.0")
+	for _, pkgName := range []string{"a", "b", "c", "d"} {
+		writeFileForTesting(t, path.Join(pkgDir, pkgName, "src",
+			"main.cc"), "int main() {}\n")
+	}
+
+	flags.workspaceDir = path.Join(tempDir, "ws")
+	flags.pkgPath = pkgDir
+	flags.buildDir = ""
+	flags.noBootstrap = true
+
+	if err = initWorkspace(); err != nil {
+		t.Fatal(err)
+	}
+
+	ws, err := loadWorkspace()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generate := func() {
+		pi, err := readPackageDefinitions(ws.wp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = generateAndBootstrapPackages(ws, pi,
+			pi.orderedPackages, newConftab()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkOutdated := func(expected string) {
+		pi, err := readPackageDefinitions(ws.wp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		current, err := computePackageHashes(ws, pi.orderedPackages)
+		if err != nil {
+			t.Fatal(err)
+		}
+		previous, err := readGenerationState(ws.absPrivateDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outdated, err := outdatedPackages(pi, pi.orderedPackages,
+			previous, current, ws.generatedPkgRootDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for pkgName := range outdated {
+			names = append(names, pkgName)
+		}
+		sort.Strings(names)
+		if result := strings.Join(names, ", "); result != expected {
+			t.Error("Unexpected outdated packages: " + result)
+		}
+	}
+
+	checkOutdated("a, b, c, d")
+
+	generate()
+
+	checkOutdated("")
+
+	writeDefinition("b", "a", "1.1.0")
+	checkOutdated("b, c")
+
+	// A new source file changes the package as well.
+	writeFileForTesting(t, path.Join(pkgDir, "d", "src", "util.cc"), "\n")
+	checkOutdated("b, c, d")
+
+	// The sources of the packages that are not regenerated
+	// are neither updated nor pruned.
+	configureAc := path.Join(ws.generatedPkgRootDir(), "a", "configure.ac")
+	if err = os.Remove(configureAc); err != nil {
+		t.Fatal(err)
+	}
+	writeFileForTesting(t, configureAc, "Stale\n")
+
+	flags.incremental = true
+	generate()
+
+	checkOutdated("")
+
+	contents, err := ioutil.ReadFile(configureAc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "Stale\n" {
+		t.Error("Unchanged package was regenerated")
+	}
+
+	makefileAm := path.Join(ws.generatedPkgRootDir(), "b", "src",
+		"Makefile.am")
+	if _, err = os.Stat(makefileAm); err != nil {
+		t.Error("Generated file was pruned: " + err.Error())
+	}
+
+	// A deleted package directory is regenerated.
+	if err = os.RemoveAll(path.Join(ws.generatedPkgRootDir(),
+		"d")); err != nil {
+		t.Fatal(err)
+	}
+	checkOutdated("d")
+
+	// Without --incremental, all packages are regenerated.
+	flags.incremental = false
+	generate()
+
+	contents, err = ioutil.ReadFile(configureAc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) == "Stale\n" {
+		t.Error("Package was not regenerated")
+	}
+
+	checkOutdated("")
+
+	// Changes in the contents of source files are detected
+	// as they matter when the sources are copied.
+	writeFileForTesting(t, path.Join(pkgDir, "d", "src", "util.cc"),
+		"int util() { return 0; }\n")
+	checkOutdated("d")
+
+	// Workspace-level settings affect all packages.
+	ws.wp.CopyrightHeader = "Copyright (C) {{.holder}}"
+	checkOutdated("a, b, c, d")
+}
+
+func TestVolatileTemplateFuncs(t *testing.T) {
+	for _, templates := range [][]embeddedTemplateFile{
+		appTemplate, libTemplate, commonTemplateFiles} {
+		volatile, err := callsVolatileFuncs(templates,
+			copyrightHeaderTemplate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if volatile {
+			t.Error("Embedded templates call volatile functions")
+		}
+	}
+
+	for contents, expected := range map[string]bool{
+		`{{.name}}`:                             false,
+		`{{Env "CC"}}`:                          true,
+		`{{if .a}}{{EnvDefault "A" ""}}{{end}}`: true,
+		`{{range .x}}{{PkgParam . "y"}}{{end}}`: true,
+	} {
+		volatile, err := callsVolatileFuncs([]embeddedTemplateFile{
+			{"test", 0644, []byte(contents)}},
+			copyrightHeaderTemplate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if volatile != expected {
+			t.Error("Unexpected result for " + contents)
+		}
+	}
+}
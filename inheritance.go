@@ -0,0 +1,159 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// rawPackageDefinition holds the parameters of a package definition
+// before they are merged with the parameters of its base definition.
+type rawPackageDefinition struct {
+	pathname string
+	params   templateParams
+}
+
+// inheritanceResolver merges the parameters of package definitions
+// with those of the definitions named by their 'extends' parameter.
+type inheritanceResolver struct {
+	byName  map[string]*rawPackageDefinition
+	byFile  map[string]*rawPackageDefinition
+	visited map[*rawPackageDefinition]int
+	chain   []*rawPackageDefinition
+}
+
+// resolveInheritance replaces the parameters of each definition that
+// has the 'extends' parameter with the parameters of its base merged
+// with its own. The base is either another package, referred to by
+// name, or a file with shared defaults, referred to by a pathname
+// relative to the extending definition. Parameters of the extending
+// definition take precedence. The 'name' parameter is never inherited.
+func resolveInheritance(definitions []rawPackageDefinition) error {
+	resolver := inheritanceResolver{
+		make(map[string]*rawPackageDefinition),
+		make(map[string]*rawPackageDefinition),
+		make(map[*rawPackageDefinition]int), nil}
+
+	for i := range definitions {
+		if name, ok := definitions[i].params["name"].(string); ok {
+			resolver.byName[name] = &definitions[i]
+		}
+	}
+
+	for i := range definitions {
+		if err := resolver.resolve(&definitions[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// label returns the name of the package if it is known
+// or the pathname of the definition otherwise.
+func (def *rawPackageDefinition) label() string {
+	if name, ok := def.params["name"].(string); ok {
+		return name
+	}
+	return def.pathname
+}
+
+// base returns the definition that 'def' extends or nil
+// if the definition does not have the 'extends' parameter.
+func (resolver *inheritanceResolver) base(
+	def *rawPackageDefinition) (*rawPackageDefinition, error) {
+	extends, found := def.params["extends"]
+	if !found {
+		return nil, nil
+	}
+
+	baseName, ok := extends.(string)
+	if !ok || baseName == "" {
+		return nil, errors.New(def.pathname +
+			": 'extends' field must be a non-empty string")
+	}
+
+	if !strings.Contains(baseName, "/") &&
+		!strings.HasSuffix(baseName, ".yaml") {
+		base := resolver.byName[baseName]
+		if base == nil {
+			return nil, errors.New(def.pathname + ": package '" +
+				baseName + "' to extend could not be found")
+		}
+		return base, nil
+	}
+
+	pathname := baseName
+	if !filepath.IsAbs(pathname) {
+		pathname = path.Join(filepath.Dir(def.pathname), pathname)
+	}
+
+	if base := resolver.byFile[pathname]; base != nil {
+		return base, nil
+	}
+
+	params, err := readPackageParams(pathname)
+	if err != nil {
+		return nil, err
+	}
+
+	base := &rawPackageDefinition{pathname, params}
+	resolver.byFile[pathname] = base
+
+	return base, nil
+}
+
+func (resolver *inheritanceResolver) resolve(def *rawPackageDefinition) error {
+	switch resolver.visited[def] {
+	case visited:
+		return nil
+	case beingVisited:
+		var cycle []string
+		for i := len(resolver.chain) - 1; i >= 0; i-- {
+			cycle = append([]string{resolver.chain[i].label()},
+				cycle...)
+			if resolver.chain[i] == def {
+				break
+			}
+		}
+		return errors.New("circular inheritance detected: " +
+			strings.Join(append(cycle, def.label()), " -> "))
+	}
+
+	resolver.visited[def] = beingVisited
+	resolver.chain = append(resolver.chain, def)
+
+	base, err := resolver.base(def)
+	if err != nil {
+		return err
+	}
+
+	if base != nil {
+		if err = resolver.resolve(base); err != nil {
+			return err
+		}
+
+		merged := templateParams{}
+		for key, value := range base.params {
+			if key != "name" {
+				merged[key] = value
+			}
+		}
+		for key, value := range def.params {
+			merged[key] = value
+		}
+		def.params = merged
+	}
+
+	delete(def.params, "extends")
+
+	resolver.chain = resolver.chain[:len(resolver.chain)-1]
+	resolver.visited[def] = visited
+
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func readPackageDefinitionsForTesting(t *testing.T,
+	definitions map[string]string) (*packageIndex, error) {
+	tempDir, err := ioutil.TempDir("", "extends")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for pathname, contents := range definitions {
+		writeFileForTesting(t, path.Join(tempDir, pathname), contents)
+	}
+
+	defer func(origPkgPath string) {
+		flags.pkgPath = origPkgPath
+	}(flags.pkgPath)
+	flags.pkgPath = tempDir
+
+	return readPackageDefinitions(&workspaceParams{Quiet: true})
+}
+
+func TestPackageInheritance(t *testing.T) {
+	pi, err := readPackageDefinitionsForTesting(t, map[string]string{
+		"defaults.yaml": "license: MIT\nversion: 1.0.0\n" +
+			"type: library\n",
+		"base/" + packageDefinitionFilename: "name: base\n" +
+			"extends: ../defaults.yaml\n" +
+			"description: Base library\n" +
+			"copyright: Base authors\n",
+		"client/" + packageDefinitionFilename: "name: client\n" +
+			"extends: base\ndescription: Client\n" +
+			"type: application\nversion: 2.0.0\n" +
+			"requires: [base]\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := pi.packageByName["base"]
+	if base == nil || base.packageType != "library" ||
+		base.params["license"] != "MIT" ||
+		base.params["version"] != "1.0.0" {
+		t.Error("Shared defaults were not inherited")
+	}
+
+	client := pi.packageByName["client"]
+	if client == nil {
+		t.Fatal("Extending package not found")
+	}
+	for key, expected := range map[string]interface{}{
+		"name":        "client",
+		"description": "Client",
+		"type":        "application",
+		"version":     "2.0.0",
+		"license":     "MIT",
+		"copyright":   "Base authors"} {
+		if client.params[key] != expected {
+			t.Error("Unexpected value of '" + key + "'")
+		}
+	}
+	if _, found := client.params["extends"]; found {
+		t.Error("The 'extends' parameter must not be retained")
+	}
+
+	for _, testCase := range []struct {
+		definitions map[string]string
+		expectedErr string
+	}{
+		{map[string]string{
+			"a/" + packageDefinitionFilename: "name: a\n" +
+				"extends: b\n",
+			"b/" + packageDefinitionFilename: "name: b\n" +
+				"extends: c\n",
+			"c/" + packageDefinitionFilename: "name: c\n" +
+				"extends: a\n"},
+			"circular inheritance detected: a -> b -> c -> a"},
+		{map[string]string{
+			"a/" + packageDefinitionFilename: "name: a\n" +
+				"extends: a\n"},
+			"circular inheritance detected: a -> a"},
+		{map[string]string{
+			"a/" + packageDefinitionFilename: "name: a\n" +
+				"extends: none\n"},
+			"package 'none' to extend could not be found"},
+	} {
+		_, err = readPackageDefinitionsForTesting(t,
+			testCase.definitions)
+		if err == nil || !strings.Contains(err.Error(),
+			testCase.expectedErr) {
+			t.Error("Expected error: " + testCase.expectedErr)
+		}
+	}
+}
@@ -9,9 +9,10 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
+	"strings"
 
 	"github.com/spf13/cobra"
-	yaml "gopkg.in/yaml.v2"
 )
 
 func initWorkspace() error {
@@ -22,8 +23,9 @@ func initWorkspace() error {
 
 	privateDir := getPrivateDir(workspaceDir)
 
-	if _, err = os.Stat(privateDir); err == nil {
-		return errors.New("workspace already initialized")
+	if _, err = os.Stat(privateDir); err == nil && !flags.force {
+		return errors.New("workspace already initialized " +
+			"(use --force to reinitialize)")
 	}
 
 	pkgpath, err := getPkgPathFlag()
@@ -38,6 +40,20 @@ func initWorkspace() error {
 		}
 	}
 
+	if _, _, err = buildFileTemplate(flags.generator); err != nil {
+		return err
+	}
+
+	if flags.packageDefName == "." || flags.packageDefName == ".." ||
+		strings.ContainsRune(flags.packageDefName, '/') {
+		return errors.New("invalid package definition file name '" +
+			flags.packageDefName + "'")
+	}
+
+	if err = checkSourceMode(flags.sourceMode); err != nil {
+		return err
+	}
+
 	buildDir, err := absIfNotEmpty(flags.buildDir)
 	if err != nil {
 		return err
@@ -49,26 +65,31 @@ func initWorkspace() error {
 	}
 
 	wp := workspaceParams{flags.quiet, pkgpath,
-		flags.makefile, flags.defaultMakeTarget,
-		buildDir, installDir}
+		flags.makefile, flags.generator, flags.defaultMakeTarget,
+		buildDir, installDir, flags.command, flags.copyrightHeader,
+		flags.keepGoing, flags.workspaceRelative, flags.packageDefName,
+		flags.formatCommand, flags.formatPatterns,
+		flags.bootstrapCommand, flags.sourceMode}
 
-	out, err := yaml.Marshal(&wp)
+	err = os.MkdirAll(privateDir, os.FileMode(0775))
 	if err != nil {
 		return err
 	}
 
-	err = os.MkdirAll(privateDir, os.FileMode(0775))
+	err = writeWorkspaceParams(privateDir, &wp)
 	if err != nil {
 		return err
 	}
 
-	err = ioutil.WriteFile(getPathToSettings(privateDir),
-		out, os.FileMode(0664))
+	// Start with an empty selection and a conftab
+	// that contains only the global section.
+	err = ioutil.WriteFile(path.Join(privateDir,
+		filenameForSelectedPackages), nil, os.FileMode(0664))
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return writeConftab(workspaceDir, newConftab())
 }
 
 // initCmd represents the init command
@@ -95,7 +116,18 @@ func init() {
 	addPkgPathFlag(initCmd)
 	addWorkspaceDirFlag(initCmd)
 	addMakefileFlag(initCmd)
+	addGeneratorFlag(initCmd)
 	addDefaultMakeTargetFlag(initCmd)
 	addBuildDirFlag(initCmd)
 	addInstallDirFlag(initCmd)
+	addCommandFlag(initCmd)
+	addCopyrightHeaderFlag(initCmd)
+	addKeepGoingFlag(initCmd)
+	addWorkspaceRelativeFlag(initCmd)
+	addPackageDefNameFlag(initCmd)
+	addFormatCommandFlag(initCmd)
+	addFormatPatternsFlag(initCmd)
+	addBootstrapCommandFlag(initCmd)
+	addSourceModeFlag(initCmd)
+	addReinitFlag(initCmd)
 }
@@ -0,0 +1,143 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestInitWorkspace(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "init")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	origWorkspaceDir, origPkgPath, origForce :=
+		flags.workspaceDir, flags.pkgPath, flags.force
+	defer func() {
+		flags.workspaceDir = origWorkspaceDir
+		flags.pkgPath = origPkgPath
+		flags.force = origForce
+	}()
+
+	flags.workspaceDir = path.Join(tempDir, "ws")
+	flags.pkgPath = tempDir
+	flags.force = false
+
+	if err = initWorkspace(); err != nil {
+		t.Fatal(err)
+	}
+
+	if files := listFilesForTesting(t, getPrivateDir(
+		flags.workspaceDir)); files != "conftab, selected, settings.yaml" {
+		t.Error("Unexpected workspace contents: " + files)
+	}
+
+	ws, err := loadWorkspace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws.wp.PkgPath != tempDir {
+		t.Error("Unexpected pkgpath: " + ws.wp.PkgPath)
+	}
+
+	pi, err := readPackageDefinitions(ws.wp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	selection, err := readPackageSelection(pi, ws.absPrivateDir)
+	if err != nil || len(selection) != 0 {
+		t.Error("The initial selection must be empty")
+	}
+
+	if _, _, err = loadConftab(); err != nil {
+		t.Error("Cannot read the starter conftab: " + err.Error())
+	}
+
+	if err = initWorkspace(); err == nil {
+		t.Error("Existing workspace was reinitialized without --force")
+	}
+
+	flags.force = true
+	if err = initWorkspace(); err != nil {
+		t.Error("Reinitialization with --force failed: " + err.Error())
+	}
+}
+
+func TestCustomPackageDefName(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "defname")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	origWorkspaceDir, origPkgPath, origDefName :=
+		flags.workspaceDir, flags.pkgPath, flags.packageDefName
+	defer func() {
+		flags.workspaceDir = origWorkspaceDir
+		flags.pkgPath = origPkgPath
+		flags.packageDefName = origDefName
+	}()
+
+	pkgDir := path.Join(tempDir, "pkg")
+	writeFileForTesting(t, path.Join(pkgDir, "a", "pkg.yaml"),
+		"name: a\ndescription: A\ntype: library\nversion: 1.0.0\n")
+	writeFileForTesting(t, path.Join(pkgDir, "b",
+		packageDefinitionFilename),
+		"name: b\ndescription: B\ntype: library\nversion: 1.0.0\n")
+	writeFileForTesting(t, path.Join(pkgDir, "a", "a.c"), "")
+
+	flags.workspaceDir = path.Join(tempDir, "ws")
+	flags.pkgPath = pkgDir
+	flags.packageDefName = "pkg/yaml"
+
+	if err = initWorkspace(); err == nil {
+		t.Error("Invalid package definition file name was accepted")
+	}
+
+	flags.packageDefName = "pkg.yaml"
+
+	if err = initWorkspace(); err != nil {
+		t.Fatal(err)
+	}
+
+	flags.pkgPath = ""
+
+	ws, err := loadWorkspace()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pi, err := readPackageDefinitions(ws.wp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := packageNames(pi.orderedPackages); names != "a" {
+		t.Error("Unexpected packages found: " + names)
+	}
+
+	// The definition file is not treated as a source file.
+	var sources []string
+	if err = processPackageSources(pi.orderedPackages[0],
+		func(_, relativePathname string, _ os.FileInfo) error {
+			sources = append(sources, relativePathname)
+			return nil
+		}); err != nil {
+		t.Fatal(err)
+	}
+	if files := strings.Join(sources, ", "); files != "a.c" {
+		t.Error("Unexpected source files: " + files)
+	}
+
+	// The setting does not change the default file name.
+	if packageDefinitionFilename != appName+".yaml" {
+		t.Error("Default package definition file name was changed")
+	}
+}
@@ -159,11 +159,11 @@ AC_CHECK_LIB([{{.name}}], [{{.function}}],,
 	[{{.other_libs}}]{{end}}){{end}}
 {{end}}{{if .requires}}
 PKG_PROG_PKG_CONFIG()
-{{range .requires}}
+{{range .requires}}{{with RequiredPackage .}}
 PKG_CHECK_MODULES([{{VarNameUC .}}], [{{VarName .}}])
 CXXFLAGS="$CXXFLAGS ${{VarNameUC .}}_CFLAGS"
 LIBS="$LIBS ${{VarNameUC .}}_LIBS"
-{{end}}{{end -}}
+{{end}}{{end}}{{end -}}
 {{template "Snippet" .}}
 AC_SUBST(CONFIG_FLAGS)
 AC_SUBST(CONFIG_LIBS)
@@ -190,6 +190,7 @@ includedir=@UNINST_PREFIX@/include
 Name: @PACKAGE_NAME@
 Description: {{.description}}
 Version: @PACKAGE_VERSION@
+{{template "PkgConfigRequires" . -}}
 Libs: @UNINST_LIBS@
 Libs.private: @PRIVATE_CONFIG_LIBS@
 Cflags: @UNINST_FLAGS@
@@ -203,8 +204,9 @@ includedir=@includedir@
 Name: @PACKAGE_NAME@
 Description: {{.description}}
 Version: @PACKAGE_VERSION@
-Libs: @CONFIG_LIBS@
+{{template "PkgConfigRequires" . -}}
+Libs: -L${libdir} -l{{LibName .name}} @CONFIG_LIBS@
 Libs.private: @PRIVATE_CONFIG_LIBS@
-Cflags: @CONFIG_FLAGS@
+Cflags: -I${includedir} @CONFIG_FLAGS@
 `)},
 }
@@ -0,0 +1,61 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPkgConfigFile(t *testing.T) {
+	var contents []byte
+	for _, fileInfo := range libTemplate {
+		if fileInfo.pathname == "{name}.pc.in" {
+			contents = fileInfo.contents
+		}
+	}
+	if contents == nil {
+		t.Fatal("The library template does not produce a .pc file")
+	}
+
+	generate := func(params templateParams) string {
+		pd := &packageDefinition{PackageName: "b", params: params}
+
+		result, err := executePackageFileTemplate("", "b.pc.in", contents,
+			pd, nil, nil, nil, []outputFileParams{{"b.pc.in", params}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(result[0].contents)
+	}
+
+	pc := generate(templateParams{
+		"name":        "b",
+		"description": "Library B",
+		"requires":    []interface{}{"a"}})
+
+	for _, expected := range []string{
+		"\nDescription: Library B\n",
+		"\nRequires: a\n",
+		"\nLibs: -L${libdir} -lb @CONFIG_LIBS@\n",
+		"\nCflags: -I${includedir} @CONFIG_FLAGS@\n"} {
+		if !strings.Contains(pc, expected) {
+			t.Error("Missing line " + strings.TrimSpace(expected) +
+				" in:\n" + pc)
+		}
+	}
+
+	pc = generate(templateParams{"name": "b", "description": "B",
+		"requires": []interface{}{"a", "c"}})
+	if !strings.Contains(pc, "\nRequires: a, c\n") {
+		t.Error("Unexpected list of requirements:\n" + pc)
+	}
+
+	pc = generate(templateParams{"name": "b", "description": "B"})
+	if strings.Contains(pc, "Requires") {
+		t.Error("Packages without dependencies must not have " +
+			"a Requires line:\n" + pc)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// manifestFilename is the name of the file in the private directory
+// that lists the files generated by the last run.
+var manifestFilename = "manifest"
+
+// fileManifest keeps track of the files generated by the previous
+// and the current runs. The pathnames are absolute. Files can be
+// recorded from multiple goroutines.
+type fileManifest struct {
+	mutex         sync.Mutex
+	found         bool
+	previous      map[string]bool
+	current       map[string]bool
+	keepUntracked bool
+}
+
+// manifest accumulates the files generated by the current command.
+var manifest fileManifest
+
+// getManifestBaseDir returns the directory that the pathnames in the
+// manifest stored in 'privateDir' are relative to.
+func getManifestBaseDir(privateDir string) string {
+	return filepath.Dir(privateDir)
+}
+
+// load reads the list of files generated by the previous run from
+// the manifest in 'privateDir' and clears the list of the files
+// generated by the current run. Existing files that were not
+// generated are overwritten unless keepUntrackedFiles is called.
+func (m *fileManifest) load(privateDir string) error {
+	previous := make(map[string]bool)
+
+	file, err := os.Open(path.Join(privateDir, manifestFilename))
+	found := err == nil
+	if found {
+		defer file.Close()
+
+		baseDir := getManifestBaseDir(privateDir)
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" {
+				previous[path.Join(baseDir, line)] = true
+			}
+		}
+		if err = scanner.Err(); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.found = found
+	m.previous, m.current = previous, make(map[string]bool)
+	m.keepUntracked = false
+
+	return nil
+}
+
+// keepUntrackedFiles sets whether existing files that were not
+// generated by the previous run must be left intact.
+func (m *fileManifest) keepUntrackedFiles(keep bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.keepUntracked = keep
+}
+
+// keepsUntrackedFiles returns true if existing files that were
+// not generated by the previous run must be left intact. Without
+// a manifest, there is no way to tell the generated files from the
+// rest: the output directory may have been generated by a version
+// that did not keep the manifest. In that case, all files are
+// considered generated.
+func (m *fileManifest) keepsUntrackedFiles() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.keepUntracked && m.found
+}
+
+// add records a file generated by the current run.
+func (m *fileManifest) add(pathname string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.current == nil {
+		m.current = make(map[string]bool)
+	}
+	m.current[pathname] = true
+}
+
+// retain records the files inside 'dir' that were generated by the
+// previous run as generated by the current run as well. This protects
+// the files of the packages that are not regenerated from pruning.
+func (m *fileManifest) retain(dir string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.current == nil {
+		m.current = make(map[string]bool)
+	}
+	for pathname := range m.previous {
+		if isInsideDir(dir, pathname) {
+			m.current[pathname] = true
+		}
+	}
+}
+
+// remove excludes a file from the files generated by the current run.
+func (m *fileManifest) remove(pathname string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.current, pathname)
+}
+
+// wasGenerated returns true if the file was generated
+// by the previous run.
+func (m *fileManifest) wasGenerated(pathname string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.previous[pathname]
+}
+
+// save deletes the files that were generated by the previous run but
+// not by the current one and writes the list of the files generated
+// by the current run to the manifest in 'privateDir'. Files that have
+// been replaced with symbolic links are left intact.
+func (m *fileManifest) save(privateDir string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	baseDir := getManifestBaseDir(privateDir)
+
+	var stale, current []string
+
+	for pathname := range m.previous {
+		if !m.current[pathname] {
+			stale = append(stale, pathname)
+		}
+	}
+	for pathname := range m.current {
+		if rel, err := filepath.Rel(baseDir, pathname); err == nil &&
+			isInsideDir(baseDir, pathname) {
+			current = append(current, rel)
+		}
+	}
+
+	sort.Strings(stale)
+	sort.Strings(current)
+
+	for _, pathname := range stale {
+		if err := removeGeneratedFile(baseDir, pathname); err != nil {
+			return err
+		}
+	}
+
+	var buffer bytes.Buffer
+	for _, rel := range current {
+		buffer.WriteString(rel)
+		buffer.WriteString("\n")
+	}
+
+	if err := mkdirAllWithRetries(privateDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	return writeFileWithRetries(path.Join(privateDir, manifestFilename),
+		buffer.Bytes(), 0644)
+}
+
+// removeGeneratedFile deletes a file that is no longer generated along
+// with the parent directories that became empty. Files outside of
+// 'baseDir', symbolic links, and missing files are skipped.
+func removeGeneratedFile(baseDir, pathname string) error {
+	if !isInsideDir(baseDir, pathname) {
+		return nil
+	}
+
+	fileInfo, err := os.Lstat(pathname)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if !fileInfo.Mode().IsRegular() {
+		return nil
+	}
+
+	displayName, err := relativeToCwd(pathname)
+	if err != nil {
+		return err
+	}
+	reportFileChange("D", displayName)
+
+	if err = os.Remove(pathname); err != nil {
+		return err
+	}
+
+	for dir := filepath.Dir(pathname); dir != baseDir &&
+		isInsideDir(baseDir, dir); dir = filepath.Dir(dir) {
+		dirEntries, err := ioutil.ReadDir(dir)
+		if err != nil || len(dirEntries) > 0 {
+			break
+		}
+		if err = os.Remove(dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
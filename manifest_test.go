@@ -0,0 +1,109 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestManifestPruning(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	definitionPathname := path.Join(tempDir, "hello",
+		packageDefinitionFilename)
+	writeFileForTesting(t, definitionPathname, `name: hello
+type: app
+description: Test application
+version: "1.0"
+`)
+
+	templateDir := path.Join(tempDir, "template")
+	writeFileForTesting(t, path.Join(templateDir, "configure.ac"),
+		"AC_INIT([{{.name}}], [{{.version}}])\n")
+	writeFileForTesting(t, path.Join(templateDir, "doc", "Makefile.am"),
+		"dist_doc_DATA = README\n")
+
+	outputDir := path.Join(tempDir, "output")
+
+	generate := func() {
+		if err := generatePackage(definitionPathname, outputDir,
+			templateDir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	generate()
+
+	manifestPathname := path.Join(getPrivateDir(outputDir),
+		manifestFilename)
+	contents, err := ioutil.ReadFile(manifestPathname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "configure.ac\ndoc/Makefile.am\n" {
+		t.Error("Unexpected manifest contents: " + string(contents))
+	}
+
+	// A file that was not generated must survive the pruning.
+	writeFileForTesting(t, path.Join(outputDir, "doc", "notes.txt"),
+		"Keep me\n")
+
+	if err = os.Remove(path.Join(templateDir, "doc",
+		"Makefile.am")); err != nil {
+		t.Fatal(err)
+	}
+
+	generate()
+
+	if files := listFilesForTesting(t, outputDir); files !=
+		"configure.ac, doc/notes.txt" {
+		t.Error("Unexpected file set: " + files)
+	}
+
+	if err = os.Remove(path.Join(outputDir, "doc",
+		"notes.txt")); err != nil {
+		t.Fatal(err)
+	}
+	writeFileForTesting(t, path.Join(templateDir, "doc", "Makefile.am"),
+		"dist_doc_DATA = README\n")
+
+	generate()
+
+	if err = os.Remove(path.Join(templateDir, "doc",
+		"Makefile.am")); err != nil {
+		t.Fatal(err)
+	}
+
+	generate()
+
+	// Directories left empty are removed as well.
+	if _, err = os.Stat(path.Join(outputDir, "doc")); !os.IsNotExist(err) {
+		t.Error("Empty directory was not removed")
+	}
+
+	// In safe mode, the files listed in the manifest
+	// can be overwritten even without the marker.
+	defer func(origSafe bool) {
+		flags.safe = origSafe
+	}(flags.safe)
+	flags.safe = true
+
+	writeFileForTesting(t, path.Join(templateDir, "configure.ac"),
+		"AC_INIT([{{.name}}], [{{.version}}], [bugs@example.com])\n")
+
+	generate()
+
+	if files := listFilesForTesting(t, outputDir); files !=
+		"configure.ac" {
+		t.Error("Unexpected file set: " + files)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"text/template"
+)
+
+// ninjaEscapePath escapes the characters that have
+// a special meaning in Ninja build statements.
+func ninjaEscapePath(pathname string) string {
+	return strings.NewReplacer(
+		"$", "$$", " ", "$ ", ":", "$:").Replace(pathname)
+}
+
+// ninjaCommand converts a makefile recipe into a single shell command
+// line suitable for a Ninja 'command' variable. Make treats each recipe
+// line as a separate shell invocation, so each line is run in its own
+// subshell, and the lines are chained with '&&'. The failure of a line
+// that make would ignore (one prefixed with '-') is ignored as well.
+// The make variable DESTDIR becomes the environment variable of the
+// same name, which is where make itself would take it from.
+func ninjaCommand(makeScript string) string {
+	var commands []string
+	var continued string
+	ignoreErrors := false
+
+	for _, line := range strings.Split(makeScript, "\n") {
+		if continued != "" {
+			line = continued + " " + strings.TrimSpace(line)
+			continued = ""
+		} else {
+			line = strings.TrimLeft(line, "\t")
+			trimmed := strings.TrimLeft(line, "@-")
+			ignoreErrors = strings.Contains(
+				line[:len(line)-len(trimmed)], "-")
+			line = trimmed
+		}
+
+		if strings.HasSuffix(line, "\\") {
+			continued = strings.TrimSpace(
+				strings.TrimSuffix(line, "\\"))
+			continue
+		}
+
+		if line = strings.TrimSpace(line); line != "" {
+			line = strings.Replace(line, "$(MAKE)", "make", -1)
+			line = strings.Replace(line, "$(DESTDIR)",
+				"${DESTDIR}", -1)
+			line = strings.Replace(line, "$$", "$", -1)
+			if ignoreErrors {
+				line += " || true"
+			}
+			commands = append(commands, "("+line+")")
+		}
+	}
+
+	return strings.Replace(strings.Join(commands, " && "), "$", "$$", -1)
+}
+
+var ninjaFuncMap = template.FuncMap{
+	"NinjaPath":    ninjaEscapePath,
+	"NinjaCommand": ninjaCommand,
+}
+
+var ninjaTemplate = embeddedTemplateFile{"{makefile}", 0644,
+	[]byte(`rule run
+  command = $command
+
+build all: phony build
+
+default {{NinjaPath .default_target}}
+
+{{range .targets}}build {{NinjaPath .Target}}: {{if .MakeScript}}run{{else -}}
+phony{{end}}{{range .Dependencies}} {{NinjaPath .}}{{end}}
+{{if .MakeScript}}  command = {{NinjaCommand .MakeScript}}
+{{end}}
+{{end}}`)}
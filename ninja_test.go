@@ -0,0 +1,124 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNinjaCommand(t *testing.T) {
+	command := ninjaCommand("\t@echo '[dist] a'\n" +
+		"\t@cd 'build/a' && \\\n" +
+		"\tdate >> make_dist.log && \\\n" +
+		"\t$(MAKE) dist >> make_dist.log\n" +
+		"\t@echo $$HOME\n" +
+		"\t-@false\n")
+
+	expected := "(echo '[dist] a') && " +
+		"(cd 'build/a' && date >> make_dist.log && " +
+		"make dist >> make_dist.log) && (echo $$HOME) && " +
+		"(false || true)"
+
+	if command != expected {
+		t.Error("Error: \"" + command + "\" != \"" + expected + "\"")
+	}
+
+	path := ninjaEscapePath("dir with:colon/$file")
+	if path != "dir$ with$:colon/$$file" {
+		t.Error("Unexpected escaping: " + path)
+	}
+}
+
+func TestNinjaBuildFile(t *testing.T) {
+	pi, err := makePackageIndexForTesting([]string{
+		"a", "b:a", "c:a", "d:b,c"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targets := collectTargetsForTesting(t,
+		makeWorkspaceForTesting("/ws"), pi.orderedPackages, pi,
+		newConftab())
+
+	result, err := parseAndExecuteTemplate(ninjaTemplate.pathname,
+		ninjaTemplate.contents, ninjaFuncMap, nil,
+		[]outputFileParams{{"build.ninja", templateParams{
+			"default_target": "help",
+			"targets":        targets}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Parse the build statements of the generated file.
+	type edge struct {
+		rule   string
+		inputs string
+	}
+	edges := make(map[string]edge)
+
+	for _, line := range strings.Split(string(result[0].contents), "\n") {
+		if !strings.HasPrefix(line, "build ") {
+			continue
+		}
+		split := strings.SplitN(line[len("build "):], ": ", 2)
+		if len(split) != 2 {
+			t.Error("Malformed build statement: " + line)
+			continue
+		}
+		ruleAndInputs := strings.SplitN(split[1], " ", 2)
+		var inputs string
+		if len(ruleAndInputs) > 1 {
+			inputs = ruleAndInputs[1]
+		}
+		edges[split[0]] = edge{ruleAndInputs[0], inputs}
+	}
+
+	if len(edges) != len(targets)+1 {
+		t.Error("Unexpected number of build statements")
+	}
+
+	installTargets := 0
+
+	for _, mt := range targets {
+		e, found := edges[mt.Target]
+		if !found {
+			t.Error("Missing build statement for " + mt.Target)
+			continue
+		}
+
+		expectedRule := "phony"
+		if mt.MakeScript != "" {
+			expectedRule = "run"
+		}
+		if e.rule != expectedRule {
+			t.Error("Unexpected rule for " + mt.Target + ": " +
+				e.rule)
+		}
+
+		if strings.HasPrefix(mt.Target, "install_") ||
+			strings.HasPrefix(mt.Target, "uninstall_") {
+			installTargets++
+			command := ninjaCommand(mt.MakeScript)
+			if strings.Contains(command, "$$(") ||
+				!strings.Contains(command,
+					" DESTDIR=$${DESTDIR}") {
+				t.Error("Unexpected command of " + mt.Target +
+					": " + command)
+			}
+		}
+
+		expectedInputs := strings.Join(mt.Dependencies, " ")
+		if e.inputs != expectedInputs {
+			t.Error("Dependencies of " + mt.Target +
+				" do not match: " + e.inputs +
+				"; expected: " + expectedInputs)
+		}
+	}
+
+	if installTargets != 2*len(pi.orderedPackages) {
+		t.Error("Missing install or uninstall targets")
+	}
+}
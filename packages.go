@@ -12,11 +12,15 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v2"
 )
 
+// packageDefinitionFilename is the default file name of package
+// definitions. The workspace settings can override it.
 var packageDefinitionFilename = appName + ".yaml"
 
 type packageDefinition struct {
@@ -29,10 +33,30 @@ type packageDefinition struct {
 	uniqRequired packageDefinitionList // 'required' sans indirect reqs
 	dependent    packageDefinitionList // Packages that depend on this one
 	params       templateParams
+	targets      []customTarget      // User-defined make targets
+	configure    string              // Relative pathname of 'configure'
+	bootstrap    string              // Command that creates 'configure'
+	inTreeBuild  bool                // Build in the package directory
+	constraints  []versionConstraint // Versions of required packages
+	env          []envVariable       // Configure and build environment
 }
 
 type packageDefinitionList []*packageDefinition
 
+// envVariable is an environment variable that a package
+// definition sets for its configure and build commands.
+type envVariable struct {
+	name  string
+	value string
+}
+
+// customTarget represents a make target that a package
+// definition declares in its 'targets' section.
+type customTarget struct {
+	name   string
+	script string
+}
+
 func getRequiredField(pathname string, params templateParams,
 	fieldName string) (interface{}, error) {
 	if value := params[fieldName]; value != nil {
@@ -55,21 +79,56 @@ func getRequiredStringField(pathname string, params templateParams,
 	}
 }
 
-func loadPackageDefinition(pathname string) (*packageDefinition, []string,
-	error) {
+// readPackageParams reads the parameters from a package definition
+// file without validating them. Values that refer to other files
+// with '@pathname' are replaced with the contents of those files.
+func readPackageParams(pathname string) (templateParams, error) {
 	data, err := ioutil.ReadFile(pathname)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	var params templateParams
 
 	if err = yaml.Unmarshal(data, &params); err != nil {
 		errMessage := strings.TrimPrefix(err.Error(), "yaml: ")
-		err = errors.New(pathname + ": " + errMessage)
+		return nil, errors.New(pathname + ": " + errMessage)
+	}
+
+	if params == nil {
+		params = templateParams{}
+	}
+
+	if err = resolveParamFiles(pathname, params); err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
+
+func loadPackageDefinition(pathname string) (*packageDefinition, []string,
+	error) {
+	params, err := readPackageParams(pathname)
+	if err != nil {
 		return nil, nil, err
 	}
 
+	definitions := []rawPackageDefinition{{pathname, params}}
+
+	if err = resolveInheritance(definitions); err != nil {
+		return nil, nil, err
+	}
+
+	return newPackageDefinition(pathname, definitions[0].params)
+}
+
+// newPackageDefinition validates the parameters of a package defined
+// in 'pathname' and returns the package definition along with the
+// names of the packages it requires. Version constraints in the
+// 'requires' list are kept in the definition and checked when the
+// package index is built.
+func newPackageDefinition(pathname string, params templateParams) (
+	*packageDefinition, []string, error) {
 	packageName, err := getRequiredStringField(pathname, params, "name")
 	if err != nil {
 		return nil, nil, err
@@ -86,12 +145,26 @@ func loadPackageDefinition(pathname string) (*packageDefinition, []string,
 		return nil, nil, err
 	}
 
-	_, err = getRequiredStringField(pathname, params, "version")
+	// YAML decodes unquoted versions like 2 and 1.10 as numbers.
+	// Integers are kept intact, but floating point numbers lose
+	// trailing zeros, so 1.10 would silently become 1.1.
+	version, err := getRequiredField(pathname, params, "version")
 	if err != nil {
 		return nil, nil, err
 	}
+	switch version.(type) {
+	case string, int:
+	case float64:
+		return nil, nil, errors.New(pathname +
+			": 'version' is read as a floating point number; " +
+			"quote the version to keep it intact")
+	default:
+		return nil, nil, errors.New(pathname +
+			": 'version' field must be a string or a number")
+	}
 
 	requires := []string{}
+	var constraints []versionConstraint
 
 	if requiredPackages := params["requires"]; requiredPackages != nil {
 		pkgList, ok := requiredPackages.([]interface{})
@@ -106,10 +179,45 @@ func loadPackageDefinition(pathname string) (*packageDefinition, []string,
 					": 'requires' must be " +
 					"a list of strings")
 			}
-			requires = append(requires, pkgNameStr)
+			requiredName, constraint, err :=
+				parseRequirement(pkgNameStr)
+			if err != nil {
+				return nil, nil, errors.New(pathname +
+					": " + err.Error())
+			}
+			requires = append(requires, requiredName)
+			if constraint != nil {
+				constraints = append(constraints, *constraint)
+			}
 		}
 	}
 
+	customTargets, err := getCustomTargets(pathname, params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	configurePath, err := getConfigurePath(pathname, params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bootstrapCommand, ok := params["bootstrap_command"].(string)
+	if !ok && params["bootstrap_command"] != nil {
+		return nil, nil, errors.New(pathname +
+			": 'bootstrap_command' field must be a string")
+	}
+
+	inTreeBuild, err := getInTreeBuild(pathname, params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	env, err := getEnvironment(pathname, params)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	return &packageDefinition{
 		packageName,
 		description,
@@ -119,12 +227,168 @@ func loadPackageDefinition(pathname string) (*packageDefinition, []string,
 		/*allRequired*/ packageDefinitionList{},
 		/*uniqRequired*/ packageDefinitionList{},
 		/*dependent*/ packageDefinitionList{},
-		params}, requires, nil
+		params,
+		customTargets,
+		configurePath,
+		bootstrapCommand,
+		inTreeBuild,
+		constraints,
+		env}, requires, nil
+}
+
+// getConfigurePath returns the location of the configure script relative
+// to the package directory, which can be changed with the optional
+// 'configure_path' parameter.
+func getConfigurePath(pathname string, params templateParams) (string,
+	error) {
+	configurePath := params["configure_path"]
+	if configurePath == nil {
+		return "configure", nil
+	}
+
+	configurePathStr, ok := configurePath.(string)
+	if !ok {
+		return "", errors.New(pathname +
+			": 'configure_path' field must be a string")
+	}
+
+	cleanPath := path.Clean(configurePathStr)
+	if configurePathStr == "" || path.IsAbs(cleanPath) ||
+		cleanPath == "." || cleanPath == ".." ||
+		strings.HasPrefix(cleanPath, "../") {
+		return "", errors.New(pathname + ": 'configure_path' " +
+			"must be a relative pathname within the package")
+	}
+
+	return cleanPath, nil
+}
+
+// getInTreeBuild returns true if the optional 'build_mode' parameter
+// requests that the package be built in its source directory.
+func getInTreeBuild(pathname string, params templateParams) (bool, error) {
+	switch params["build_mode"] {
+	case nil, "out-of-tree":
+		return false, nil
+	case "in-tree":
+		return true, nil
+	}
+	return false, errors.New(pathname + ": 'build_mode' must be " +
+		"either 'out-of-tree' or 'in-tree'")
+}
+
+var envVariableNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// getEnvironment parses the optional 'env' map of environment variables
+// for the configure and build commands of the package. The returned
+// list is sorted by variable name.
+func getEnvironment(pathname string, params templateParams) (
+	[]envVariable, error) {
+	env := params["env"]
+	if env == nil {
+		return nil, nil
+	}
+
+	envMap, ok := env.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New(pathname +
+			": 'env' must be a map of variable names to values")
+	}
+
+	var variables []envVariable
+
+	for name, value := range envMap {
+		nameStr, ok := name.(string)
+		if !ok || !envVariableNameRegexp.MatchString(nameStr) {
+			return nil, errors.New(pathname +
+				": invalid environment variable name '" +
+				fmt.Sprint(name) + "'")
+		}
+		switch value.(type) {
+		case string, int, float64, bool:
+		default:
+			return nil, errors.New(pathname +
+				": value of environment variable '" +
+				nameStr + "' must be a scalar")
+		}
+		variables = append(variables,
+			envVariable{nameStr, fmt.Sprint(value)})
+	}
+
+	sort.Slice(variables, func(i, j int) bool {
+		return variables[i].name < variables[j].name
+	})
+
+	return variables, nil
+}
+
+// pkgBuildDir returns the directory where the package is configured
+// and built given the build directory of the workspace and the
+// directory with generated package sources.
+func (pd *packageDefinition) pkgBuildDir(buildDir, pkgRootDir string) string {
+	if pd.inTreeBuild {
+		return path.Join(pkgRootDir, pd.PackageName)
+	}
+	return path.Join(buildDir, pd.PackageName)
+}
+
+// configurePathname returns the pathname of the configure
+// script of the package generated in packageDir.
+func (pd *packageDefinition) configurePathname(packageDir string) string {
+	return path.Join(packageDir, pd.configure)
+}
+
+// getCustomTargets parses the optional 'targets' section of a package
+// definition. The section maps target names to shell scripts. The
+// returned list is sorted by target name.
+func getCustomTargets(pathname string, params templateParams) (
+	[]customTarget, error) {
+	targets := params["targets"]
+	if targets == nil {
+		return nil, nil
+	}
+
+	targetMap, ok := targets.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New(pathname +
+			": 'targets' must be a map of target names to scripts")
+	}
+
+	var customTargets []customTarget
+
+	for name, script := range targetMap {
+		nameStr, ok := name.(string)
+		if !ok || nameStr == "" ||
+			strings.ContainsAny(nameStr, " \t:=#$%") {
+			return nil, errors.New(pathname +
+				": invalid custom target name '" +
+				fmt.Sprint(name) + "'")
+		}
+		if builtinTargetNames[nameStr] {
+			return nil, errors.New(pathname +
+				": custom target '" + nameStr +
+				"' conflicts with a built-in target")
+		}
+		scriptStr, ok := script.(string)
+		if !ok {
+			return nil, errors.New(pathname +
+				": script for custom target '" + nameStr +
+				"' must be a string")
+		}
+		customTargets = append(customTargets,
+			customTarget{nameStr, scriptStr})
+	}
+
+	sort.Slice(customTargets, func(i, j int) bool {
+		return customTargets[i].name < customTargets[j].name
+	})
+
+	return customTargets, nil
 }
 
 type packageIndex struct {
 	packageByName   map[string]*packageDefinition
 	orderedPackages packageDefinitionList
+	copyrightHeader string
 }
 
 func (pi *packageIndex) getPackageByName(pkgName string) (
@@ -136,8 +400,7 @@ func (pi *packageIndex) getPackageByName(pkgName string) (
 }
 
 func readPackageDefinitions(wp *workspaceParams) (*packageIndex, error) {
-	var packages packageDefinitionList
-	dependencies := [][]string{}
+	var definitions []rawPackageDefinition
 
 	pkgpath := flags.pkgPath
 	if pkgpath == "" {
@@ -154,29 +417,118 @@ func readPackageDefinitions(wp *workspaceParams) (*packageIndex, error) {
 		path.Join(filepath.Dir(os.Args[0]), "templates"))
 
 	for _, pkgpathDir := range pkgpathDirs {
+		// A regular file in the package path is an index
+		// manifest that lists package definitions.
+		if fileInfo, err := os.Stat(pkgpathDir); err == nil &&
+			fileInfo.Mode().IsRegular() {
+			manifestDefinitions, err :=
+				loadPackageIndexManifest(pkgpathDir)
+			if err != nil {
+				return nil, err
+			}
+			definitions = append(definitions,
+				manifestDefinitions...)
+			continue
+		}
+
 		dirEntries, _ := ioutil.ReadDir(pkgpathDir)
 
 		for _, dirEntry := range dirEntries {
 			dirEntryPathname := path.Join(pkgpathDir,
-				dirEntry.Name(), packageDefinitionFilename)
+				dirEntry.Name(), wp.definitionFilename())
 
 			fileInfo, err := os.Stat(dirEntryPathname)
 			if err != nil || !fileInfo.Mode().IsRegular() {
 				continue
 			}
 
-			pd, requires, err := loadPackageDefinition(
-				dirEntryPathname)
+			params, err := readPackageParams(dirEntryPathname)
 			if err != nil {
 				return nil, err
 			}
 
-			packages = append(packages, pd)
-			dependencies = append(dependencies, requires)
+			definitions = append(definitions,
+				rawPackageDefinition{dirEntryPathname, params})
+		}
+	}
+
+	if err := resolveInheritance(definitions); err != nil {
+		return nil, err
+	}
+
+	var packages packageDefinitionList
+	dependencies := [][]string{}
+
+	for _, definition := range definitions {
+		pd, requires, err := newPackageDefinition(
+			definition.pathname, definition.params)
+		if err != nil {
+			return nil, err
+		}
+
+		packages = append(packages, pd)
+		dependencies = append(dependencies, requires)
+	}
+
+	pi, err := buildPackageIndex(wp.Quiet, packages, dependencies)
+	if err != nil {
+		return nil, err
+	}
+
+	pi.copyrightHeader = wp.CopyrightHeader
+
+	return pi, nil
+}
+
+// loadPackageIndexManifest reads a package index manifest, which is
+// a YAML list of package definitions. In addition to the regular
+// package parameters, each entry contains the 'definition' field
+// with the location of the package definition file (relative to the
+// directory of the manifest). Parameter values of the form '@pathname'
+// are resolved relative to the same directory. The sources of a
+// package are expected
+// next to its definition file, but they are only required for
+// generating the package.
+func loadPackageIndexManifest(pathname string) ([]rawPackageDefinition,
+	error) {
+	data, err := ioutil.ReadFile(pathname)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []templateParams
+
+	if err = yaml.Unmarshal(data, &entries); err != nil {
+		errMessage := strings.TrimPrefix(err.Error(), "yaml: ")
+		return nil, errors.New(pathname + ": " + errMessage)
+	}
+
+	var definitions []rawPackageDefinition
+
+	for _, params := range entries {
+		definition, err := getRequiredStringField(pathname, params,
+			"definition")
+		if err != nil {
+			return nil, err
+		}
+		delete(params, "definition")
+
+		// Parameter files are also located relative
+		// to the directory of the manifest.
+		if err = resolveParamFiles(pathname, params); err != nil {
+			return nil, err
+		}
+
+		if !filepath.IsAbs(definition) {
+			definition = path.Join(filepath.Dir(pathname),
+				definition)
 		}
+
+		definitions = append(definitions,
+			rawPackageDefinition{definition, params})
 	}
 
-	return buildPackageIndex(wp.Quiet, packages, dependencies)
+	return definitions, nil
 }
 
 type topologicalSorter struct {
@@ -253,7 +605,7 @@ func topologicalSort(packages packageDefinitionList) (packageDefinitionList,
 func buildPackageIndex(quiet bool, packages packageDefinitionList,
 	dependencies [][]string) (*packageIndex, error) {
 	pi := &packageIndex{make(map[string]*packageDefinition),
-		packageDefinitionList{}}
+		packageDefinitionList{}, ""}
 
 	// Create the packageByName index.
 	for _, pd := range packages {
@@ -268,21 +620,61 @@ func buildPackageIndex(quiet bool, packages packageDefinitionList,
 	}
 
 	// Resolve dependencies and compute the edges of the
-	// reverse dependency DAG.
+	// reverse dependency DAG. All dangling references are
+	// reported at once.
+	var danglingRefs []string
+
 	for i, pd := range packages {
 		for _, dep := range dependencies[i] {
 			depp := pi.packageByName[dep]
 			if depp == nil {
-				return nil, errors.New("package " +
-					pd.PackageName + " requires " +
-					dep + ", which is not " +
+				danglingRefs = append(danglingRefs, "package "+
+					pd.PackageName+" requires "+
+					dep+", which is not "+
 					"available in the search path")
+				continue
 			}
 			pd.required = append(pd.required, depp)
 			depp.dependent = append(depp.dependent, pd)
 		}
 	}
 
+	if len(danglingRefs) > 0 {
+		return nil, errors.New(strings.Join(danglingRefs, "\n"))
+	}
+
+	// Check the versions of the required packages against
+	// the constraints. All violations are reported at once.
+	var violations []string
+
+	for _, pd := range packages {
+		for i := range pd.constraints {
+			constraint := &pd.constraints[i]
+			depp := pi.packageByName[constraint.pkgName]
+			value, ok := depp.params["version"]
+			if !ok {
+				violations = append(violations, "package "+
+					pd.PackageName+" requires "+
+					constraint.String()+", but "+
+					depp.pathname+" does not define "+
+					"a version")
+				continue
+			}
+			version := fmt.Sprint(value)
+			if !constraint.satisfiedBy(version) {
+				violations = append(violations, "package "+
+					pd.PackageName+" requires "+
+					constraint.String()+", but version "+
+					version+" is available (from "+
+					depp.pathname+")")
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return nil, errors.New(strings.Join(violations, "\n"))
+	}
+
 	// Apply topological sorting to the dependency DAG so that
 	// no package comes before the packages it depends on.
 	var err error
@@ -5,6 +5,9 @@
 package main
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
 	"path"
 	"strings"
 	"testing"
@@ -35,7 +38,8 @@ func makePackageIndexForTesting(packagesAndDependencies []string, quiet bool) (
 		packages = append(packages, &packageDefinition{
 			PackageName: split[0],
 			pathname: path.Join(split[0],
-				packageDefinitionFilename)})
+				packageDefinitionFilename),
+			configure: "configure"})
 
 		if len(split) > 1 {
 			deps = append(deps, strings.Split(split[1], ","))
@@ -205,3 +209,115 @@ func TestSelectionGraph(t *testing.T) {
 			"j": "i",
 		})
 }
+
+func TestConfigurePath(t *testing.T) {
+	configurePath, err := getConfigurePath("a.yaml", templateParams{})
+	if err != nil || configurePath != "configure" {
+		t.Error("Unexpected default configure path: " + configurePath)
+	}
+
+	configurePath, err = getConfigurePath("a.yaml", templateParams{
+		"configure_path": "./src//configure"})
+	if err != nil || configurePath != "src/configure" {
+		t.Error("Unexpected configure path: " + configurePath)
+	}
+
+	for _, invalid := range []interface{}{
+		"", ".", "..", "../configure", "/configure", 42} {
+		if _, err = getConfigurePath("a.yaml", templateParams{
+			"configure_path": invalid}); err == nil {
+			t.Errorf("Invalid configure path accepted: %v", invalid)
+		}
+	}
+}
+
+func TestPackageIndexManifest(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	manifestPathname := path.Join(tempDir, "index.yaml")
+
+	writeFileForTesting(t, manifestPathname, `- name: base
+  type: lib
+  description: Base library
+  version: "1.0"
+  definition: /srv/src/base/autoforge.yaml
+- name: client
+  type: app
+  description: Client application
+  version: "2.1"
+  requires: [base]
+  sources: ["@client/sources.txt", main.c]
+  license: "@LICENSE"
+  definition: client/autoforge.yaml
+`)
+	writeFileForTesting(t, path.Join(tempDir, "client", "sources.txt"),
+		"util.c\nutil.h\n")
+	writeFileForTesting(t, path.Join(tempDir, "LICENSE"), "MIT\n")
+
+	// Package sources are not needed to resolve
+	// package names and dependencies.
+	pi, err := readPackageDefinitions(&workspaceParams{
+		Quiet: true, PkgPath: manifestPathname})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := packageNames(pi.orderedPackages); names != "base, client" {
+		t.Error("Unexpected package list: " + names)
+	}
+
+	client, err := pi.getPackageByName("client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := packageNames(client.required); names != "base" {
+		t.Error("Unexpected dependencies: " + names)
+	}
+	if client.pathname != path.Join(tempDir, "client",
+		packageDefinitionFilename) {
+		t.Error("Unexpected definition location: " + client.pathname)
+	}
+	if _, found := client.params["definition"]; found {
+		t.Error("Manifest field is passed to templates")
+	}
+	if sources := fmt.Sprint(client.params["sources"]); sources !=
+		"[util.c util.h main.c]" {
+		t.Error("Unexpected sources: " + sources)
+	}
+	if license := client.params["license"]; license != "MIT\n" {
+		t.Error("Parameter file reference was not resolved")
+	}
+
+	writeFileForTesting(t, manifestPathname, `- name: base
+  type: lib
+  description: Base library
+  version: "1.0"
+`)
+	if _, err = readPackageDefinitions(&workspaceParams{
+		Quiet: true, PkgPath: manifestPathname}); err == nil ||
+		!strings.Contains(err.Error(), "'definition'") {
+		t.Error("Missing definition location is not reported")
+	}
+}
+
+func TestDanglingRequirements(t *testing.T) {
+	_, err := makePackageIndexForTesting([]string{
+		"base", "client:base,nosuchlib", "server:base,netlib"}, true)
+
+	if err == nil {
+		t.Fatal("Dangling requirements were not detected")
+	}
+
+	for _, expected := range []string{
+		"package client requires nosuchlib, which is not available",
+		"package server requires netlib, which is not available"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Error("Error message does not contain \"" +
+				expected + "\": " + err.Error())
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// readParamFile returns the contents of the file that a parameter
+// value of the form '@pathname' refers to. Relative pathnames are
+// resolved against the directory of the package definition file.
+func readParamFile(pathname, paramName, ref string) (string, error) {
+	filename := ref[1:]
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(filepath.Dir(pathname), filename)
+	}
+
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", errors.New(pathname + ": parameter '" + paramName +
+			"': " + err.Error())
+	}
+
+	return string(contents), nil
+}
+
+// isParamFileRef returns true if the string value refers to a file.
+// A leading '@@' escapes a literal '@'.
+func isParamFileRef(value string) bool {
+	return strings.HasPrefix(value, "@") && !strings.HasPrefix(value, "@@")
+}
+
+// unescapeParamValue removes the escaping '@' from a value that
+// starts with '@@'.
+func unescapeParamValue(value interface{}) interface{} {
+	if s, ok := value.(string); ok && strings.HasPrefix(s, "@@") {
+		return s[1:]
+	}
+	return value
+}
+
+// expandParamFileRefs returns a copy of the list in which every
+// '@pathname' element is replaced with the non-blank lines of the file.
+func expandParamFileRefs(pathname, paramName string,
+	list []interface{}) ([]interface{}, error) {
+	elems := []interface{}{}
+
+	for _, elem := range list {
+		ref, ok := elem.(string)
+		if !ok || !isParamFileRef(ref) {
+			elems = append(elems, unescapeParamValue(elem))
+			continue
+		}
+
+		contents, err := readParamFile(pathname, paramName, ref)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, line := range strings.Split(contents, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				elems = append(elems, line)
+			}
+		}
+	}
+
+	return elems, nil
+}
+
+// resolveParamFiles replaces parameter values of the form '@pathname'
+// with the contents of the named files. In a list, such an element
+// is replaced with the lines of the file.
+func resolveParamFiles(pathname string, params templateParams) error {
+	for name, value := range params {
+		var err error
+
+		switch v := value.(type) {
+		case string:
+			if isParamFileRef(v) {
+				params[name], err = readParamFile(pathname,
+					name, v)
+			} else {
+				params[name] = unescapeParamValue(v)
+			}
+		case []interface{}:
+			params[name], err = expandParamFileRefs(pathname,
+				name, v)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
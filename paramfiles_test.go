@@ -0,0 +1,58 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParamFiles(t *testing.T) {
+	pi, err := readPackageDefinitionsForTesting(t, map[string]string{
+		"hello/" + packageDefinitionFilename: "name: hello\n" +
+			"description: '@@home'\ntype: application\n" +
+			"version: 1.0.0\n" +
+			"configure_ac_patch: '@patches/configure.ac.in'\n" +
+			"sources: [main.c, '@sources.txt', '@@weird.c']\n",
+		"hello/patches/configure.ac.in": "AC_PROG_CXX\n" +
+			"AC_LANG([C++])\n",
+		"hello/sources.txt": "util.c\n\n  net.c  \nio.c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pd := pi.packageByName["hello"]
+
+	if patch := pd.params["configure_ac_patch"]; patch !=
+		"AC_PROG_CXX\nAC_LANG([C++])\n" {
+		t.Errorf("Unexpected scalar value: %v", patch)
+	}
+
+	if sources := pd.params["sources"]; !reflect.DeepEqual(sources,
+		[]interface{}{"main.c", "util.c", "net.c", "io.c", "@weird.c"}) {
+		t.Errorf("Unexpected list value: %v", sources)
+	}
+
+	if pd.description != "@home" {
+		t.Error("Escaped value was not unescaped: " + pd.description)
+	}
+
+	for _, definition := range []string{
+		"configure_ac_patch: '@missing.in'\n",
+		"sources: [main.c, '@missing.txt']\n"} {
+		_, err = readPackageDefinitionsForTesting(t, map[string]string{
+			"hello/" + packageDefinitionFilename: "name: hello\n" +
+				"description: Hello\ntype: application\n" +
+				"version: 1.0.0\n" + definition})
+		if err == nil || !strings.Contains(err.Error(),
+			"no such file") {
+			t.Error("Missing file was not reported")
+		} else if !strings.Contains(err.Error(), "hello/"+
+			packageDefinitionFilename+": parameter '") {
+			t.Error("Unexpected error message: " + err.Error())
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"text/template"
+	"text/template/parse"
+)
+
+// toolParamNames lists the package definition parameters that
+// are consumed or written by the tool itself rather than used
+// by the templates. The 'sources' list is written by the add
+// command to record the starter sources of a new package.
+var toolParamNames = map[string]bool{
+	"name":              true,
+	"description":       true,
+	"type":              true,
+	"requires":          true,
+	"targets":           true,
+	"configure_path":    true,
+	"bootstrap_command": true,
+	"build_mode":        true,
+	"env":               true,
+	"sources":           true,
+}
+
+// paramRefs is a set of package parameter names
+// referenced by templates and their pathnames.
+// A nil paramRefs ignores all references.
+type paramRefs map[string]bool
+
+var pathnameParamRegexp = regexp.MustCompile(`\{([^{}]+)\}`)
+
+func (refs paramRefs) addPathname(pathname string) {
+	if refs == nil {
+		return
+	}
+
+	for _, match := range pathnameParamRegexp.FindAllStringSubmatch(
+		pathname, -1) {
+		refs[match[1]] = true
+	}
+}
+
+// indexKeys returns the string literal arguments
+// of a command that calls the 'index' function.
+func indexKeys(cmd *parse.CommandNode) []string {
+	if len(cmd.Args) == 0 {
+		return nil
+	}
+	ident, ok := cmd.Args[0].(*parse.IdentifierNode)
+	if !ok || ident.Ident != "index" {
+		return nil
+	}
+
+	var keys []string
+	for _, arg := range cmd.Args[1:] {
+		if key, ok := arg.(*parse.StringNode); ok {
+			keys = append(keys, key.Text)
+		}
+	}
+	return keys
+}
+
+// copyrightHeaderName is the name of the template that
+// parsePackageTemplate defines for the text rendered by
+// the CopyrightHeader function.
+var copyrightHeaderName = "CopyrightHeader()"
+
+// parsePackageTemplate parses a package file template without
+// executing it. The named templates that the template can invoke,
+// including 'headerTemplate' rendered by CopyrightHeader, are
+// defined in the same template set.
+func parsePackageTemplate(templateName string, templateContents []byte,
+	partials map[string]string,
+	headerTemplate string) (*template.Template, error) {
+	t := template.New(filepath.Base(templateName))
+	t.Funcs(commonFuncMap)
+	t.Funcs(packageFuncMap(nil, nil, nil))
+
+	// IncludeFile is bound to the location of the template when
+	// the template is executed; a stub is enough for parsing.
+	t.Funcs(template.FuncMap{
+		"IncludeFile": func(relPath string) (string, error) {
+			return "", nil
+		}})
+
+	for name, text := range packageTemplateDefinitions(partials) {
+		if _, err := t.New(name).Parse(text); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := t.New(copyrightHeaderName).Parse(
+		headerTemplate); err != nil {
+		return nil, err
+	}
+
+	t.Delims(templateDelims(templateName))
+
+	if _, err := t.Parse(string(templateContents)); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// walkTemplateTree calls 'visit' for every node of a template
+// parse tree. Invocations of other templates are not followed.
+func walkTemplateTree(node parse.Node, visit func(parse.Node)) {
+	visit(node)
+
+	walkBranch := func(branch *parse.BranchNode) {
+		walkTemplateTree(branch.Pipe, visit)
+		walkTemplateTree(branch.List, visit)
+		if branch.ElseList != nil {
+			walkTemplateTree(branch.ElseList, visit)
+		}
+	}
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		for _, child := range n.Nodes {
+			walkTemplateTree(child, visit)
+		}
+	case *parse.ActionNode:
+		walkTemplateTree(n.Pipe, visit)
+	case *parse.PipeNode:
+		for _, cmd := range n.Cmds {
+			walkTemplateTree(cmd, visit)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkTemplateTree(arg, visit)
+		}
+	case *parse.ChainNode:
+		walkTemplateTree(n.Node, visit)
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode)
+	case *parse.TemplateNode:
+		if n.Pipe != nil {
+			walkTemplateTree(n.Pipe, visit)
+		}
+	}
+}
+
+// addTemplate parses the template and records all parameter names that
+// it refers to either directly as fields of dot or '$', or as string
+// keys of the 'index' function. Sub-templates invoked by the template
+// are scanned as well. Because dot can change inside 'range' and 'with'
+// actions, the result may include names that are not parameters, which
+// is harmless for detecting unused parameters. The text rendered by
+// CopyrightHeader is defined by 'headerTemplate'.
+func (refs paramRefs) addTemplate(templateName string,
+	templateContents []byte, partials map[string]string,
+	headerTemplate string) error {
+	if refs == nil {
+		return nil
+	}
+
+	t, err := parsePackageTemplate(templateName, templateContents,
+		partials, headerTemplate)
+	if err != nil {
+		return err
+	}
+
+	visited := make(map[string]bool)
+
+	var walkTemplate func(name string)
+
+	visit := func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.CommandNode:
+			for _, key := range indexKeys(n) {
+				refs[key] = true
+			}
+		case *parse.FieldNode:
+			refs[n.Ident[0]] = true
+		case *parse.VariableNode:
+			if len(n.Ident) > 1 && n.Ident[0] == "$" {
+				refs[n.Ident[1]] = true
+			}
+		case *parse.ChainNode:
+			if len(n.Field) > 0 {
+				refs[n.Field[0]] = true
+			}
+		case *parse.TemplateNode:
+			walkTemplate(n.Name)
+		case *parse.IdentifierNode:
+			// The text rendered by CopyrightHeader refers
+			// to the package parameters as well.
+			if n.Ident == "CopyrightHeader" {
+				walkTemplate(copyrightHeaderName)
+			}
+		}
+	}
+
+	walkTemplate = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		if tmpl := t.Lookup(name); tmpl != nil && tmpl.Tree != nil {
+			walkTemplateTree(tmpl.Tree.Root, visit)
+		}
+	}
+
+	walkTemplate(t.Name())
+
+	return nil
+}
+
+// unusedParams returns the sorted list of the package
+// parameters that have not been referenced.
+func (refs paramRefs) unusedParams(pd *packageDefinition) []string {
+	var unused []string
+
+	for name := range pd.params {
+		if !refs[name] && !toolParamNames[name] {
+			unused = append(unused, name)
+		}
+	}
+
+	sort.Strings(unused)
+
+	return unused
+}
+
+// reportUnusedParams prints a warning for each package
+// parameter that none of the templates refers to.
+func (refs paramRefs) reportUnusedParams(pd *packageDefinition) {
+	if refs == nil {
+		return
+	}
+
+	for _, name := range refs.unusedParams(pd) {
+		fmt.Fprintln(os.Stderr, pd.pathname+
+			": warning: unused parameter '"+name+"'")
+	}
+}
+
+// newParamRefs returns an empty set of parameter references
+// if the --warn-unused-params flag is set, or nil otherwise.
+func newParamRefs() paramRefs {
+	if flags.warnUnusedParams {
+		return paramRefs{}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnusedParams(t *testing.T) {
+	pd := &packageDefinition{
+		PackageName: "a",
+		params: templateParams{
+			"name":        "a",
+			"description": "Test package",
+			"module":      "core",
+			"header":      "Copyright",
+			"sources":     []string{"a.c"},
+			"libs":        map[interface{}]interface{}{"z": "-lz"},
+			"cflags":      "-O2",
+			"headers":     []string{"a.h"},
+			"srouces":     []string{"typo.c"},
+			"extra":       "unused"}}
+
+	refs := paramRefs{}
+
+	refs.addPathname("{name}/{module}.c")
+
+	err := refs.addTemplate("Makefile.am", []byte(
+		`{{template "FileHeader" .}}{{.sources}} {{$.libs.z}}
+{{index . "cflags"}}{{range .headers}}{{.}}{{end}}`), nil,
+		copyrightHeaderTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unused := strings.Join(refs.unusedParams(pd), ", ")
+	if unused != "extra, srouces" {
+		t.Error("Unexpected list of unused parameters: " + unused)
+	}
+
+	if err = refs.addTemplate("broken", []byte("{{.x"), nil,
+		copyrightHeaderTemplate); err == nil {
+		t.Error("Template syntax error is not reported")
+	}
+
+	// A nil set does not collect anything.
+	var disabled paramRefs
+	disabled.addPathname("{name}")
+	if err = disabled.addTemplate("test", []byte("{{.x"), nil,
+		copyrightHeaderTemplate); err != nil {
+		t.Error("Disabled reference collection parses templates")
+	}
+}
+
+func TestEmbeddedTemplateParamRefs(t *testing.T) {
+	refs := paramRefs{}
+
+	for _, templates := range [][]embeddedTemplateFile{
+		appTemplate, libTemplate, commonTemplateFiles} {
+		for _, fileInfo := range templates {
+			refs.addPathname(fileInfo.pathname)
+			if err := refs.addTemplate(fileInfo.pathname,
+				fileInfo.contents, nil,
+				copyrightHeaderTemplate); err != nil {
+				t.Error(fileInfo.pathname + ": " + err.Error())
+			}
+		}
+	}
+
+	for _, name := range []string{"name", "external_libs", "header"} {
+		if !refs[name] {
+			t.Error("Reference to '" + name + "' is not detected")
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// jsonCompatible converts the maps with interface{} keys that the YAML
+// decoder produces into maps with string keys, which JSON can encode.
+func jsonCompatible(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			result[fmt.Sprint(key)] = jsonCompatible(elem)
+		}
+		return result
+	case templateParams:
+		result := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			result[key] = jsonCompatible(elem)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, elem := range v {
+			result[i] = jsonCompatible(elem)
+		}
+		return result
+	}
+	return value
+}
+
+// formatParamValue renders a parameter value on a single line.
+// Lists are rendered as comma-separated elements in brackets.
+func formatParamValue(value interface{}) string {
+	var elems []string
+
+	switch v := value.(type) {
+	case []interface{}:
+		for _, elem := range v {
+			elems = append(elems, formatParamValue(elem))
+		}
+	case []string:
+		elems = v
+	default:
+		return fmt.Sprint(value)
+	}
+
+	return "[" + strings.Join(elems, ", ") + "]"
+}
+
+// printPackageParams prints the template parameters of the package
+// sorted by name, either one per line or as a JSON object.
+func printPackageParams(w io.Writer, pd *packageDefinition,
+	asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(jsonCompatible(pd.params))
+	}
+
+	var names []string
+	for name := range pd.params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		_, err := fmt.Fprintln(w, name+": "+
+			formatParamValue(pd.params[name]))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func showPackageParams(pkgName string) error {
+	wp := &workspaceParams{}
+	if flags.pkgPath == "" {
+		ws, err := loadWorkspace()
+		if err != nil {
+			return err
+		}
+		wp = ws.wp
+	}
+
+	pi, err := readPackageDefinitions(wp)
+	if err != nil {
+		return err
+	}
+
+	pd, err := pi.getPackageByName(pkgName)
+	if err != nil {
+		return err
+	}
+
+	return printPackageParams(os.Stdout, pd, flags.json)
+}
+
+// paramsCmd represents the params command
+var paramsCmd = &cobra.Command{
+	Use:   "params [flags] package_name",
+	Short: "Print the template parameters of a package",
+	Long: wrapText("The 'params' command prints the parameters " +
+		"that the package definition exposes to the templates. " +
+		"Inherited parameters are included."),
+	Args: cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		if err := showPackageParams(args[0]); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(paramsCmd)
+
+	paramsCmd.Flags().SortFlags = false
+	addPkgPathFlag(paramsCmd)
+	addWorkspaceDirFlag(paramsCmd)
+	addJSONFlag(paramsCmd)
+}
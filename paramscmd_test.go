@@ -0,0 +1,69 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestPrintPackageParams(t *testing.T) {
+	pi, err := readPackageDefinitionsForTesting(t, map[string]string{
+		"hello/" + packageDefinitionFilename: "name: hello\n" +
+			"description: Hello world\ntype: application\n" +
+			"version: 1.0.0\nheader_only: false\njobs: 4\n" +
+			"sources: [main.c, util.c]\nheaders: []\n" +
+			"targets:\n  docs: doxygen\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pd := pi.packageByName["hello"]
+
+	var buffer bytes.Buffer
+	if err = printPackageParams(&buffer, pd, false); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `description: Hello world
+header_only: false
+headers: []
+jobs: 4
+name: hello
+sources: [main.c, util.c]
+targets: map[docs:doxygen]
+type: application
+version: 1.0.0
+`
+	if buffer.String() != expected {
+		t.Error("Unexpected output:\n" + buffer.String())
+	}
+
+	buffer.Reset()
+	if err = printPackageParams(&buffer, pd, true); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]interface{}
+	if err = json.Unmarshal(buffer.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decoded, map[string]interface{}{
+		"name":        "hello",
+		"description": "Hello world",
+		"type":        "application",
+		"version":     "1.0.0",
+		"header_only": false,
+		"jobs":        float64(4),
+		"sources":     []interface{}{"main.c", "util.c"},
+		"headers":     []interface{}{},
+		"targets": map[string]interface{}{
+			"docs": "doxygen"}}) {
+		t.Error("Unexpected JSON output:\n" + buffer.String())
+	}
+}
@@ -7,6 +7,7 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 func relativeToCwd(absPath string) (string, error) {
@@ -35,3 +36,11 @@ func relativeIfShorter(basePath, targetPath string) string {
 	}
 	return targetPath
 }
+
+// isInsideDir returns true if 'pathname' is 'dir' itself or a
+// pathname inside of it. Both arguments must be absolute.
+func isInsideDir(dir, pathname string) bool {
+	relPath, err := filepath.Rel(dir, pathname)
+	return err == nil && relPath != ".." &&
+		!strings.HasPrefix(relPath, "../")
+}
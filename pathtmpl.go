@@ -7,6 +7,7 @@ package main
 import (
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -31,7 +32,9 @@ type pathnameTemplateText struct {
 // either a string or a slice of strings.  In the latter case, the text
 // in the receiver structure gets truncated by the substitution and the
 // receiver structure gets extended by a new pathnameTemplateMultiplier
-// structure.  Subst returns the number of substitution values.
+// structure.  Values of other types, such as booleans, are rendered
+// using fmt.Sprint; a boolean placeholder therefore becomes either
+// "true" or "false".  Subst returns the number of substitution values.
 func (t *pathnameTemplateText) subst(name string, value interface{}) int {
 	if textValue, ok := value.(string); ok {
 		t.text = strings.Replace(t.text, "{"+name+"}",
@@ -49,60 +52,78 @@ func (t *pathnameTemplateText) subst(name string, value interface{}) int {
 	return 1
 }
 
+// emptyPathnameParam returns the name of a parameter that the pathname
+// template refers to and whose value is an empty slice of strings.
+// If there is no such parameter, an empty string is returned.
+func emptyPathnameParam(pathname string, params templateParams) string {
+	var names []string
+
+	for name, value := range params {
+		if arrayValue, ok := value.([]string); ok &&
+			len(arrayValue) == 0 &&
+			strings.Contains(pathname, "{"+name+"}") {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return ""
+	}
+
+	sort.Strings(names)
+
+	return names[0]
+}
+
 // expandPathnameTemplate takes a pathname template and substitutes
 // template parameter names with their values. Parameter values can be
-// either strings or slices of strings. Each template value that is a
+// strings, slices of strings, or booleans. Each template value that is a
 // slice of strings multiplies the number of output strings by the number
-// of strings in the slice.
+// of strings in the slice. A parameter that appears in the template more
+// than once has the same value in all of its positions.
 func expandPathnameTemplate(pathname string,
 	params templateParams) []outputFileParams {
 	root := pathnameTemplateText{pathname, nil}
 
-	resultSize := 1
-
 	for name, value := range params {
-		resultSize *= root.subst(name, value)
+		root.subst(name, value)
 
 		for n := root.next; n != nil; n = n.continuation.next {
-			resultSize *= n.continuation.subst(name, value)
+			n.continuation.subst(name, value)
 		}
 	}
 
-	result := make([]outputFileParams, resultSize)
+	// The values of the list parameters change with the output
+	// file index. The parameter that appears first in the template
+	// changes the fastest.
+	stride := make(map[string]int)
+
+	resultSize := 1
 
-	if resultSize == 0 {
-		return result
+	for a := root.next; a != nil; a = a.continuation.next {
+		if _, seen := stride[a.paramName]; !seen {
+			stride[a.paramName] = resultSize
+			resultSize *= len(a.paramValues)
+		}
 	}
 
-	for i := 0; i < resultSize; i++ {
-		result[i].filename = root.text
+	result := make([]outputFileParams, resultSize)
+
+	for i := range result {
+		filename := root.text
 		copyOfParams := templateParams{}
 		for name, value := range params {
 			copyOfParams[name] = value
 		}
-		result[i].params = copyOfParams
-	}
 
-	sliceSize := 1
-
-	for a := root.next; a != nil; a = a.continuation.next {
-		numberOfValues := len(a.paramValues)
-
-		continuationText := a.continuation.text
-
-		for i := 0; i < resultSize; {
-			for j := 0; j < numberOfValues; j++ {
-				value := a.paramValues[j]
-				filenameFragment := value + continuationText
-				for k := 0; k < sliceSize; k++ {
-					result[i].filename += filenameFragment
-					result[i].params[a.paramName] = value
-					i++
-				}
-			}
+		for a := root.next; a != nil; a = a.continuation.next {
+			value := a.paramValues[i/stride[a.paramName]%
+				len(a.paramValues)]
+			filename += value + a.continuation.text
+			copyOfParams[a.paramName] = value
 		}
 
-		sliceSize *= numberOfValues
+		result[i] = outputFileParams{filename, copyOfParams}
 	}
 
 	// Let the templates know their output file and directory names.
@@ -117,3 +117,65 @@ func TestExpandPathnameTemplateNoFiles(t *testing.T) {
 	runExpandPathnameTemplateTest(t, "{nil}/{noeffect}",
 		paramsNil, resultNil)
 }
+
+func TestExpandPathnameTemplateThreeSlices(t *testing.T) {
+	params := map[string]interface{}{
+		"a":    []string{"x", "y"},
+		"b":    []string{"1", "2", "3"},
+		"c":    []string{"p", "q"},
+		"name": "pkg"}
+
+	result := expandPathnameTemplate("{a}/{b}/{c}.txt", params)
+
+	if len(result) != 12 {
+		t.Fatalf("Expected 12 files, got %d", len(result))
+	}
+
+	seen := make(map[string]bool)
+
+	for _, fp := range result {
+		a, b, c := fp.params["a"], fp.params["b"], fp.params["c"]
+		expected := a.(string) + "/" + b.(string) + "/" +
+			c.(string) + ".txt"
+		if fp.filename != expected {
+			t.Error("Parameters of " + fp.filename +
+				" do not match its name: " + expected)
+		}
+		if fp.params["dirname"] != a.(string)+"/"+b.(string) {
+			t.Error("Unexpected dirname for " + fp.filename)
+		}
+		if fp.params["name"] != "pkg" {
+			t.Error("Scalar parameter is lost in " + fp.filename)
+		}
+		seen[fp.filename] = true
+	}
+
+	for _, a := range params["a"].([]string) {
+		for _, b := range params["b"].([]string) {
+			for _, c := range params["c"].([]string) {
+				filename := a + "/" + b + "/" + c + ".txt"
+				if !seen[filename] {
+					t.Error("Missing file: " + filename)
+				}
+			}
+		}
+	}
+}
+
+func TestExpandPathnameTemplateRepeatedParam(t *testing.T) {
+	result := expandPathnameTemplate("{module}/{variant}/{module}.c",
+		map[string]interface{}{
+			"module":  []string{"core", "net"},
+			"variant": []string{"debug", "release"}})
+
+	var filenames []string
+	for _, fp := range result {
+		filenames = append(filenames, fp.filename)
+	}
+
+	expected := "core/debug/core.c, net/debug/net.c, " +
+		"core/release/core.c, net/release/net.c"
+	if strings.Join(filenames, ", ") != expected {
+		t.Error("Unexpected expansion: " + strings.Join(filenames, ", "))
+	}
+}
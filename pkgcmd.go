@@ -0,0 +1,138 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// resolvedPath is a pathname computed for a package in both absolute
+// and workspace-relative forms.
+type resolvedPath struct {
+	Absolute string `json:"absolute"`
+	Relative string `json:"relative"`
+}
+
+// packageInfo contains the locations that the tool
+// computes for a package in the current workspace.
+type packageInfo struct {
+	Name       string       `json:"name"`
+	SourceDir  resolvedPath `json:"source_dir"`
+	PackageDir resolvedPath `json:"package_dir"`
+	BuildDir   resolvedPath `json:"build_dir"`
+	Configure  resolvedPath `json:"configure"`
+}
+
+func (ws *workspace) resolvePath(absPath string) resolvedPath {
+	relPath, err := filepath.Rel(ws.absDir, absPath)
+	if err != nil {
+		relPath = absPath
+	}
+	return resolvedPath{absPath, relPath}
+}
+
+// getPackageInfo computes the locations of the package
+// the same way the makefile targets do.
+func getPackageInfo(ws *workspace, pd *packageDefinition) (
+	*packageInfo, error) {
+	sourceDir, err := filepath.Abs(filepath.Dir(pd.pathname))
+	if err != nil {
+		return nil, err
+	}
+
+	pkgRootDir := ws.generatedPkgRootDir()
+	packageDir := path.Join(pkgRootDir, pd.PackageName)
+
+	return &packageInfo{pd.PackageName,
+		ws.resolvePath(sourceDir),
+		ws.resolvePath(packageDir),
+		ws.resolvePath(pd.pkgBuildDir(ws.buildDir(), pkgRootDir)),
+		ws.resolvePath(pd.configurePathname(packageDir))}, nil
+}
+
+// printPackageInfo prints the package locations
+// either one per line or as a JSON object.
+func printPackageInfo(w io.Writer, info *packageInfo, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	}
+
+	_, err := fmt.Fprintf(w, "name: %s\n"+
+		"source_dir: %s (%s)\n"+
+		"package_dir: %s (%s)\n"+
+		"build_dir: %s (%s)\n"+
+		"configure: %s (%s)\n", info.Name,
+		info.SourceDir.Absolute, info.SourceDir.Relative,
+		info.PackageDir.Absolute, info.PackageDir.Relative,
+		info.BuildDir.Absolute, info.BuildDir.Relative,
+		info.Configure.Absolute, info.Configure.Relative)
+
+	return err
+}
+
+func showPackageInfo(pkgName string) error {
+	ws, err := loadWorkspace()
+	if err != nil {
+		return err
+	}
+
+	pi, err := readPackageDefinitions(ws.wp)
+	if err != nil {
+		return err
+	}
+
+	pd, err := pi.getPackageByName(pkgName)
+	if err != nil {
+		return err
+	}
+
+	info, err := getPackageInfo(ws, pd)
+	if err != nil {
+		return err
+	}
+
+	return printPackageInfo(os.Stdout, info, flags.json)
+}
+
+// pkgCmd represents the pkg command
+var pkgCmd = &cobra.Command{
+	Use:   "pkg",
+	Short: "Inspect packages in the workspace",
+}
+
+var pkgInfoCmd = &cobra.Command{
+	Use:   "info [flags] package_name",
+	Short: "Print the locations computed for a package",
+	Long: wrapText("The 'info' command prints the source directory, " +
+		"the directory with generated sources, the build directory, " +
+		"and the configure script of the package. Each pathname is " +
+		"printed both as an absolute pathname and relative to the " +
+		"workspace directory."),
+	Args: cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		if err := showPackageInfo(args[0]); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pkgCmd)
+	pkgCmd.AddCommand(pkgInfoCmd)
+
+	pkgInfoCmd.Flags().SortFlags = false
+	addWorkspaceDirFlag(pkgInfoCmd)
+	addJSONFlag(pkgInfoCmd)
+}
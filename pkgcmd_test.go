@@ -0,0 +1,130 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestPackageInfo(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "pkginfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	origWorkspaceDir, origPkgPath, origBuildDir :=
+		flags.workspaceDir, flags.pkgPath, flags.buildDir
+	defer func() {
+		flags.workspaceDir = origWorkspaceDir
+		flags.pkgPath = origPkgPath
+		flags.buildDir = origBuildDir
+	}()
+
+	pkgDir := path.Join(tempDir, "pkg")
+	writeFileForTesting(t, path.Join(pkgDir, "hello",
+		packageDefinitionFilename), "name: hello\n"+
+		"description: Hello\ntype: application\nversion: 1.0.0\n"+
+		"configure_path: src/configure\n")
+	writeFileForTesting(t, path.Join(pkgDir, "tool",
+		packageDefinitionFilename), "name: tool\n"+
+		"description: Tool\ntype: application\nversion: 1.0.0\n"+
+		"build_mode: in-tree\n")
+
+	workspaceDir := path.Join(tempDir, "ws")
+
+	flags.workspaceDir = workspaceDir
+	flags.pkgPath = pkgDir
+	flags.buildDir = path.Join(tempDir, "build")
+
+	if err = initWorkspace(); err != nil {
+		t.Fatal(err)
+	}
+
+	ws, err := loadWorkspace()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pi, err := readPackageDefinitions(ws.wp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []packageInfo{
+		{"hello",
+			resolvedPath{path.Join(pkgDir, "hello"),
+				"../pkg/hello"},
+			resolvedPath{path.Join(workspaceDir,
+				".autoforge/packages/hello"),
+				".autoforge/packages/hello"},
+			resolvedPath{path.Join(tempDir, "build/hello"),
+				"../build/hello"},
+			resolvedPath{path.Join(workspaceDir,
+				".autoforge/packages/hello/src/configure"),
+				".autoforge/packages/hello/src/configure"}},
+		{"tool",
+			resolvedPath{path.Join(pkgDir, "tool"),
+				"../pkg/tool"},
+			resolvedPath{path.Join(workspaceDir,
+				".autoforge/packages/tool"),
+				".autoforge/packages/tool"},
+			resolvedPath{path.Join(workspaceDir,
+				".autoforge/packages/tool"),
+				".autoforge/packages/tool"},
+			resolvedPath{path.Join(workspaceDir,
+				".autoforge/packages/tool/configure"),
+				".autoforge/packages/tool/configure"}},
+	} {
+		pd, err := pi.getPackageByName(expected.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := getPackageInfo(ws, pd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *info != expected {
+			t.Errorf("Unexpected info: %+v", *info)
+		}
+
+		var buffer bytes.Buffer
+		if err = printPackageInfo(&buffer, info, true); err != nil {
+			t.Fatal(err)
+		}
+		var decoded packageInfo
+		if err = json.Unmarshal(buffer.Bytes(), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded != expected {
+			t.Error("Unexpected JSON output:\n" + buffer.String())
+		}
+	}
+
+	pd, _ := pi.getPackageByName("hello")
+	info, _ := getPackageInfo(ws, pd)
+
+	var buffer bytes.Buffer
+	if err = printPackageInfo(&buffer, info, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := "name: hello\n" +
+		"source_dir: " + pkgDir + "/hello (../pkg/hello)\n" +
+		"package_dir: " + workspaceDir + "/.autoforge/packages/hello " +
+		"(.autoforge/packages/hello)\n" +
+		"build_dir: " + tempDir + "/build/hello (../build/hello)\n" +
+		"configure: " + workspaceDir +
+		"/.autoforge/packages/hello/src/configure " +
+		"(.autoforge/packages/hello/src/configure)\n"
+	if buffer.String() != expected {
+		t.Error("Unexpected output:\n" + buffer.String())
+	}
+}
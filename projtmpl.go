@@ -5,8 +5,8 @@
 package main
 
 import (
+	"bytes"
 	"errors"
-	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
@@ -18,9 +18,31 @@ import (
 type fileProcessor func(sourcePathname, relativePathname string,
 	info os.FileInfo) error
 
+// excludedByPattern returns true if the relative pathname matches
+// one of the patterns given with the --exclude-pattern flag. Patterns
+// that do not contain a slash are matched against the base name.
+func excludedByPattern(relativePathname string) (bool, error) {
+	for _, pattern := range flags.excludePatterns {
+		name := relativePathname
+		if !strings.Contains(pattern, "/") {
+			name = filepath.Base(relativePathname)
+		}
+		matched, err := filepath.Match(pattern, name)
+		if err != nil {
+			return false, errors.New("invalid exclude pattern '" +
+				pattern + "': " + err.Error())
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // processAllFiles calls the processFile() function for every file in
 // sourceDir. All hidden files and all files in hidden subdirectories
-// as well as package definition files are skipped.
+// are skipped. So are the files and directories that match the
+// exclusion patterns.
 func processAllFiles(sourceDir string, processFile fileProcessor) error {
 	sourceDir = filepath.Clean(sourceDir)
 	sourceDirWithSlash := sourceDir + "/"
@@ -46,22 +68,44 @@ func processAllFiles(sourceDir string, processFile fileProcessor) error {
 		// directory (and the target file in the target directory).
 		relativePathname := sourcePathname[len(sourceDirWithSlash):]
 
-		// Ignore hidden files and the package definition file.
-		if filepath.Base(relativePathname)[0] == '.' {
+		excluded, err := excludedByPattern(relativePathname)
+		if err != nil {
+			return err
+		}
+
+		// Ignore hidden and excluded files as well
+		// as the package definition file.
+		if excluded || filepath.Base(relativePathname)[0] == '.' {
 			if info.IsDir() {
 				return filepath.SkipDir
 			}
 			return nil
 		} else if info.IsDir() {
 			return nil
-		} else if relativePathname == packageDefinitionFilename {
-			return nil
 		}
 
 		return processFile(sourcePathname, relativePathname, info)
 	})
 }
 
+// processPackageSources calls the processFile() function for every
+// source file of the package. Files are skipped as in processAllFiles.
+// The package definition file itself is not a source file.
+func processPackageSources(pd *packageDefinition,
+	processFile fileProcessor) error {
+	definitionFilename := filepath.Base(pd.pathname)
+
+	return processAllFiles(filepath.Dir(pd.pathname),
+		func(sourcePathname, relativePathname string,
+			info os.FileInfo) error {
+			if relativePathname == definitionFilename {
+				return nil
+			}
+			return processFile(sourcePathname, relativePathname,
+				info)
+		})
+}
+
 // directoryTree represents a directory structure.
 // The 'entries' map contains directory entries.
 // If an entry name resolves into nil, it's a file,
@@ -174,16 +218,121 @@ func (dirTree *directoryTree) list() []string {
 	return list
 }
 
+// copySourceFile copies a package source file to 'targetPathname'
+// preserving its permissions and modification time. A target file
+// with the same size and modification time is considered up to date;
+// otherwise, the contents are compared before the file is rewritten.
+func copySourceFile(sourcePathname, targetPathname string) (bool, error) {
+	sourceFileInfo, err := os.Stat(sourcePathname)
+	if err != nil {
+		return false, err
+	}
+	perm := sourceFileInfo.Mode().Perm()
+	modTime := sourceFileInfo.ModTime()
+
+	mode := "R"
+
+	targetFileInfo, err := os.Lstat(targetPathname)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return false, err
+		}
+		if err = mkdirAllWithRetries(filepath.Dir(targetPathname),
+			os.ModePerm); err != nil {
+			return false, err
+		}
+		mode = "A"
+	} else if targetFileInfo.Mode().IsRegular() {
+		if !flags.force &&
+			targetFileInfo.Size() == sourceFileInfo.Size() &&
+			targetFileInfo.Mode().Perm() == perm &&
+			targetFileInfo.ModTime().Equal(modTime) {
+			return false, nil
+		}
+		mode = "U"
+	}
+
+	contents, err := ioutil.ReadFile(sourcePathname)
+	if err != nil {
+		return false, err
+	}
+
+	if mode == "U" && !flags.force &&
+		targetFileInfo.Mode().Perm() == perm {
+		oldContents, err := ioutil.ReadFile(targetPathname)
+		if err == nil && bytes.Equal(oldContents, contents) {
+			// Only the modification time differs. Update it
+			// so that the contents are not compared next time.
+			return false, os.Chtimes(targetPathname,
+				modTime, modTime)
+		}
+	}
+
+	reportFileChange(mode, targetPathname)
+	if mode == "R" {
+		if err = os.Remove(targetPathname); err != nil {
+			return false, err
+		}
+	}
+
+	if err = writeFileWithRetries(targetPathname, contents,
+		perm); err != nil {
+		return false, err
+	}
+
+	return true, os.Chtimes(targetPathname, modTime, modTime)
+}
+
+// checkSourceMode returns an error if 'mode' is not a valid value
+// of --source-mode. An empty string selects the default mode.
+func checkSourceMode(mode string) error {
+	if mode != "" && mode != "symlink" && mode != "copy" {
+		return errors.New("--source-mode: unknown mode '" +
+			mode + "' (must be 'copy' or 'symlink')")
+	}
+	return nil
+}
+
+// linkFilesFromSourceDir creates symbolic links to the package
+// sources in 'projectDir' or, if --source-mode is 'copy', copies
+// the sources there. The files that the ignore file in the source
+// directory excludes are not linked or copied. With --no-link, the
+// source directory is only scanned. In all cases, the returned tree
+// lists the source files for the Dir template functions.
 func linkFilesFromSourceDir(pd *packageDefinition,
 	projectDir string) (*directoryTree, bool, error) {
+	if err := checkSourceMode(flags.sourceMode); err != nil {
+		return nil, false, err
+	}
+	copyMode := flags.sourceMode == "copy"
+
 	dirTree := newDirectoryTree()
 	sourceDir := filepath.Dir(pd.pathname)
 	changesMade := false
 
+	ignoreList, err := readIgnoreFile(sourceDir)
+	if err != nil {
+		return nil, false, err
+	}
+
 	linkFile := func(sourcePathname, relativePathname string,
 		sourceFileInfo os.FileInfo) error {
+		if ignoreList.ignored(relativePathname) {
+			return nil
+		}
 		dirTree.addFile(relativePathname)
+		if flags.noLink {
+			return nil
+		}
 		targetPathname := path.Join(projectDir, relativePathname)
+		if copyMode {
+			copied, err := copySourceFile(sourcePathname,
+				targetPathname)
+			if copied {
+				changesMade = true
+			}
+			return err
+		}
 		targetFileInfo, err := os.Lstat(targetPathname)
 		if err == nil {
 			if (targetFileInfo.Mode() & os.ModeSymlink) != 0 {
@@ -193,7 +342,8 @@ func linkFilesFromSourceDir(pd *packageDefinition,
 					return err
 				}
 
-				if originalLink == sourcePathname {
+				if originalLink == sourcePathname &&
+					!flags.force {
 					return nil
 				}
 			}
@@ -203,54 +353,139 @@ func linkFilesFromSourceDir(pd *packageDefinition,
 			}
 		}
 
-		fmt.Println("L", targetPathname)
+		reportFileChange("L", targetPathname)
 
-		if err = os.MkdirAll(filepath.Dir(targetPathname),
+		if err = mkdirAllWithRetries(filepath.Dir(targetPathname),
 			os.ModePerm); err != nil {
 			return err
 		}
 
 		changesMade = true
 
-		return os.Symlink(sourcePathname, targetPathname)
+		return symlinkWithRetries(sourcePathname, targetPathname)
 	}
 
-	err := processAllFiles(sourceDir, linkFile)
+	err = processPackageSources(pd, linkFile)
 
 	return dirTree, changesMade, err
 }
 
-func pathnamesNotInDir(pathnameTemplate string, params templateParams,
-	dirTree *directoryTree) []outputFileParams {
+// pathnamesNotInDir expands the pathname template and returns the
+// expansions that are not overridden by the package source files.
+// It is an error for a list parameter used in the pathname template
+// to be empty, because then the template produces no files at all.
+func pathnamesNotInDir(pathnameTemplate string, pd *packageDefinition,
+	dirTree *directoryTree) ([]outputFileParams, error) {
+	if name := emptyPathnameParam(pathnameTemplate,
+		pd.params); name != "" {
+		return nil, errors.New(pd.pathname + ": template '" +
+			pathnameTemplate + "' expands to no files because " +
+			"parameter '" + name + "' is an empty list")
+	}
+
 	var fileParams []outputFileParams
-	for _, fp := range expandPathnameTemplate(pathnameTemplate, params) {
+	for _, fp := range expandPathnameTemplate(pathnameTemplate,
+		pd.params) {
 		if !dirTree.hasFile(fp.filename) {
 			fileParams = append(fileParams, fp)
 		}
 	}
-	return fileParams
+	return fileParams, nil
+}
+
+// outputFileOwners maps the pathnames of the files generated for
+// a package to the names of the templates that produced them.
+type outputFileOwners map[string]string
+
+// claim records 'templateName' as the source of the output files and
+// returns an error if any of them is already produced by a template.
+func (owners outputFileOwners) claim(pd *packageDefinition,
+	templateName string, fileParams []outputFileParams) error {
+	for _, fp := range fileParams {
+		if owner, taken := owners[fp.filename]; taken {
+			return errors.New(pd.PackageName + ": templates '" +
+				owner + "' and '" + templateName +
+				"' both generate '" + fp.filename + "'")
+		}
+		owners[fp.filename] = templateName
+	}
+	return nil
+}
+
+// partialsDirName is the name of the directory inside a project
+// template that contains shared template fragments. Each file in
+// this directory is available to the other template files as a
+// named template, for example: {{template "header.am" .}}.
+var partialsDirName = "_partials"
+
+// readTemplatePartials returns the contents of the files in the
+// partials directory of a project template keyed by their pathnames
+// relative to that directory.
+func readTemplatePartials(templateDir string) (map[string]string, error) {
+	partialsDir := path.Join(templateDir, partialsDirName)
+
+	if _, err := os.Stat(partialsDir); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	partials := make(map[string]string)
+
+	err := processAllFiles(partialsDir, func(sourcePathname,
+		relativePathname string, _ os.FileInfo) error {
+		contents, err := ioutil.ReadFile(sourcePathname)
+		if err != nil {
+			return err
+		}
+		partials[relativePathname] = string(contents)
+		return nil
+	})
+
+	return partials, err
 }
 
 // generateBuildFilesFromProjectTemplate generates an output file inside
 // 'projectDir' with the same relative pathname as the respective source
 // file in 'templateDir'.
 func generateBuildFilesFromProjectTemplate(templateDir,
-	projectDir string, pd *packageDefinition) (bool, error) {
+	projectDir string, pd *packageDefinition,
+	pi *packageIndex) (bool, error) {
+
+	partials, err := readTemplatePartials(templateDir)
+	if err != nil {
+		return false, err
+	}
 
 	dirTree, changesMade, err := linkFilesFromSourceDir(pd, projectDir)
 	if err != nil {
 		return false, err
 	}
 
+	refs := newParamRefs()
+	owners := outputFileOwners{}
+
 	generateFile := func(sourcePathname, relativePathname string,
 		sourceFileInfo os.FileInfo) error {
-		fileParams := pathnamesNotInDir(relativePathname,
-			pd.params, dirTree)
-
-		if len(fileParams) == 0 {
+		// Partials are not output files themselves.
+		if strings.HasPrefix(relativePathname, partialsDirName+"/") {
 			return nil
 		}
 
+		outputPathname := strings.TrimSuffix(relativePathname,
+			altDelimsSuffix)
+
+		refs.addPathname(outputPathname)
+
+		fileParams, err := pathnamesNotInDir(outputPathname,
+			pd, dirTree)
+		if err != nil || len(fileParams) == 0 {
+			return err
+		}
+
+		err = owners.claim(pd, relativePathname, fileParams)
+		if err != nil {
+			return err
+		}
+
 		// Read the contents of the template file. Cannot use
 		// template.ParseFiles() because a Funcs() call must be
 		// made between New() and Parse().
@@ -259,9 +494,17 @@ func generateBuildFilesFromProjectTemplate(templateDir,
 			return err
 		}
 
+		err = refs.addTemplate(relativePathname, templateContents,
+			partials, pi.headerTemplate())
+		if err != nil {
+			return err
+		}
+
 		filesUpdated, err := generateFilesFromProjectFileTemplate(
-			projectDir, relativePathname, templateContents,
-			sourceFileInfo.Mode(), pd, dirTree, fileParams)
+			projectDir, templateDir, relativePathname,
+			templateContents,
+			sourceFileInfo.Mode(), pd, pi, dirTree, partials,
+			fileParams)
 		if err != nil {
 			return err
 		}
@@ -271,9 +514,13 @@ func generateBuildFilesFromProjectTemplate(templateDir,
 		return nil
 	}
 
-	err = processAllFiles(templateDir, generateFile)
+	if err = processAllFiles(templateDir, generateFile); err != nil {
+		return false, err
+	}
+
+	refs.reportUnusedParams(pd)
 
-	return changesMade, err
+	return changesMade, nil
 }
 
 // embeddedTemplateFile defines the file mode and the contents
@@ -287,24 +534,43 @@ type embeddedTemplateFile struct {
 // generateBuildFilesFromEmbeddedTemplate generates project build
 // files from a built-in template pointed to by the 't' parameter.
 func generateBuildFilesFromEmbeddedTemplate(t []embeddedTemplateFile,
-	projectDir string, pd *packageDefinition) (bool, error) {
+	projectDir string, pd *packageDefinition,
+	pi *packageIndex) (bool, error) {
 
 	dirTree, changesMade, err := linkFilesFromSourceDir(pd, projectDir)
 	if err != nil {
 		return false, err
 	}
 
+	refs := newParamRefs()
+	owners := outputFileOwners{}
+
 	for _, fileInfo := range append(t, commonTemplateFiles...) {
-		fileParams := pathnamesNotInDir(fileInfo.pathname,
-			pd.params, dirTree)
+		refs.addPathname(fileInfo.pathname)
 
+		fileParams, err := pathnamesNotInDir(fileInfo.pathname,
+			pd, dirTree)
+		if err != nil {
+			return false, err
+		}
 		if len(fileParams) == 0 {
 			continue
 		}
 
+		err = owners.claim(pd, fileInfo.pathname, fileParams)
+		if err != nil {
+			return false, err
+		}
+
+		err = refs.addTemplate(fileInfo.pathname,
+			fileInfo.contents, nil, pi.headerTemplate())
+		if err != nil {
+			return false, err
+		}
+
 		filesUpdated, err := generateFilesFromProjectFileTemplate(
-			projectDir, fileInfo.pathname, fileInfo.contents,
-			fileInfo.mode, pd, dirTree, fileParams)
+			projectDir, "", fileInfo.pathname, fileInfo.contents,
+			fileInfo.mode, pd, pi, dirTree, nil, fileParams)
 		if err != nil {
 			return false, err
 		}
@@ -313,26 +579,33 @@ func generateBuildFilesFromEmbeddedTemplate(t []embeddedTemplateFile,
 		}
 	}
 
+	refs.reportUnusedParams(pd)
+
 	return changesMade, nil
 }
 
-func (pd *packageDefinition) getPackageGeneratorFunc(
-	packageDir string) (func() (bool, error), error) {
-	switch pd.packageType {
+// getEmbeddedTemplate returns the built-in project
+// template for the specified package type.
+func getEmbeddedTemplate(packageType string) []embeddedTemplateFile {
+	switch packageType {
 	case "app", "application":
-		return func() (bool, error) {
-			return generateBuildFilesFromEmbeddedTemplate(
-				appTemplate, packageDir, pd)
-		}, nil
-
+		return appTemplate
 	case "lib", "library":
-		return func() (bool, error) {
-			return generateBuildFilesFromEmbeddedTemplate(
-				libTemplate, packageDir, pd)
-		}, nil
+		return libTemplate
+	}
+	return nil
+}
 
-	default:
+func (pd *packageDefinition) getPackageGeneratorFunc(packageDir string,
+	pi *packageIndex) (func() (bool, error), error) {
+	t := getEmbeddedTemplate(pd.packageType)
+	if t == nil {
 		return nil, errors.New(pd.PackageName +
 			": unknown package type '" + pd.packageType + "'")
 	}
+
+	return func() (bool, error) {
+		return generateBuildFilesFromEmbeddedTemplate(
+			t, packageDir, pd, pi)
+	}, nil
 }
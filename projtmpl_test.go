@@ -0,0 +1,583 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestForcedRegeneration(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "force")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templateDir := path.Join(tempDir, "template")
+	sourceDir := path.Join(tempDir, "src")
+	projectDir := path.Join(tempDir, "project")
+
+	writeFileForTesting(t, path.Join(templateDir, "Makefile.am"),
+		"bin_PROGRAMS = {{.name}}\n")
+	writeFileForTesting(t, path.Join(sourceDir, "main.c"),
+		"int main() {}\n")
+
+	pd := &packageDefinition{
+		PackageName: "a",
+		pathname:    path.Join(sourceDir, packageDefinitionFilename),
+		params:      templateParams{"name": "a"}}
+
+	generate := func(force bool) bool {
+		defer func(origForce bool) {
+			flags.force = origForce
+		}(flags.force)
+		flags.force = force
+
+		changesMade, err := generateBuildFilesFromProjectTemplate(
+			templateDir, projectDir, pd, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return changesMade
+	}
+
+	if !generate(false) {
+		t.Error("Initial generation must report changes")
+	}
+
+	if generate(false) {
+		t.Error("Up-to-date files must not be rewritten")
+	}
+
+	if !generate(true) {
+		t.Error("Forced generation must rewrite up-to-date files")
+	}
+
+	link, err := os.Readlink(path.Join(projectDir, "main.c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != path.Join(sourceDir, "main.c") {
+		t.Error("Unexpected symlink target: " + link)
+	}
+
+	contents, err := ioutil.ReadFile(path.Join(projectDir, "Makefile.am"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "bin_PROGRAMS = a\n" {
+		t.Error("Unexpected contents: " + string(contents))
+	}
+}
+
+func TestTemplatePartials(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "partials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templateDir := path.Join(tempDir, "template")
+	sourceDir := path.Join(tempDir, "src")
+	projectDir := path.Join(tempDir, "project")
+
+	writeFileForTesting(t, path.Join(templateDir, partialsDirName,
+		"common.am"), "AM_CPPFLAGS = -DPACKAGE_{{VarNameUC .name}}\n")
+	writeFileForTesting(t, path.Join(templateDir, "Makefile.am"),
+		`{{template "common.am" .}}SUBDIRS = lib`+"\n")
+	writeFileForTesting(t, path.Join(templateDir, "lib", "Makefile.am"),
+		`{{template "common.am" .}}noinst_LIBRARIES = lib{{.name}}.a`+
+			"\n")
+	writeFileForTesting(t, path.Join(sourceDir, "main.c"),
+		"int main() {}\n")
+
+	pd := &packageDefinition{
+		PackageName: "a",
+		pathname:    path.Join(sourceDir, packageDefinitionFilename),
+		params:      templateParams{"name": "hello"}}
+
+	if _, err = generateBuildFilesFromProjectTemplate(
+		templateDir, projectDir, pd, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if files := listFilesForTesting(t, projectDir); files !=
+		"Makefile.am, lib/Makefile.am, main.c" {
+		t.Error("Unexpected file set: " + files)
+	}
+
+	for pathname, expected := range map[string]string{
+		"Makefile.am": "AM_CPPFLAGS = -DPACKAGE_HELLO\n" +
+			"SUBDIRS = lib\n",
+		"lib/Makefile.am": "AM_CPPFLAGS = -DPACKAGE_HELLO\n" +
+			"noinst_LIBRARIES = libhello.a\n"} {
+		contents, err := ioutil.ReadFile(path.Join(projectDir,
+			pathname))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(contents) != expected {
+			t.Error("Unexpected contents of " + pathname + ": " +
+				string(contents))
+		}
+	}
+}
+
+func TestEmptyPathnameExpansion(t *testing.T) {
+	pd := &packageDefinition{
+		PackageName: "a",
+		pathname:    "a/" + packageDefinitionFilename,
+		params: templateParams{
+			"name":    "a",
+			"modules": []string{},
+			"headers": []string{"a.h"}}}
+
+	dirTree := newDirectoryTree()
+
+	_, err := pathnamesNotInDir("{name}/{modules}.cc", pd, dirTree)
+	if err == nil {
+		t.Error("Expansion to zero files was not reported")
+	} else if !strings.Contains(err.Error(), "'{name}/{modules}.cc'") ||
+		!strings.Contains(err.Error(), "'modules' is an empty list") {
+		t.Error("Unexpected error message: " + err.Error())
+	}
+
+	// Templates without multipliers produce a single file.
+	fileParams, err := pathnamesNotInDir("{name}.pc.in", pd, dirTree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fileParams) != 1 || fileParams[0].filename != "a.pc.in" {
+		t.Error("Expected a single output file")
+	}
+
+	// Files overridden by the sources are not an error.
+	dirTree.addFile("a.h.in")
+	fileParams, err = pathnamesNotInDir("{headers}.in", pd, dirTree)
+	if err != nil || len(fileParams) != 0 {
+		t.Error("Overridden files must be skipped silently")
+	}
+}
+
+func TestOutputPathCollision(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "collision")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templateDir := path.Join(tempDir, "template")
+	sourceDir := path.Join(tempDir, "src")
+
+	writeFileForTesting(t, path.Join(templateDir, "{module}.h"),
+		"/* module */\n")
+	writeFileForTesting(t, path.Join(templateDir, "{name}.h"),
+		"/* name */\n")
+	writeFileForTesting(t, path.Join(sourceDir, "main.c"), "")
+
+	pd := &packageDefinition{
+		PackageName: "a",
+		pathname:    path.Join(sourceDir, packageDefinitionFilename),
+		params: templateParams{
+			"name":   "core",
+			"module": []string{"net", "core"}}}
+
+	_, err = generateBuildFilesFromProjectTemplate(templateDir,
+		path.Join(tempDir, "project"), pd, nil)
+	if err == nil {
+		t.Fatal("Output path collision was not detected")
+	}
+	if err.Error() != "a: templates '{module}.h' and '{name}.h' "+
+		"both generate 'core.h'" {
+		t.Error("Unexpected error message: " + err.Error())
+	}
+}
+
+func TestExcludePattern(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "exclude")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templateDir := path.Join(tempDir, "template")
+	sourceDir := path.Join(tempDir, "src")
+	projectDir := path.Join(tempDir, "project")
+
+	for _, pathname := range []string{"Makefile.am", "Makefile.am.bak",
+		"lib/Makefile.am", "lib/Makefile.am.bak",
+		"win32/Makefile.am"} {
+		writeFileForTesting(t, path.Join(templateDir, pathname),
+			"# {{.name}}\n")
+	}
+	for _, pathname := range []string{"main.c", "main.c.bak",
+		"win32/port.c", "src/win32.c"} {
+		writeFileForTesting(t, path.Join(sourceDir, pathname), "\n")
+	}
+
+	pd := &packageDefinition{
+		PackageName: "a",
+		pathname:    path.Join(sourceDir, packageDefinitionFilename),
+		params:      templateParams{"name": "hello"}}
+
+	defer func(origExcludePatterns []string) {
+		flags.excludePatterns = origExcludePatterns
+	}(flags.excludePatterns)
+
+	flags.excludePatterns = []string{"["}
+
+	if _, err = generateBuildFilesFromProjectTemplate(
+		templateDir, projectDir, pd, nil); err == nil ||
+		!strings.Contains(err.Error(), "invalid exclude pattern") {
+		t.Error("Invalid pattern must be reported")
+	}
+
+	flags.excludePatterns = []string{"*.bak", "win32"}
+
+	if _, err = generateBuildFilesFromProjectTemplate(
+		templateDir, projectDir, pd, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	flags.excludePatterns = nil
+
+	if files := listFilesForTesting(t, projectDir); files !=
+		"Makefile.am, lib/Makefile.am, main.c, src/win32.c" {
+		t.Error("Unexpected file set: " + files)
+	}
+}
+
+func TestSourceModeCopy(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	sourceDir := path.Join(tempDir, "src")
+	projectDir := path.Join(tempDir, "project")
+
+	writeFileForTesting(t, path.Join(sourceDir, "main.c"), "int main;\n")
+	writeFileForTesting(t, path.Join(sourceDir, "run.sh"), "exit 0\n")
+	if err = os.Chmod(path.Join(sourceDir, "run.sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	pd := &packageDefinition{
+		PackageName: "a",
+		pathname:    path.Join(sourceDir, packageDefinitionFilename)}
+
+	defer func(origSourceMode string) {
+		flags.sourceMode = origSourceMode
+	}(flags.sourceMode)
+
+	flags.sourceMode = "hardlink"
+
+	if _, _, err = linkFilesFromSourceDir(pd, projectDir); err == nil ||
+		!strings.Contains(err.Error(), "unknown mode 'hardlink'") {
+		t.Error("Invalid source mode must be reported")
+	}
+
+	flags.sourceMode = "copy"
+
+	copyFiles := func(expectChanges bool) {
+		_, changesMade, err := linkFilesFromSourceDir(pd, projectDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changesMade != expectChanges {
+			t.Error("Unexpected change status")
+		}
+	}
+
+	checkCopy := func(pathname, contents string, perm os.FileMode) {
+		copyPathname := path.Join(projectDir, pathname)
+		info, err := os.Lstat(copyPathname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.Mode().IsRegular() {
+			t.Error(pathname + " is not a regular file")
+		}
+		if info.Mode().Perm() != perm {
+			t.Error("Unexpected permissions of " + pathname)
+		}
+		data, err := ioutil.ReadFile(copyPathname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != contents {
+			t.Error("Unexpected contents of " + pathname)
+		}
+	}
+
+	// Create the copies.
+	copyFiles(true)
+	checkCopy("main.c", "int main;\n", 0644)
+	checkCopy("run.sh", "exit 0\n", 0755)
+
+	// Nothing has changed.
+	copyFiles(false)
+
+	// Same contents with a different modification time.
+	mtime := time.Now().Add(time.Hour)
+	if err = os.Chtimes(path.Join(sourceDir, "main.c"),
+		mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	copyFiles(false)
+
+	// Updated contents.
+	writeFileForTesting(t, path.Join(sourceDir, "main.c"),
+		"int main() {}\n")
+	copyFiles(true)
+	checkCopy("main.c", "int main() {}\n", 0644)
+	checkCopy("run.sh", "exit 0\n", 0755)
+
+	// Symbolic links left by the symlink mode are replaced.
+	flags.sourceMode = "symlink"
+	copyFiles(true)
+	flags.sourceMode = "copy"
+	copyFiles(true)
+	checkCopy("main.c", "int main() {}\n", 0644)
+}
+
+func TestSourceModeSetting(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "sourcemode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	origWorkspaceDir, origPkgPath, origSourceMode :=
+		flags.workspaceDir, flags.pkgPath, flags.sourceMode
+	defer func() {
+		flags.workspaceDir = origWorkspaceDir
+		flags.pkgPath = origPkgPath
+		flags.sourceMode = origSourceMode
+	}()
+
+	flags.workspaceDir = path.Join(tempDir, "ws")
+	flags.pkgPath = tempDir
+	flags.sourceMode = ""
+
+	if err = initWorkspace(); err != nil {
+		t.Fatal(err)
+	}
+
+	loadAndApply := func() *workspace {
+		ws, err := loadWorkspace()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = ws.applySourceModeFlag(); err != nil {
+			t.Fatal(err)
+		}
+		return ws
+	}
+
+	flags.sourceMode = "copy"
+	loadAndApply()
+
+	// Without the flag, the saved mode is used.
+	flags.sourceMode = ""
+	if ws := loadAndApply(); ws.wp.SourceMode != "copy" ||
+		flags.sourceMode != "copy" {
+		t.Error("Source mode was not saved")
+	}
+
+	flags.sourceMode = "hardlink"
+	ws, err := loadWorkspace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ws.applySourceModeFlag(); err == nil ||
+		!strings.Contains(err.Error(), "unknown mode 'hardlink'") {
+		t.Error("Invalid source mode must be reported")
+	}
+}
+
+func TestIncludeFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "include")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templateDir := path.Join(tempDir, "template")
+	sourceDir := path.Join(tempDir, "src")
+	projectDir := path.Join(tempDir, "project")
+
+	writeFileForTesting(t, path.Join(tempDir, "secret.txt"), "secret\n")
+	writeFileForTesting(t, path.Join(templateDir, partialsDirName,
+		"license.txt"), "Licensed under MIT.\n")
+	writeFileForTesting(t, path.Join(templateDir, "lib", "lib.h"),
+		`/* {{IncludeFile "../_partials/license.txt" | TrimSpace}} */`+
+			"\n")
+	writeFileForTesting(t, path.Join(sourceDir, "main.c"),
+		"int main() {}\n")
+
+	pd := &packageDefinition{
+		PackageName: "a",
+		pathname:    path.Join(sourceDir, packageDefinitionFilename),
+		params:      templateParams{}}
+
+	// Templates are parsed once more to collect parameter
+	// references when unused parameters are reported.
+	defer func(origWarnUnusedParams bool) {
+		flags.warnUnusedParams = origWarnUnusedParams
+	}(flags.warnUnusedParams)
+	flags.warnUnusedParams = true
+
+	if _, err = generateBuildFilesFromProjectTemplate(
+		templateDir, projectDir, pd, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(path.Join(projectDir, "lib", "lib.h"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "/* Licensed under MIT. */\n" {
+		t.Error("Unexpected contents: " + string(contents))
+	}
+
+	// Files outside the template directory cannot be included.
+	for relPath, expectedError := range map[string]string{
+		"../../secret.txt":     "is outside of",
+		"../../src/main.c":     "is outside of",
+		"/etc/passwd":          "is not a relative pathname",
+		"../_partials/missing": "no such file",
+	} {
+		writeFileForTesting(t, path.Join(templateDir, "lib", "lib.h"),
+			`{{IncludeFile "`+relPath+`"}}`)
+
+		_, err = generateBuildFilesFromProjectTemplate(
+			templateDir, projectDir, pd, nil)
+		if err == nil || !strings.Contains(err.Error(), expectedError) {
+			t.Error("Unexpected result of including " + relPath)
+		}
+	}
+
+	// Embedded templates include files from the package sources.
+	result, err := executePackageFileTemplate("", "test",
+		[]byte(`{{IncludeFile "main.c"}}`), pd, nil, nil, nil,
+		[]outputFileParams{{"test", nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result[0].contents) != "int main() {}\n" {
+		t.Error("Unexpected contents: " + string(result[0].contents))
+	}
+}
+
+func TestStrictTemplates(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "strict")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templateDir := path.Join(tempDir, "template")
+	sourceDir := path.Join(tempDir, "src")
+	projectDir := path.Join(tempDir, "project")
+
+	writeFileForTesting(t, path.Join(templateDir, "README"),
+		"{{.name}} {{.verison}}\n")
+	writeFileForTesting(t, path.Join(sourceDir, "main.c"),
+		"int main() {}\n")
+
+	pd := &packageDefinition{
+		PackageName: "a",
+		pathname:    path.Join(sourceDir, packageDefinitionFilename),
+		params:      templateParams{"name": "hello", "version": "1.0"}}
+
+	defer func(origStrictTemplates bool) {
+		flags.strictTemplates = origStrictTemplates
+	}(flags.strictTemplates)
+
+	// The default mode is lenient.
+	flags.strictTemplates = false
+
+	if _, err = generateBuildFilesFromProjectTemplate(
+		templateDir, projectDir, pd, nil); err != nil {
+		t.Fatal(err)
+	}
+	contents, err := ioutil.ReadFile(path.Join(projectDir, "README"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "hello <no value>\n" {
+		t.Error("Unexpected contents: " + string(contents))
+	}
+
+	flags.strictTemplates = true
+
+	_, err = generateBuildFilesFromProjectTemplate(
+		templateDir, projectDir, pd, nil)
+	if err == nil {
+		t.Fatal("Missing parameter was not reported")
+	}
+	for _, expected := range []string{"README", `"verison"`} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Error("Error message does not contain " + expected +
+				": " + err.Error())
+		}
+	}
+
+	// Embedded templates are not affected.
+	if _, err = executePackageFileTemplate("", "test",
+		[]byte(`{{if .optional}}{{.optional}}{{end}}`), pd, nil, nil,
+		nil, []outputFileParams{{"test", pd.params}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAlternativeDelimiters(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "delims")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templateDir := path.Join(tempDir, "template")
+	sourceDir := path.Join(tempDir, "src")
+	projectDir := path.Join(tempDir, "project")
+
+	writeFileForTesting(t, path.Join(templateDir, partialsDirName,
+		"greeting"), "Hello from {{.name}}")
+	writeFileForTesting(t, path.Join(templateDir, "{name}.j2.tmpl2"),
+		`# <% template "greeting" . %>
+<% range .hosts %>{{ <% . %> | upper }}
+<% end %>`)
+	writeFileForTesting(t, path.Join(sourceDir, "main.c"),
+		"int main() {}\n")
+
+	pd := &packageDefinition{
+		PackageName: "a",
+		pathname:    path.Join(sourceDir, packageDefinitionFilename),
+		params: templateParams{"name": "site",
+			"hosts": []interface{}{"alpha", "beta"}}}
+
+	if _, err = generateBuildFilesFromProjectTemplate(
+		templateDir, projectDir, pd, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The pathname template is expanded as usual.
+	contents, err := ioutil.ReadFile(path.Join(projectDir, "site.j2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "# Hello from site\n"+
+		"{{ alpha | upper }}\n{{ beta | upper }}\n" {
+		t.Error("Unexpected contents: " + string(contents))
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// removeOrphanedBuildDirs removes the subdirectories of 'buildDir'
+// that do not belong to any of the selected packages. Because the
+// build directory can be shared with other data, only the build
+// directories of the packages from the package index and the
+// directories that contain the output of a configure script are
+// removed. Symbolic links and regular files are left intact. The
+// function returns the pathnames of the removed directories.
+func removeOrphanedBuildDirs(buildDir string, pi *packageIndex,
+	selection packageDefinitionList) ([]string, error) {
+	buildDir, err := filepath.Abs(buildDir)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]bool)
+	for _, pd := range selection {
+		selected[pd.PackageName] = true
+	}
+
+	dirEntries, err := ioutil.ReadDir(buildDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var removed []string
+
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() || selected[dirEntry.Name()] {
+			continue
+		}
+
+		orphan := path.Join(buildDir, dirEntry.Name())
+
+		if pi.packageByName[dirEntry.Name()] == nil {
+			_, err = os.Stat(path.Join(orphan, "config.status"))
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return removed, err
+			}
+		}
+
+		if err = os.RemoveAll(orphan); err != nil {
+			return removed, err
+		}
+
+		removed = append(removed, orphan)
+	}
+
+	return removed, nil
+}
+
+// removeStaleLinks removes the symbolic links in the directory with
+// generated package sources that either point to nonexistent files or
+// belong to packages that are no longer selected. The directories left
+// empty are removed as well. Symbolic links are never followed. The
+// function returns the pathnames of the removed links.
+func removeStaleLinks(pkgRootDir string,
+	selection packageDefinitionList) ([]string, error) {
+	selected := make(map[string]bool)
+	for _, pd := range selection {
+		selected[pd.PackageName] = true
+	}
+
+	if _, err := os.Lstat(pkgRootDir); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	var staleLinks []string
+
+	err := filepath.Walk(pkgRootDir, func(pathname string,
+		info os.FileInfo, err error) error {
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			return err
+		}
+
+		rel, err := filepath.Rel(pkgRootDir, pathname)
+		if err != nil {
+			return err
+		}
+
+		pkgName := strings.SplitN(rel, "/", 2)[0]
+
+		if _, err = os.Stat(pathname); err != nil || !selected[pkgName] {
+			staleLinks = append(staleLinks, pathname)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+
+	for _, link := range staleLinks {
+		if err = os.Remove(link); err != nil {
+			return removed, err
+		}
+		removed = append(removed, link)
+
+		// Remove the parent directories that became empty.
+		for dir := filepath.Dir(link); dir != pkgRootDir &&
+			isInsideDir(pkgRootDir, dir); dir = filepath.Dir(dir) {
+			dirEntries, err := ioutil.ReadDir(dir)
+			if err != nil || len(dirEntries) > 0 {
+				break
+			}
+			if err = os.Remove(dir); err != nil {
+				return removed, err
+			}
+		}
+	}
+
+	return removed, nil
+}
+
+func pruneOrphans() error {
+	ws, err := loadWorkspace()
+	if err != nil {
+		return err
+	}
+
+	wl, err := ws.lock()
+	if err != nil {
+		return err
+	}
+	defer wl.unlock()
+
+	pi, err := readPackageDefinitions(ws.wp)
+	if err != nil {
+		return err
+	}
+
+	selection, err := readPackageSelection(pi, ws.absPrivateDir)
+	if err != nil {
+		return err
+	}
+
+	removed, err := removeOrphanedBuildDirs(ws.buildDir(), pi,
+		selection)
+
+	if err == nil && flags.deep {
+		var removedLinks []string
+		removedLinks, err = removeStaleLinks(
+			ws.generatedPkgRootDir(), selection)
+		removed = append(removed, removedLinks...)
+	}
+
+	if !flags.quiet {
+		for _, pathname := range removed {
+			fmt.Println("D", ws.relativeToWorkspace(pathname))
+		}
+	}
+
+	return err
+}
+
+// pruneOrphansCmd represents the prune-orphans command
+var pruneOrphansCmd = &cobra.Command{
+	Use:   "prune-orphans",
+	Short: "Remove build directories of deselected packages",
+	Args:  cobra.MaximumNArgs(0),
+	Run: func(_ *cobra.Command, _ []string) {
+		if err := pruneOrphans(); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pruneOrphansCmd)
+
+	pruneOrphansCmd.Flags().SortFlags = false
+	addQuietFlag(pruneOrphansCmd)
+	addWorkspaceDirFlag(pruneOrphansCmd)
+	addDeepFlag(pruneOrphansCmd)
+}
@@ -0,0 +1,143 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestRemoveOrphanedBuildDirs(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "prune")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	buildDir := path.Join(tempDir, "build")
+	outsideDir := path.Join(tempDir, "outside")
+
+	for _, pkgName := range []string{"a", "b", "c"} {
+		writeFileForTesting(t, path.Join(buildDir, pkgName,
+			"Makefile"), "all:\n")
+	}
+	// Directories of packages that are no longer in the index
+	// are recognized by the output of configure. Other
+	// directories are not touched.
+	writeFileForTesting(t, path.Join(buildDir, "e", "config.status"),
+		"#!/bin/sh\n")
+	writeFileForTesting(t, path.Join(buildDir, "data", "file"), "keep\n")
+	writeFileForTesting(t, path.Join(buildDir, "notes.txt"), "keep\n")
+	writeFileForTesting(t, path.Join(outsideDir, "file"), "keep\n")
+
+	if err = os.Symlink(outsideDir, path.Join(buildDir, "d")); err != nil {
+		t.Fatal(err)
+	}
+
+	pi, err := makePackageIndexForTesting([]string{"a", "b:a", "c"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := removeOrphanedBuildDirs(buildDir, pi,
+		packageDefinitionList{pi.packageByName["b"]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range removed {
+		removed[i] = strings.TrimPrefix(removed[i], buildDir+"/")
+	}
+	if names := strings.Join(removed, ", "); names != "a, c, e" {
+		t.Error("Unexpected list of removed directories: " + names)
+	}
+
+	if files := listFilesForTesting(t,
+		buildDir); files != "b/Makefile, d, data/file, notes.txt" {
+		t.Error("Unexpected build directory contents: " + files)
+	}
+
+	if _, err = os.Stat(path.Join(outsideDir, "file")); err != nil {
+		t.Error("File outside the build directory was removed")
+	}
+}
+
+func TestRemoveStaleLinks(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	sourceDir := path.Join(tempDir, "src")
+	pkgRootDir := path.Join(tempDir, "packages")
+
+	for _, pathname := range []string{"a/main.c", "a/lib/util.c",
+		"a/lib/gone.c", "b/b.c"} {
+		writeFileForTesting(t, path.Join(sourceDir, pathname), "")
+		linkPathname := path.Join(pkgRootDir, pathname)
+		if err = os.MkdirAll(path.Dir(linkPathname), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.Symlink(path.Join(sourceDir, pathname),
+			linkPathname); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFileForTesting(t, path.Join(pkgRootDir, "a", "Makefile.am"), "")
+
+	// A link to a directory must not be followed.
+	if err = os.Symlink(sourceDir, path.Join(pkgRootDir, "a",
+		"tree")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.Remove(path.Join(sourceDir, "a", "lib",
+		"gone.c")); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Remove(path.Join(sourceDir, "a", "lib",
+		"util.c")); err != nil {
+		t.Fatal(err)
+	}
+
+	pi, err := makePackageIndexForTesting([]string{"a", "b"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := removeStaleLinks(pkgRootDir,
+		packageDefinitionList{pi.packageByName["a"]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range removed {
+		removed[i] = strings.TrimPrefix(removed[i], pkgRootDir+"/")
+	}
+	if links := strings.Join(removed, ", "); links !=
+		"a/lib/gone.c, a/lib/util.c, b/b.c" {
+		t.Error("Unexpected list of removed links: " + links)
+	}
+
+	if files := listFilesForTesting(t, pkgRootDir); files !=
+		"a/Makefile.am, a/main.c, a/tree" {
+		t.Error("Unexpected package directory contents: " + files)
+	}
+
+	for _, dir := range []string{"a/lib", "b"} {
+		if _, err = os.Stat(path.Join(pkgRootDir,
+			dir)); !os.IsNotExist(err) {
+			t.Error("Empty directory was not removed: " + dir)
+		}
+	}
+
+	if _, err = os.Stat(path.Join(sourceDir, "b", "b.c")); err != nil {
+		t.Error("Link target was removed")
+	}
+}
@@ -57,6 +57,20 @@ func refreshWorkspace() error {
 		return err
 	}
 
+	wl, err := ws.lock()
+	if err != nil {
+		return err
+	}
+	defer wl.unlock()
+
+	if err = ws.applyBuildDirFlag(); err != nil {
+		return err
+	}
+
+	if err = ws.applySourceModeFlag(); err != nil {
+		return err
+	}
+
 	pi, err := readPackageDefinitions(ws.wp)
 	if err != nil {
 		return err
@@ -94,5 +108,13 @@ func init() {
 	refreshCmd.Flags().SortFlags = false
 	addQuietFlag(refreshCmd)
 	addWorkspaceDirFlag(refreshCmd)
+	addBuildDirFlag(refreshCmd)
 	addNoBootstrapFlag(refreshCmd)
+	addForceFlag(refreshCmd)
+	addWarnUnusedParamsFlag(refreshCmd)
+	addEnvFlag(refreshCmd)
+	addExcludePatternFlag(refreshCmd)
+	addFsRetriesFlag(refreshCmd)
+	addSourceModeFlag(refreshCmd)
+	addIncrementalFlag(refreshCmd)
 }
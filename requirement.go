@@ -0,0 +1,122 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// versionConstraint restricts the acceptable versions
+// of a required package, as in 'name >= 1.2.0'.
+type versionConstraint struct {
+	pkgName  string
+	operator string
+	version  string
+}
+
+var requirementRegexp = regexp.MustCompile(
+	`^\s*([^\s<>=!]+)\s*(?:(>=|<=|==|!=|>|<|=)\s*([0-9][^\s<>=!]*))?\s*$`)
+
+// parseRequirement splits an entry of the 'requires' list into
+// the package name and an optional version constraint. The
+// returned constraint is nil if the entry consists of just the
+// package name.
+func parseRequirement(entry string) (string, *versionConstraint, error) {
+	match := requirementRegexp.FindStringSubmatch(entry)
+	if match == nil {
+		return "", nil, errors.New("invalid requirement '" + entry +
+			"': expected 'name' or 'name OP version'")
+	}
+
+	if match[2] == "" {
+		return match[1], nil, nil
+	}
+
+	return match[1], &versionConstraint{match[1], match[2], match[3]},
+		nil
+}
+
+// requiredPackageName returns the package name part of
+// a 'requires' entry.
+func requiredPackageName(entry string) string {
+	pkgName, _, err := parseRequirement(entry)
+	if err != nil {
+		return entry
+	}
+	return pkgName
+}
+
+// pkgConfigRequirement converts a 'requires' entry to the syntax
+// of the 'Requires' field of pkg-config files, which uses '='
+// for equality and expects spaces around the operator.
+func pkgConfigRequirement(entry string) string {
+	pkgName, constraint, err := parseRequirement(entry)
+	if err != nil {
+		return entry
+	}
+	if constraint == nil {
+		return pkgName
+	}
+
+	operator := constraint.operator
+	if operator == "==" {
+		operator = "="
+	}
+
+	return pkgName + " " + operator + " " + constraint.version
+}
+
+// compareVersions compares two dotted version strings component
+// by component. Numeric parts of the components are compared
+// numerically. Missing components are treated as zeros, so "1.2"
+// and "1.2.0" are equal. The result is negative, zero, or positive
+// if 'a' is less than, equal to, or greater than 'b'.
+func compareVersions(a, b string) int {
+	componentsA := strings.Split(a, ".")
+	componentsB := strings.Split(b, ".")
+
+	for len(componentsA) < len(componentsB) {
+		componentsA = append(componentsA, "0")
+	}
+	for len(componentsB) < len(componentsA) {
+		componentsB = append(componentsB, "0")
+	}
+
+	for i := range componentsA {
+		if naturalLess(componentsA[i], componentsB[i]) {
+			return -1
+		}
+		if naturalLess(componentsB[i], componentsA[i]) {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// satisfiedBy returns true if the version meets the constraint.
+func (vc *versionConstraint) satisfiedBy(version string) bool {
+	result := compareVersions(version, vc.version)
+
+	switch vc.operator {
+	case ">=":
+		return result >= 0
+	case "<=":
+		return result <= 0
+	case ">":
+		return result > 0
+	case "<":
+		return result < 0
+	case "!=":
+		return result != 0
+	}
+	return result == 0
+}
+
+func (vc *versionConstraint) String() string {
+	return vc.pkgName + " " + vc.operator + " " + vc.version
+}
@@ -0,0 +1,200 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestParseRequirement(t *testing.T) {
+	for _, testCase := range []struct {
+		entry, pkgName, constraint string
+	}{
+		{"base", "base", ""},
+		{" base ", "base", ""},
+		{"base >= 1.2.0", "base", "base >= 1.2.0"},
+		{"base>=1.2.0", "base", "base >= 1.2.0"},
+		{"libc++ < 2", "libc++", "libc++ < 2"},
+		{"base == 1.0-rc1", "base", "base == 1.0-rc1"},
+	} {
+		pkgName, constraint, err := parseRequirement(testCase.entry)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if pkgName != testCase.pkgName {
+			t.Error("Unexpected package name: " + pkgName)
+		}
+		if constraint == nil {
+			if testCase.constraint != "" {
+				t.Error("Constraint not parsed: " +
+					testCase.entry)
+			}
+		} else if constraint.String() != testCase.constraint {
+			t.Error("Unexpected constraint: " + constraint.String())
+		}
+	}
+
+	for _, malformed := range []string{
+		"", "base >=", ">= 1.2.0", "base ~> 1.2", "base >= 1.2 extra",
+		"base >= >= 1.2", "base >= x1.2", "base 1.2"} {
+		if _, _, err := parseRequirement(malformed); err == nil {
+			t.Error("Malformed requirement accepted: '" +
+				malformed + "'")
+		}
+	}
+}
+
+func TestPkgConfigRequirement(t *testing.T) {
+	for _, testCase := range []struct{ entry, expected string }{
+		{"base", "base"},
+		{"base==1.2", "base = 1.2"},
+		{"base = 1.2", "base = 1.2"},
+		{"base>=1.2", "base >= 1.2"},
+		{"base <= 2", "base <= 2"},
+		{"base != 1.3", "base != 1.3"},
+	} {
+		if result := pkgConfigRequirement(
+			testCase.entry); result != testCase.expected {
+			t.Error("Unexpected pkg-config requirement: " + result)
+		}
+	}
+
+	pd := &packageDefinition{PackageName: "client", params: templateParams{
+		"requires": []interface{}{"base==1.2", "util >= 0.1"}}}
+
+	result, err := executePackageFileTemplate("", "client.pc",
+		[]byte(`{{template "PkgConfigRequires" .}}`), pd, nil,
+		nil, nil, []outputFileParams{{"client.pc", pd.params}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "Requires: base = 1.2, util >= 0.1\n"; string(
+		result[0].contents) != expected {
+		t.Error("Unexpected Requires field: " +
+			string(result[0].contents))
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, testCase := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.2.0", "1.2.0", 0},
+		{"1.2", "1.2.0", 0},
+		{"1.10", "1.9", 1},
+		{"1.2.0", "1.2.1", -1},
+		{"2", "1.99.99", 1},
+		{"1.0rc1", "1.0rc2", -1},
+	} {
+		if result := compareVersions(testCase.a,
+			testCase.b); result != testCase.expected {
+			t.Errorf("compareVersions(%q, %q) = %d",
+				testCase.a, testCase.b, result)
+		}
+	}
+}
+
+func makeVersionedPackagesForTesting(baseVersion interface{},
+	requires ...interface{}) (*packageIndex, error) {
+	var packages packageDefinitionList
+	var dependencies [][]string
+
+	for _, params := range []templateParams{
+		{"name": "base", "version": baseVersion},
+		{"name": "client", "version": "0.1",
+			"requires": requires},
+	} {
+		params["description"] = "Test package"
+		params["type"] = "lib"
+
+		pd, deps, err := newPackageDefinition(
+			params["name"].(string)+".yaml", params)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, pd)
+		dependencies = append(dependencies, deps)
+	}
+
+	return buildPackageIndex(true, packages, dependencies)
+}
+
+func TestVersionConstraints(t *testing.T) {
+	for _, satisfied := range []string{
+		"base", "base >= 1.2.0", "base >= 1.2", "base > 1.1.9",
+		"base < 1.10", "base == 1.2", "base != 1.3"} {
+		pi, err := makeVersionedPackagesForTesting("1.2.0",
+			satisfied)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		client, _ := pi.getPackageByName("client")
+		if names := packageNames(client.required); names != "base" {
+			t.Error("Unexpected dependencies: " + names)
+		}
+	}
+
+	for _, violated := range []string{
+		"base >= 1.3", "base > 1.2.0", "base < 1.2", "base == 1.1",
+		"base != 1.2.0"} {
+		_, err := makeVersionedPackagesForTesting("1.2.0",
+			violated)
+		if err == nil {
+			t.Error("Constraint violation was not detected: " +
+				violated)
+		} else if !strings.Contains(err.Error(),
+			"package client requires "+violated+
+				", but version 1.2.0 is available") {
+			t.Error("Unexpected error: " + err.Error())
+		}
+	}
+
+	_, err := makeVersionedPackagesForTesting("1.2.0", "base >=")
+	if err == nil || !strings.Contains(err.Error(),
+		"client.yaml: invalid requirement 'base >='") {
+		t.Error("Malformed requirement was not reported")
+	}
+
+	// Unquoted versions are decoded as numbers. Integers are
+	// accepted, but floating point numbers would lose trailing
+	// zeros.
+	var intVersion, floatVersion interface{}
+	if err = yaml.Unmarshal([]byte("2"), &intVersion); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = makeVersionedPackagesForTesting(intVersion,
+		"base >= 2", "base < 2.1"); err != nil {
+		t.Error(err)
+	}
+
+	if err = yaml.Unmarshal([]byte("1.10"), &floatVersion); err != nil {
+		t.Fatal(err)
+	}
+	_, err = makeVersionedPackagesForTesting(floatVersion, "base >= 1.5")
+	if err == nil || !strings.Contains(err.Error(),
+		"base.yaml: 'version' is read as a floating point number; "+
+			"quote the version") {
+		t.Error("Unquoted floating point version was not reported")
+	}
+
+	_, err = makeVersionedPackagesForTesting(nil, "base >= 1.2")
+	if err == nil || !strings.Contains(err.Error(),
+		"base.yaml: missing required field 'version'") {
+		t.Error("Missing version was not reported")
+	}
+
+	_, err = makeVersionedPackagesForTesting([]interface{}{1, 2},
+		"base >= 1.2")
+	if err == nil || !strings.Contains(err.Error(),
+		"base.yaml: 'version' field must be a string or a number") {
+		t.Error("Invalid version was not reported")
+	}
+}
@@ -5,6 +5,8 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"log"
 	"os"
 	"path"
@@ -75,6 +77,15 @@ func packageRangesToFlatSelection(pi *packageIndex, args []string) (
 			continue
 		}
 
+		// '^pkg' is an alias of ':pkg' (the package and everything
+		// it requires) and 'pkg^' is an alias of 'pkg:' (the package
+		// and everything that depends on it).
+		if strings.HasPrefix(arg, "^") {
+			arg = ":" + arg[1:]
+		} else if strings.HasSuffix(arg, "^") {
+			arg = arg[:len(arg)-1] + ":"
+		}
+
 		var pkgRange packageDefinitionList
 
 		emptyRange := true
@@ -127,12 +138,111 @@ func packageRangesToFlatSelection(pi *packageIndex, args []string) (
 	return selection, nil
 }
 
+// canonicalPackageType returns the short form of a package type.
+func canonicalPackageType(packageType string) string {
+	switch packageType {
+	case "application":
+		return "app"
+	case "library":
+		return "lib"
+	}
+	return packageType
+}
+
+// filterByPackageType returns the packages of the selection that have
+// the specified type. An empty type leaves the selection unchanged.
+// If none of the packages have the type, an error is returned rather
+// than an empty selection, which would remove the generated files of
+// all previously selected packages.
+func filterByPackageType(selection packageDefinitionList,
+	packageType string) (packageDefinitionList, error) {
+	if packageType == "" {
+		return selection, nil
+	}
+
+	if getEmbeddedTemplate(packageType) == nil {
+		return nil, errors.New("--type: unknown package type '" +
+			packageType + "'")
+	}
+
+	canonicalType := canonicalPackageType(packageType)
+
+	var filtered packageDefinitionList
+
+	for _, pd := range selection {
+		if canonicalPackageType(pd.packageType) == canonicalType {
+			filtered = append(filtered, pd)
+		}
+	}
+
+	if len(filtered) == 0 {
+		return nil, errors.New("no selected packages of type '" +
+			packageType + "'")
+	}
+
+	return filtered, nil
+}
+
+// readPackageRanges reads package range arguments from a file.
+// Ranges are separated by whitespace; everything after a '#'
+// on the same line is ignored.
+func readPackageRanges(pathname string) (args []string, err error) {
+	file, err := os.Open(pathname)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		closeErr := file.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+		args = append(args, strings.Fields(line)...)
+	}
+
+	return args, scanner.Err()
+}
+
 func selectPackages(args []string) error {
+	if flags.fromFile != "" {
+		fileArgs, err := readPackageRanges(flags.fromFile)
+		if err != nil {
+			return err
+		}
+		args = append(fileArgs, args...)
+	}
+
+	if len(args) == 0 {
+		return errors.New("no package ranges given")
+	}
+
 	ws, err := loadWorkspace()
 	if err != nil {
 		return err
 	}
 
+	wl, err := ws.lock()
+	if err != nil {
+		return err
+	}
+	defer wl.unlock()
+
+	if err = ws.applyBuildDirFlag(); err != nil {
+		return err
+	}
+
+	if err = ws.applySourceModeFlag(); err != nil {
+		return err
+	}
+
 	pi, err := readPackageDefinitions(ws.wp)
 	if err != nil {
 		return err
@@ -143,6 +253,11 @@ func selectPackages(args []string) error {
 		return err
 	}
 
+	selection, err = filterByPackageType(selection, flags.typeFilter)
+	if err != nil {
+		return err
+	}
+
 	conftab, err := readConftab(path.Join(ws.absPrivateDir,
 		conftabFilename))
 	if err != nil {
@@ -159,7 +274,6 @@ func selectPackages(args []string) error {
 var selectCmd = &cobra.Command{
 	Use:   "select package_range...",
 	Short: "Choose one or more packages to work on",
-	Args:  cobra.MinimumNArgs(1),
 	Run: func(_ *cobra.Command, args []string) {
 		if err := selectPackages(args); err != nil {
 			log.Fatal(err)
@@ -174,5 +288,15 @@ func init() {
 	addQuietFlag(selectCmd)
 	addPkgPathFlag(selectCmd)
 	addWorkspaceDirFlag(selectCmd)
+	addBuildDirFlag(selectCmd)
 	addNoBootstrapFlag(selectCmd)
+	addForceFlag(selectCmd)
+	addWarnUnusedParamsFlag(selectCmd)
+	addEnvFlag(selectCmd)
+	addExcludePatternFlag(selectCmd)
+	addFsRetriesFlag(selectCmd)
+	addSourceModeFlag(selectCmd)
+	addIncrementalFlag(selectCmd)
+	addFromFileFlag(selectCmd)
+	addTypeFilterFlag(selectCmd)
 }
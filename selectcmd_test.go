@@ -0,0 +1,143 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestPackageRangesFromFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "ranges")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	pathname := path.Join(tempDir, "selection")
+	writeFileForTesting(t, pathname, `# Core packages
+:c  # everything 'c' depends on
+
+b:d
+-
+   c:
+`)
+
+	args, err := readPackageRanges(pathname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pi, err := makePackageIndexForTesting([]string{
+		"a", "b:a", "c:b", "d:b", "e"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ranges from the command line follow those from the file.
+	selection, err := packageRangesToFlatSelection(pi,
+		append(args, "+", "e"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := packageNames(selection); names != "a, b, d, e" {
+		t.Error("Unexpected selection: " + names)
+	}
+
+	if _, err = readPackageRanges(path.Join(tempDir,
+		"missing")); err == nil {
+		t.Error("Missing file was not reported")
+	}
+}
+
+func TestCaretSelectionAliases(t *testing.T) {
+	pi, err := makePackageIndexForTesting([]string{
+		"a", "b:a", "c:b", "d:b", "e"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	selectionNames := func(args ...string) string {
+		selection, err := packageRangesToFlatSelection(pi, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return packageNames(selection)
+	}
+
+	for _, testCase := range []struct {
+		alias, rangeForm []string
+		expected         string
+	}{
+		{[]string{"^c"}, []string{":c"}, "a, b, c"},
+		{[]string{"b^"}, []string{"b:"}, "b, c, d"},
+		{[]string{"a^", "-", "^d"}, []string{"a:", "-", ":d"}, "c"},
+	} {
+		if names := selectionNames(testCase.alias...); names !=
+			testCase.expected {
+			t.Error("Unexpected selection: " + names)
+		}
+		if names := selectionNames(testCase.rangeForm...); names !=
+			testCase.expected {
+			t.Error("Unexpected selection: " + names)
+		}
+	}
+}
+
+func TestPackageTypeFilter(t *testing.T) {
+	pi, err := makePackageIndexForTesting([]string{
+		"a", "b:a", "c:b", "d:b", "e"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pd := range pi.orderedPackages {
+		switch pd.PackageName {
+		case "a", "b":
+			pd.packageType = "library"
+		case "d":
+			pd.packageType = "lib"
+		default:
+			pd.packageType = "application"
+		}
+	}
+
+	selection, err := packageRangesToFlatSelection(pi,
+		[]string{"a:", "-", "d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testCase := range []struct {
+		packageType, expected string
+	}{
+		{"", "a, b, c"},
+		{"library", "a, b"},
+		{"lib", "a, b"},
+		{"app", "c"},
+	} {
+		filtered, err := filterByPackageType(selection,
+			testCase.packageType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names := packageNames(filtered); names != testCase.expected {
+			t.Error("Unexpected selection: " + names)
+		}
+	}
+
+	if _, err = filterByPackageType(selection, "plugin"); err == nil {
+		t.Error("Unknown package type was not reported")
+	}
+
+	_, err = filterByPackageType(packageDefinitionList{selection[2]},
+		"library")
+	if err == nil || err.Error() != "no selected packages of type 'library'" {
+		t.Error("Empty filtered selection was not reported")
+	}
+}
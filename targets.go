@@ -8,6 +8,9 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
 type target struct {
@@ -17,24 +20,51 @@ type target struct {
 	MakeScript   string
 }
 
+// builtinTargetNames contains the names of the global targets
+// that every generated makefile defines. Custom targets declared
+// in package definitions may not reuse these names.
+var builtinTargetNames = map[string]bool{
+	"default":   true,
+	"all":       true,
+	"help":      true,
+	"bootstrap": true,
+	"configure": true,
+	"build":     true,
+	"check":     true,
+	"install":   true,
+	"uninstall": true,
+	"dist":      true,
+	"clean":     true,
+	"rebuild":   true,
+	"describe":  true,
+	"format":    true,
+	"lint":      true,
+}
+
 type makefileTargetCollector struct {
 	ws               *workspace
+	conftab          *Conftab
 	relBuildDir      string
 	pkgRootDir       string
 	selection        packageDefinitionList
 	selectedDeps     map[*packageDefinition]packageDefinitionList
+	selectedDepnts   map[*packageDefinition]packageDefinitionList
 	globalTargetDeps []string
 	targets          []target
+	err              error
 }
 
+// createMakefileTargets returns the targets of the top-level build
+// file. The collector records an error instead of stopping, so the
+// error is returned along with the targets collected so far.
 func createMakefileTargets(ws *workspace, selection packageDefinitionList,
-	pi *packageIndex) []target {
+	pi *packageIndex, conftab *Conftab) ([]target, error) {
 
 	selectedDeps := establishDependenciesInSelection(selection, pi)
 
 	dependentOnSelected := map[*packageDefinition]packageDefinitionList{}
-	for pd, deps := range selectedDeps {
-		for _, dep := range deps {
+	for _, pd := range selection {
+		for _, dep := range selectedDeps[pd] {
 			dependentOnSelected[dep] = append(
 				dependentOnSelected[dep], pd)
 		}
@@ -48,28 +78,43 @@ func createMakefileTargets(ws *workspace, selection packageDefinitionList,
 		}
 	}
 
-	mtc := &makefileTargetCollector{ws,
+	mtc := &makefileTargetCollector{ws, conftab,
 		ws.buildDirRelativeToWorkspace(),
 		ws.pkgRootDirRelativeToWorkspace(),
-		selection, selectedDeps, globalTargetDeps, nil}
+		selection, selectedDeps, dependentOnSelected,
+		globalTargetDeps, nil, nil}
 
 	mtc.addHelpTarget()
+	mtc.addDescribeTarget()
 	mtc.addBootstrapTargets()
 	mtc.addConfigureTargets()
 	mtc.addBuildTargets()
 	mtc.addCheckTargets()
 	mtc.addInstallTargets()
+	mtc.addUninstallTargets()
 	mtc.addDistTargets()
+	mtc.addCleanTargets()
+	mtc.addRebuildTarget()
+	mtc.addFormatTargets()
+	mtc.addLintTargets()
+	mtc.addCustomTargets()
+
+	return mtc.targets, mtc.err
+}
 
-	return mtc.targets
+// buildDirFor returns the directory where the package is configured
+// and built: either its own subdirectory of the build directory or,
+// for in-tree builds, the generated package directory itself.
+func (mtc *makefileTargetCollector) buildDirFor(pd *packageDefinition) string {
+	return pd.pkgBuildDir(mtc.relBuildDir, mtc.pkgRootDir)
 }
 
 func (mtc *makefileTargetCollector) makefileFor(pd *packageDefinition) string {
-	return path.Join(mtc.relBuildDir, pd.PackageName, "Makefile")
+	return path.Join(mtc.buildDirFor(pd), "Makefile")
 }
 
 func (mtc *makefileTargetCollector) configureFor(pd *packageDefinition) string {
-	return path.Join(mtc.pkgRootDir, pd.PackageName, "configure")
+	return pd.configurePathname(path.Join(mtc.pkgRootDir, pd.PackageName))
 }
 
 func (mtc *makefileTargetCollector) addTarget(name string, phony bool,
@@ -79,6 +124,11 @@ func (mtc *makefileTargetCollector) addTarget(name string, phony bool,
 }
 
 func (mtc *makefileTargetCollector) addHelpTarget() {
+	installDir := mtc.ws.installDir()
+	if mtc.ws.wp.WorkspaceRelative {
+		installDir = mtc.ws.relativeToWorkspace(installDir)
+	}
+
 	mtc.addTarget("help", true, nil,
 		`	@echo "Usage:"
 	@echo "    make [target...]"
@@ -110,21 +160,141 @@ func (mtc *makefileTargetCollector) addHelpTarget() {
 	@echo
 	@echo "    install"
 	@echo "        Install package binaries and library headers into"
-	@echo "        '`+mtc.ws.installDir()+`'."
+	@echo "        '`+installDir+`'."
+	@echo
+	@echo "    uninstall"
+	@echo "        Remove the installed files of the selected packages"
+	@echo "        from '`+installDir+`'."
 	@echo
 	@echo "    dist"
 	@echo "        Create distribution tarballs and move them to the"
 	@echo "        'dist' subdirectory of the workspace."
 	@echo
-`)
+	@echo "    clean"
+	@echo "        Remove the build artifacts of the selected packages."
+	@echo
+	@echo "    rebuild"
+	@echo "        Clean the selected packages and build them again."
+	@echo
+	@echo "    describe"
+	@echo "        Display the type, version, and description of"
+	@echo "        each selected package."
+	@echo
+	@echo "    format"
+	@echo "        Reformat the source files of the selected packages"
+	@echo "        in their original source directories."
+	@echo
+	@echo "    lint"
+	@echo "        Run static analysis on the source files of the"
+	@echo "        selected packages. The linter is set by the"
+	@echo "        '--linter' and '--linter-args' options in the"
+	@echo "        global section of the conftab."
+	@echo
+`+mtc.customTargetHelp())
+}
+
+// shellQuote encloses the argument in single quotes
+// so that the shell does not interpret it.
+func shellQuote(arg string) string {
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
+
+// echoCommand returns a recipe line that prints the specified text
+// verbatim. The text is quoted for the shell and escaped for make.
+func echoCommand(text string) string {
+	if text == "" {
+		return "\t@echo\n"
+	}
+	return "\t@echo " + strings.Replace(shellQuote(text),
+		"$", "$$", -1) + "\n"
+}
+
+func (mtc *makefileTargetCollector) addDescribeTarget() {
+	var script string
+
+	for _, pd := range mtc.selection {
+		line := pd.PackageName + " (" + pd.packageType + ")"
+		if version, ok := pd.params["version"]; ok {
+			line += " " + fmt.Sprint(version)
+		}
+		script += echoCommand(line)
+
+		description := strings.TrimRight(wrapText(pd.description), "\n")
+		if description != "" {
+			for _, descLine := range strings.Split(description, "\n") {
+				script += echoCommand("    " + descLine)
+			}
+		}
+	}
+
+	mtc.addTarget("describe", true, nil, script)
 }
 
+// customTargetNames returns the sorted list of distinct
+// custom target names declared by the selected packages.
+func (mtc *makefileTargetCollector) customTargetNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+
+	for _, pd := range mtc.selection {
+		for _, ct := range pd.targets {
+			if !seen[ct.name] {
+				seen[ct.name] = true
+				names = append(names, ct.name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func (mtc *makefileTargetCollector) customTargetHelp() string {
+	names := mtc.customTargetNames()
+	if len(names) == 0 {
+		return ""
+	}
+
+	help := "\t@echo \"Custom targets:\"\n"
+
+	for _, name := range names {
+		var definedBy packageDefinitionList
+		for _, pd := range mtc.selection {
+			for _, ct := range pd.targets {
+				if ct.name == name {
+					definedBy = append(definedBy, pd)
+				}
+			}
+		}
+
+		help += "\t@echo \"    " + name + "\"\n" +
+			"\t@echo \"        Defined by: " +
+			packageNames(definedBy) + "\"\n\t@echo\n"
+	}
+
+	return help
+}
+
+// selfPathnameRelativeToWorkspace returns the command that the
+// generated makefile uses to invoke this program. The command
+// can be overridden in the workspace settings.
 func selfPathnameRelativeToWorkspace(ws *workspace) string {
+	if ws.wp.Command != "" {
+		return ws.wp.Command
+	}
+
 	executable, err := os.Executable()
 	if err != nil {
 		return appName
 	}
 
+	// A relocatable workspace cannot refer to an
+	// executable outside of it by a fixed pathname.
+	if ws.wp.WorkspaceRelative && !isInsideDir(ws.absDir, executable) {
+		return appName
+	}
+
 	return ws.relativeToWorkspace(executable)
 }
 
@@ -143,7 +313,7 @@ func (mtc *makefileTargetCollector) addBootstrapTargets() {
 func (mtc *makefileTargetCollector) addConfigureTargets() {
 	relativeConftabPathname := path.Join(privateDirName, conftabFilename)
 
-	cmd := "\t@" + selfPathnameRelativeToWorkspace(mtc.ws) + " configure "
+	cmd := selfPathnameRelativeToWorkspace(mtc.ws) + " configure "
 
 	for _, pd := range mtc.selection {
 		dependencies := []string{relativeConftabPathname,
@@ -154,8 +324,8 @@ func (mtc *makefileTargetCollector) addConfigureTargets() {
 				mtc.makefileFor(dep))
 		}
 
-		mtc.addTarget(mtc.makefileFor(pd), false,
-			dependencies, cmd+pd.PackageName+"\n")
+		mtc.addTarget(mtc.makefileFor(pd), false, dependencies,
+			"\t@"+envAssignments(pd)+cmd+pd.PackageName+"\n")
 	}
 }
 
@@ -168,13 +338,23 @@ func (mtc *makefileTargetCollector) scriptTemplate(targetName,
 	}
 
 	header := fmt.Sprintf(`	@echo '[%[1]s] %%[1]s'
-	@cd '`+mtc.relBuildDir+`/%%[1]s' && \
+	@cd '%%[2]s' && \
 	echo '--------------------------------' >> make%[2]s.log && \
 	date >> make%[2]s.log && \
 	echo '--------------------------------' >> make%[2]s.log && \
 `, targetName, logFileSuffix)
 
-	cmd := "\t$(MAKE)" + projectTarget
+	cmd := "\t%[4]s$(MAKE)"
+	if mtc.ws.wp.KeepGoing {
+		cmd += " -k"
+	}
+	cmd += projectTarget
+	// The staging directory is passed to the package
+	// makefiles explicitly so that uninstall removes
+	// the files from where install has put them.
+	if targetName == "install" || targetName == "uninstall" {
+		cmd += " DESTDIR=$(DESTDIR)"
+	}
 	if targetName == "check" {
 		cmd += "\n"
 	} else {
@@ -184,6 +364,30 @@ func (mtc *makefileTargetCollector) scriptTemplate(targetName,
 	return header + cmd
 }
 
+// packageScript fills in a script template returned by scriptTemplate
+// with the name, the build directory, the version, and the environment
+// of the package.
+func (mtc *makefileTargetCollector) packageScript(scriptTemplate string,
+	pd *packageDefinition) string {
+	return fmt.Sprintf(scriptTemplate, pd.PackageName,
+		mtc.buildDirFor(pd), pd.params["version"], envAssignments(pd))
+}
+
+// envAssignments returns the shell assignments of the environment
+// variables of the package followed by a space, or an empty string
+// if the package does not define any. The values are quoted for the
+// shell and escaped for make.
+func envAssignments(pd *packageDefinition) string {
+	var assignments string
+
+	for _, variable := range pd.env {
+		assignments += variable.name + "=" + strings.Replace(
+			shellQuote(variable.value), "$", "$$", -1) + " "
+	}
+
+	return assignments
+}
+
 func (mtc *makefileTargetCollector) addBuildTargets() {
 	mtc.addTarget("build", true, mtc.globalTargetDeps, "")
 
@@ -197,7 +401,7 @@ func (mtc *makefileTargetCollector) addBuildTargets() {
 		}
 
 		mtc.addTarget(pd.PackageName, true, dependencies,
-			fmt.Sprintf(scriptTemplate, pd.PackageName))
+			mtc.packageScript(scriptTemplate, pd))
 	}
 }
 
@@ -213,15 +417,16 @@ func (mtc *makefileTargetCollector) addCheckTargets() {
 
 	scriptTemplate := mtc.scriptTemplate("check", "check")
 
+	// Tests of a package run after the package itself is built,
+	// which in turn happens after its selected dependencies are
+	// built. A package without tests still has an (empty) check
+	// target generated by Automake; other failures do not stop
+	// the remaining packages from being checked when make runs
+	// with --keep-going.
 	for _, pd := range mtc.selection {
-		dependencies := []string{mtc.makefileFor(pd)}
-
-		for _, dep := range mtc.selectedDeps[pd] {
-			dependencies = append(dependencies, dep.PackageName)
-		}
-
-		mtc.addTarget("check_"+pd.PackageName, true, dependencies,
-			fmt.Sprintf(scriptTemplate, pd.PackageName))
+		mtc.addTarget("check_"+pd.PackageName, true,
+			[]string{pd.PackageName},
+			mtc.packageScript(scriptTemplate, pd))
 	}
 }
 
@@ -246,7 +451,34 @@ func (mtc *makefileTargetCollector) addInstallTargets() {
 		}
 
 		mtc.addTarget("install_"+pd.PackageName, true, dependencies,
-			fmt.Sprintf(scriptTemplate, pd.PackageName))
+			mtc.packageScript(scriptTemplate, pd))
+	}
+}
+
+func (mtc *makefileTargetCollector) addUninstallTargets() {
+	var selectedPkgNames []string
+
+	for _, pd := range mtc.selection {
+		selectedPkgNames = append(selectedPkgNames,
+			"uninstall_"+pd.PackageName)
+	}
+
+	mtc.addTarget("uninstall", true, selectedPkgNames, "")
+
+	scriptTemplate := mtc.scriptTemplate("uninstall", "uninstall")
+
+	// Packages are uninstalled in the reverse order of
+	// installation: dependent packages go first.
+	for _, pd := range mtc.selection {
+		dependencies := []string{mtc.makefileFor(pd)}
+
+		for _, dep := range mtc.selectedDepnts[pd] {
+			dependencies = append(dependencies,
+				"uninstall_"+dep.PackageName)
+		}
+
+		mtc.addTarget("uninstall_"+pd.PackageName, true, dependencies,
+			mtc.packageScript(scriptTemplate, pd))
 	}
 }
 
@@ -262,7 +494,7 @@ func (mtc *makefileTargetCollector) addDistTargets() {
 
 	scriptTemplate := mtc.scriptTemplate("dist", "dist") +
 		`	@mkdir -p dist
-	@mv '` + mtc.relBuildDir + `/%[1]s/%[1]s-%[2]s.tar.gz' dist/
+	@mv '%[2]s/%[1]s-%[3]s.tar.gz' dist/
 `
 
 	for _, pd := range mtc.selection {
@@ -274,7 +506,205 @@ func (mtc *makefileTargetCollector) addDistTargets() {
 		}
 
 		mtc.addTarget("dist_"+pd.PackageName, true, dependencies,
-			fmt.Sprintf(scriptTemplate, pd.PackageName,
-				pd.params["version"]))
+			mtc.packageScript(scriptTemplate, pd))
+	}
+}
+
+func (mtc *makefileTargetCollector) addCleanTargets() {
+	var selectedPkgNames []string
+
+	for _, pd := range mtc.selection {
+		selectedPkgNames = append(selectedPkgNames,
+			"clean_"+pd.PackageName)
+	}
+
+	mtc.addTarget("clean", true, selectedPkgNames, "")
+
+	scriptTemplate := mtc.scriptTemplate("clean", "clean")
+
+	for _, pd := range mtc.selection {
+		mtc.addTarget("clean_"+pd.PackageName, true,
+			[]string{mtc.makefileFor(pd)},
+			mtc.packageScript(scriptTemplate, pd))
+	}
+}
+
+// addRebuildTarget generates a rule that invokes the top-level build
+// file recursively, first for 'clean' and then for 'build'. Listing
+// both as dependencies would let a parallel build run them out of
+// order.
+func (mtc *makefileTargetCollector) addRebuildTarget() {
+	generator, _, makefile, err := mtc.ws.buildFileSettings()
+	if err != nil {
+		mtc.err = err
+		return
+	}
+
+	cmd := "$(MAKE)"
+	if generator == "ninja" {
+		cmd = "ninja"
+	}
+	cmd += " -f '" + makefile + "' "
+
+	mtc.addTarget("rebuild", true, nil,
+		"\t"+cmd+"clean\n\t"+cmd+"build\n")
+}
+
+// defaultFormatCommand is the formatter that the 'format'
+// target runs unless the workspace settings override it.
+var defaultFormatCommand = "clang-format -i"
+
+// defaultSourcePatterns are the file name patterns of the C and C++
+// sources that the 'format' and 'lint' targets process by default.
+var defaultSourcePatterns = []string{"*.c", "*.cc", "*.cpp", "*.cxx",
+	"*.h", "*.hh", "*.hpp", "*.hxx"}
+
+// findSourcesCommand returns a 'find' command line that runs 'command'
+// on the files in the current directory whose names match one of the
+// patterns. Hidden files and directories are skipped.
+func findSourcesCommand(patterns []string, command string) string {
+	var nameTests []string
+	for _, pattern := range patterns {
+		nameTests = append(nameTests, "-name "+shellQuote(pattern))
+	}
+
+	return "find . -path '*/.*' -prune -o -type f \\( " +
+		strings.Join(nameTests, " -o ") + " \\) -exec " +
+		command + " {} +"
+}
+
+// sourcePatterns returns the file name patterns of the
+// package sources that the 'format' and 'lint' targets process.
+func (mtc *makefileTargetCollector) sourcePatterns() []string {
+	if len(mtc.ws.wp.FormatPatterns) > 0 {
+		return mtc.ws.wp.FormatPatterns
+	}
+	return defaultSourcePatterns
+}
+
+// addFormatTargets generates a rule for each selected package that
+// runs the formatter over the files in the package source directory.
+// Generated build files are never in that directory, so they are
+// left intact.
+func (mtc *makefileTargetCollector) addFormatTargets() {
+	command := mtc.ws.wp.FormatCommand
+	if command == "" {
+		command = defaultFormatCommand
+	}
+
+	findCommand := strings.Replace(findSourcesCommand(
+		mtc.sourcePatterns(), command), "$", "$$", -1)
+
+	var selectedPkgNames []string
+
+	for _, pd := range mtc.selection {
+		selectedPkgNames = append(selectedPkgNames,
+			"format_"+pd.PackageName)
+	}
+
+	mtc.addTarget("format", true, selectedPkgNames, "")
+
+	for _, pd := range mtc.selection {
+		sourceDir := mtc.ws.relativeToWorkspace(
+			filepath.Dir(pd.pathname))
+
+		mtc.addTarget("format_"+pd.PackageName, true, nil,
+			"\t@echo '[format] "+pd.PackageName+"'\n"+
+				"\t@cd "+shellQuote(sourceDir)+" && "+
+				findCommand+"\n")
+	}
+}
+
+// defaultLinter is the command that the 'lint' target runs
+// unless the global section of the conftab sets '--linter'.
+var defaultLinter = "cppcheck --quiet --error-exitcode=1"
+
+// linterCommand returns the linter command line composed of the
+// '--linter' and '--linter-args' options in the global section of
+// the conftab.
+func linterCommand(conftab *Conftab) string {
+	command := conftab.globalOptionValue("linter")
+	if command == "" {
+		command = defaultLinter
+	}
+	if args := conftab.globalOptionValue("linter-args"); args != "" {
+		command += " " + args
+	}
+	return command
+}
+
+// addLintTargets generates a rule for each selected package that
+// runs the linter over the files in the package source directory.
+// A linter failure fails the rule unless the workspace is set to
+// keep going, in which case make reports the failure and ignores it.
+func (mtc *makefileTargetCollector) addLintTargets() {
+	findCommand := strings.Replace(findSourcesCommand(
+		mtc.sourcePatterns(), linterCommand(mtc.conftab)),
+		"$", "$$", -1)
+
+	prefix := "\t@"
+	if mtc.ws.wp.KeepGoing {
+		prefix = "\t-@"
+	}
+
+	var selectedPkgNames []string
+
+	for _, pd := range mtc.selection {
+		selectedPkgNames = append(selectedPkgNames,
+			"lint_"+pd.PackageName)
+	}
+
+	mtc.addTarget("lint", true, selectedPkgNames, "")
+
+	for _, pd := range mtc.selection {
+		sourceDir := mtc.ws.relativeToWorkspace(
+			filepath.Dir(pd.pathname))
+
+		mtc.addTarget("lint_"+pd.PackageName, true, nil,
+			"\t@echo '[lint] "+pd.PackageName+"'\n"+
+				prefix+"cd "+shellQuote(sourceDir)+" && "+
+				findCommand+"\n")
+	}
+}
+
+// addCustomTargets generates a rule for each custom target declared
+// in a package definition as well as a global target for each distinct
+// custom target name. Each line of the custom target script is run
+// as a separate command in the source directory of the package.
+func (mtc *makefileTargetCollector) addCustomTargets() {
+	for _, name := range mtc.customTargetNames() {
+		var pkgTargets []string
+
+		for _, pd := range mtc.selection {
+			for _, ct := range pd.targets {
+				if ct.name == name {
+					pkgTargets = append(pkgTargets,
+						name+"_"+pd.PackageName)
+				}
+			}
+		}
+
+		mtc.addTarget(name, true, pkgTargets, "")
+	}
+
+	for _, pd := range mtc.selection {
+		sourceDir := mtc.ws.relativeToWorkspace(
+			filepath.Dir(pd.pathname))
+
+		for _, ct := range pd.targets {
+			script := "\t@echo '[" + ct.name + "] " +
+				pd.PackageName + "'\n"
+
+			for _, line := range strings.Split(ct.script, "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					script += "\tcd '" + sourceDir + "' && " +
+						strings.Replace(line,
+							"$", "$$", -1) + "\n"
+				}
+			}
+
+			mtc.addTarget(ct.name+"_"+pd.PackageName, true,
+				nil, script)
+		}
 	}
 }
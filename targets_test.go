@@ -0,0 +1,655 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func makeWorkspaceForTesting(workspaceDir string) *workspace {
+	return &workspace{workspaceDir, getPrivateDir(workspaceDir),
+		&workspaceParams{}}
+}
+
+func collectTargetsForTesting(t *testing.T, ws *workspace,
+	selection packageDefinitionList, pi *packageIndex,
+	conftab *Conftab) []target {
+	targets, err := createMakefileTargets(ws, selection, pi, conftab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return targets
+}
+
+func makeTargetsForTesting(t *testing.T, packagesAndDependencies []string,
+	customize func(pi *packageIndex)) map[string]target {
+	pi, err := makePackageIndexForTesting(packagesAndDependencies, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if customize != nil {
+		customize(pi)
+	}
+
+	targetByName := make(map[string]target)
+
+	for _, mt := range collectTargetsForTesting(t,
+		makeWorkspaceForTesting("/ws"), pi.orderedPackages, pi,
+		newConftab()) {
+		if _, dup := targetByName[mt.Target]; dup {
+			t.Error("Duplicate target: " + mt.Target)
+		}
+		targetByName[mt.Target] = mt
+	}
+
+	return targetByName
+}
+
+func checkTargetDependencies(t *testing.T, targetByName map[string]target,
+	targetName, expectedDeps string) {
+	mt, found := targetByName[targetName]
+	if !found {
+		t.Error("Target not found: " + targetName)
+		return
+	}
+
+	deps := strings.Join(mt.Dependencies, ", ")
+	if deps != expectedDeps {
+		t.Error("Unexpected dependencies for " + targetName + ": " +
+			deps + "; expected: " + expectedDeps)
+	}
+}
+
+func TestInvalidGeneratorTargets(t *testing.T) {
+	pi, err := makePackageIndexForTesting([]string{"a"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ws := makeWorkspaceForTesting("/ws")
+	ws.wp.Generator = "scons"
+
+	_, err = createMakefileTargets(ws, pi.orderedPackages, pi,
+		newConftab())
+	if err == nil || !strings.Contains(err.Error(), "scons") {
+		t.Error("Invalid generator was not reported")
+	}
+}
+
+func TestCustomTargets(t *testing.T) {
+	targetByName := makeTargetsForTesting(t, []string{"a", "b:a"},
+		func(pi *packageIndex) {
+			pi.packageByName["a"].targets = []customTarget{
+				{"docs", "doxygen"}}
+			pi.packageByName["b"].targets = []customTarget{
+				{"docs", "doxygen\n\necho $HOME\n"},
+				{"proto-gen", "protoc *.proto"}}
+		})
+
+	checkTargetDependencies(t, targetByName, "docs", "docs_a, docs_b")
+	checkTargetDependencies(t, targetByName, "proto-gen", "proto-gen_b")
+
+	script := targetByName["docs_b"].MakeScript
+	expected := "\t@echo '[docs] b'\n" +
+		"\tcd 'b' && doxygen\n" +
+		"\tcd 'b' && echo $$HOME\n"
+	if script != expected {
+		t.Error("Unexpected script for docs_b: " + script)
+	}
+
+	help := targetByName["help"].MakeScript
+	if !strings.Contains(help, "Custom targets:") ||
+		!strings.Contains(help, "Defined by: a, b") {
+		t.Error("Custom targets are not listed in help: " + help)
+	}
+}
+
+func TestCustomTargetValidation(t *testing.T) {
+	targets, err := getCustomTargets("test.yaml", templateParams{
+		"targets": map[interface{}]interface{}{
+			"proto-gen": "protoc",
+			"docs":      "doxygen"}})
+	if err != nil {
+		t.Error("Unexpected error: " + err.Error())
+	} else if len(targets) != 2 || targets[0].name != "docs" {
+		t.Error("Custom targets are not sorted by name")
+	}
+
+	for _, invalid := range []interface{}{
+		"not a map",
+		map[interface{}]interface{}{"build": "make"},
+		map[interface{}]interface{}{"a b": "make"},
+		map[interface{}]interface{}{"docs": 42}} {
+		_, err := getCustomTargets("test.yaml",
+			templateParams{"targets": invalid})
+		if err == nil {
+			t.Error("Invalid custom target definition accepted")
+		}
+	}
+}
+
+func TestCheckTargetDependencies(t *testing.T) {
+	targetByName := makeTargetsForTesting(t, []string{
+		"a", "b:a", "c:b"}, nil)
+
+	checkTargetDependencies(t, targetByName, "check",
+		"check_a, check_b, check_c")
+
+	checkTargetDependencies(t, targetByName, "check_a", "a")
+	checkTargetDependencies(t, targetByName, "check_b", "b")
+	checkTargetDependencies(t, targetByName, "check_c", "c")
+
+	// Packages are built before they are checked.
+	checkTargetDependencies(t, targetByName, "b",
+		".autoforge/build/b/Makefile, a")
+
+	separator := "\techo '--------------------------------' " +
+		">> make_check.log && \\\n"
+
+	script := targetByName["check_b"].MakeScript
+	expected := "\t@echo '[check] b'\n" +
+		"\t@cd '.autoforge/build/b' && \\\n" +
+		separator +
+		"\tdate >> make_check.log && \\\n" +
+		separator +
+		"\t$(MAKE) check\n"
+	if script != expected {
+		t.Error("Unexpected check script: " + script)
+	}
+}
+
+func TestUninstallTargets(t *testing.T) {
+	targetByName := makeTargetsForTesting(t, []string{
+		"a", "b:a", "c:a", "d:b,c"}, nil)
+
+	checkTargetDependencies(t, targetByName, "uninstall",
+		"uninstall_a, uninstall_b, uninstall_c, uninstall_d")
+
+	checkTargetDependencies(t, targetByName, "uninstall_a",
+		".autoforge/build/a/Makefile, uninstall_b, uninstall_c")
+	checkTargetDependencies(t, targetByName, "uninstall_b",
+		".autoforge/build/b/Makefile, uninstall_d")
+	checkTargetDependencies(t, targetByName, "uninstall_d",
+		".autoforge/build/d/Makefile")
+
+	script := targetByName["uninstall_d"].MakeScript
+	if !strings.Contains(script, "cd '.autoforge/build/d'") ||
+		!strings.Contains(script,
+			"\t$(MAKE) uninstall DESTDIR=$(DESTDIR) "+
+				">> make_uninstall.log\n") {
+		t.Error("Unexpected uninstall script: " + script)
+	}
+}
+
+func TestConfigureInSubdirectory(t *testing.T) {
+	targetByName := makeTargetsForTesting(t, []string{"a", "b:a"},
+		func(pi *packageIndex) {
+			pi.packageByName["b"].configure = "src/configure"
+		})
+
+	checkTargetDependencies(t, targetByName,
+		".autoforge/packages/a/configure",
+		".autoforge/packages/a/configure.ac")
+	checkTargetDependencies(t, targetByName,
+		".autoforge/packages/b/src/configure",
+		".autoforge/packages/b/src/configure.ac")
+
+	checkTargetDependencies(t, targetByName,
+		".autoforge/build/b/Makefile",
+		".autoforge/conftab, .autoforge/packages/b/src/configure, "+
+			".autoforge/build/a/Makefile")
+
+	if _, found := targetByName[".autoforge/packages/b/configure"]; found {
+		t.Error("Bootstrap target uses the default configure location")
+	}
+}
+
+func TestCommandOverride(t *testing.T) {
+	pi, err := makePackageIndexForTesting([]string{"a"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ws := makeWorkspaceForTesting("/ws")
+	ws.wp.Command = "tools/autoforge-wrapper"
+
+	recipesChecked := 0
+
+	for _, mt := range collectTargetsForTesting(t, ws,
+		pi.orderedPackages, pi, newConftab()) {
+		switch mt.Target {
+		case ".autoforge/build/a/Makefile":
+			recipesChecked++
+			if mt.MakeScript !=
+				"\t@tools/autoforge-wrapper configure a\n" {
+				t.Error("Unexpected configure recipe: " +
+					mt.MakeScript)
+			}
+		case ".autoforge/packages/a/configure":
+			recipesChecked++
+			if mt.MakeScript !=
+				"\t@tools/autoforge-wrapper bootstrap a\n" {
+				t.Error("Unexpected bootstrap recipe: " +
+					mt.MakeScript)
+			}
+		}
+	}
+
+	if recipesChecked != 2 {
+		t.Error("Configure or bootstrap target is missing")
+	}
+}
+
+func TestBuildTargets(t *testing.T) {
+	targetByName := makeTargetsForTesting(t, []string{
+		"a", "b:a", "c:a", "d:b"}, nil)
+
+	// Only the packages that no other selected
+	// package depends on are listed explicitly.
+	checkTargetDependencies(t, targetByName, "build", "c, d")
+
+	// Each package is built after its Makefile is generated
+	// by 'configure' and after its dependencies are built.
+	checkTargetDependencies(t, targetByName, "a",
+		".autoforge/build/a/Makefile")
+	checkTargetDependencies(t, targetByName, "d",
+		".autoforge/build/d/Makefile, b")
+
+	// The Makefile of a package is regenerated when the package
+	// is bootstrapped or when the Makefiles of its dependencies
+	// change.
+	checkTargetDependencies(t, targetByName,
+		".autoforge/build/d/Makefile",
+		".autoforge/conftab, .autoforge/packages/d/configure, "+
+			".autoforge/build/b/Makefile")
+	checkTargetDependencies(t, targetByName,
+		".autoforge/packages/d/configure",
+		".autoforge/packages/d/configure.ac")
+
+	if !targetByName["a"].Phony || !targetByName["build"].Phony {
+		t.Error("Build targets must be phony")
+	}
+
+	script := targetByName["a"].MakeScript
+	if !strings.HasPrefix(script, "\t@echo '[build] a'\n"+
+		"\t@cd '.autoforge/build/a' && \\\n") ||
+		!strings.HasSuffix(script, "\t$(MAKE) >> make.log\n") {
+		t.Error("Unexpected build script: " + script)
+	}
+}
+
+func TestDistTargets(t *testing.T) {
+	targetByName := makeTargetsForTesting(t, []string{"a", "b:a"},
+		func(pi *packageIndex) {
+			pi.packageByName["a"].params = templateParams{
+				"version": "1.2.0"}
+			pi.packageByName["b"].params = templateParams{
+				"version": "0.9"}
+		})
+
+	checkTargetDependencies(t, targetByName, "dist", "dist_a, dist_b")
+
+	// Packages must be configured before
+	// their tarballs can be created.
+	checkTargetDependencies(t, targetByName, "dist_a",
+		".autoforge/build/a/Makefile")
+	checkTargetDependencies(t, targetByName, "dist_b",
+		".autoforge/build/b/Makefile, .autoforge/build/a/Makefile")
+
+	script := targetByName["dist_b"].MakeScript
+	if !strings.HasPrefix(script, "\t@echo '[dist] b'\n"+
+		"\t@cd '.autoforge/build/b' && \\\n") {
+		t.Error("Unexpected dist script: " + script)
+	}
+
+	expected := "\t$(MAKE) dist >> make_dist.log\n" +
+		"\t@mkdir -p dist\n" +
+		"\t@mv '.autoforge/build/b/b-0.9.tar.gz' dist/\n"
+	if !strings.HasSuffix(script, expected) {
+		t.Error("Unexpected tarball collection rule: " + script)
+	}
+}
+
+func TestRebuildTarget(t *testing.T) {
+	targetByName := makeTargetsForTesting(t, []string{"a", "b:a"}, nil)
+
+	checkTargetDependencies(t, targetByName, "clean", "clean_a, clean_b")
+	checkTargetDependencies(t, targetByName, "clean_b",
+		".autoforge/build/b/Makefile")
+
+	// Rebuilding must not depend on 'clean' and 'build' directly,
+	// or else they could run in parallel.
+	checkTargetDependencies(t, targetByName, "rebuild", "")
+
+	script := targetByName["rebuild"].MakeScript
+	if script != "\t$(MAKE) -f 'Makefile' clean\n"+
+		"\t$(MAKE) -f 'Makefile' build\n" {
+		t.Error("Unexpected rebuild script: " + script)
+	}
+
+	if !strings.HasSuffix(targetByName["clean_a"].MakeScript,
+		"\t$(MAKE) clean >> make_clean.log\n") {
+		t.Error("Unexpected clean script: " +
+			targetByName["clean_a"].MakeScript)
+	}
+}
+
+func TestDescribeTarget(t *testing.T) {
+	targetByName := makeTargetsForTesting(t, []string{"a", "b:a"},
+		func(pi *packageIndex) {
+			a := pi.packageByName["a"]
+			a.packageType = "library"
+			a.description = "Costs $5, isn't cheap."
+			a.params = templateParams{"version": "1.2.0"}
+			pi.packageByName["b"].packageType = "application"
+		})
+
+	script := targetByName["describe"].MakeScript
+	expected := "\t@echo 'a (library) 1.2.0'\n" +
+		"\t@echo '    Costs $$5, isn'\\''t cheap.'\n" +
+		"\t@echo 'b (application)'\n"
+	if script != expected {
+		t.Error("Unexpected describe script: " + script)
+	}
+
+	if !targetByName["describe"].Phony {
+		t.Error("The describe target must be phony")
+	}
+}
+
+func TestFormatTarget(t *testing.T) {
+	targetByName := makeTargetsForTesting(t, []string{"a", "b:a"}, nil)
+
+	checkTargetDependencies(t, targetByName, "format", "format_a, format_b")
+	checkTargetDependencies(t, targetByName, "format_b", "")
+
+	script := targetByName["format_a"].MakeScript
+	if !strings.HasPrefix(script, "\t@echo '[format] a'\n\t@cd 'a' && ") ||
+		!strings.HasSuffix(script, "-name '*.c' -o -name '*.cc' "+
+			"-o -name '*.cpp' -o -name '*.cxx' -o -name '*.h' "+
+			"-o -name '*.hh' -o -name '*.hpp' -o -name '*.hxx' "+
+			"\\) -exec clang-format -i {} +\n") {
+		t.Error("Unexpected format script: " + script)
+	}
+
+	pi, err := makePackageIndexForTesting([]string{"a"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ws := makeWorkspaceForTesting("/ws")
+	ws.wp.FormatCommand = "astyle --options=$HOME/.astylerc"
+	ws.wp.FormatPatterns = []string{"*.c", "it's.h"}
+
+	for _, mt := range collectTargetsForTesting(t, ws,
+		pi.orderedPackages, pi, newConftab()) {
+		if mt.Target == "format_a" && !strings.HasSuffix(mt.MakeScript,
+			`\( -name '*.c' -o -name 'it'\''s.h' \) `+
+				"-exec astyle --options=$$HOME/.astylerc {} +\n") {
+			t.Error("Unexpected format script: " + mt.MakeScript)
+		}
+	}
+}
+
+func TestFindSourcesCommand(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "format")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, pathname := range []string{"main.c", "README",
+		"lib/util.h", "lib/util.cc", ".git/hooks.c",
+		"lib/.hidden.c", packageDefinitionFilename} {
+		writeFileForTesting(t, path.Join(tempDir, pathname), "")
+	}
+
+	cmd := exec.Command("sh", "-c", findSourcesCommand(
+		[]string{"*.c", "*.cc", "*.h"}, "printf '%s\\n'"))
+	cmd.Dir = tempDir
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := strings.Fields(string(output))
+	sort.Strings(files)
+
+	if result := strings.Join(files, ", "); result !=
+		"./lib/util.cc, ./lib/util.h, ./main.c" {
+		t.Error("Unexpected list of sources: " + result)
+	}
+}
+
+func TestLintTarget(t *testing.T) {
+	pi, err := makePackageIndexForTesting([]string{"a", "b:a"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lintScripts := func(ws *workspace, conftab *Conftab) map[string]string {
+		scripts := make(map[string]string)
+		for _, mt := range collectTargetsForTesting(t, ws,
+			pi.orderedPackages, pi, conftab) {
+			if strings.HasPrefix(mt.Target, "lint") {
+				scripts[mt.Target] = mt.MakeScript
+				if mt.Target == "lint" {
+					scripts[mt.Target] = strings.Join(
+						mt.Dependencies, ", ")
+				}
+			}
+		}
+		return scripts
+	}
+
+	ws := makeWorkspaceForTesting("/ws")
+
+	scripts := lintScripts(ws, newConftab())
+	if scripts["lint"] != "lint_a, lint_b" {
+		t.Error("Unexpected lint dependencies: " + scripts["lint"])
+	}
+	if !strings.HasPrefix(scripts["lint_b"],
+		"\t@echo '[lint] b'\n\t@cd 'b' && find . ") ||
+		!strings.HasSuffix(scripts["lint_b"], " -exec cppcheck "+
+			"--quiet --error-exitcode=1 {} +\n") {
+		t.Error("Unexpected default lint script: " + scripts["lint_b"])
+	}
+
+	conftab := newConftab()
+	conftab.GlobalSection.setOption(optionKey{optOther, "linter"},
+		"--linter=clang-tidy")
+	conftab.GlobalSection.setOption(optionKey{optOther, "linter-args"},
+		"--linter-args=--quiet -p $BUILD_DIR")
+
+	// Global tool options must not be passed to configure.
+	if args := conftab.getConfigureArgs("a"); len(args) != 0 {
+		t.Error("Unexpected configure args: " +
+			strings.Join(args, " "))
+	}
+
+	ws.wp.KeepGoing = true
+
+	scripts = lintScripts(ws, conftab)
+	if !strings.HasPrefix(scripts["lint_a"],
+		"\t@echo '[lint] a'\n\t-@cd 'a' && find . ") ||
+		!strings.HasSuffix(scripts["lint_a"], " -exec clang-tidy "+
+			"--quiet -p $$BUILD_DIR {} +\n") {
+		t.Error("Unexpected custom lint script: " + scripts["lint_a"])
+	}
+}
+
+func TestBuildMode(t *testing.T) {
+	targetByName := makeTargetsForTesting(t, []string{"a", "b:a"},
+		func(pi *packageIndex) {
+			b := pi.packageByName["b"]
+			b.inTreeBuild = true
+			b.params = templateParams{"version": "2.0"}
+		})
+
+	// Out-of-tree package.
+	checkTargetDependencies(t, targetByName, "a",
+		".autoforge/build/a/Makefile")
+	checkTargetDependencies(t, targetByName,
+		".autoforge/build/a/Makefile",
+		".autoforge/conftab, .autoforge/packages/a/configure")
+	if !strings.Contains(targetByName["a"].MakeScript,
+		"@cd '.autoforge/build/a' && ") {
+		t.Error("Unexpected build script: " +
+			targetByName["a"].MakeScript)
+	}
+
+	// In-tree package.
+	checkTargetDependencies(t, targetByName, "b",
+		".autoforge/packages/b/Makefile, a")
+	checkTargetDependencies(t, targetByName,
+		".autoforge/packages/b/Makefile",
+		".autoforge/conftab, .autoforge/packages/b/configure, "+
+			".autoforge/build/a/Makefile")
+	if !strings.Contains(targetByName["b"].MakeScript,
+		"@cd '.autoforge/packages/b' && ") {
+		t.Error("Unexpected build script: " +
+			targetByName["b"].MakeScript)
+	}
+	if !strings.HasSuffix(targetByName["dist_b"].MakeScript,
+		"@mv '.autoforge/packages/b/b-2.0.tar.gz' dist/\n") {
+		t.Error("Unexpected dist script: " +
+			targetByName["dist_b"].MakeScript)
+	}
+
+	for mode, expected := range map[interface{}]bool{
+		nil: false, "out-of-tree": false, "in-tree": true} {
+		inTree, err := getInTreeBuild("a.yaml",
+			templateParams{"build_mode": mode})
+		if err != nil || inTree != expected {
+			t.Error("Unexpected build mode")
+		}
+	}
+	if _, err := getInTreeBuild("a.yaml",
+		templateParams{"build_mode": "in-source"}); err == nil {
+		t.Error("Invalid build mode must be rejected")
+	}
+}
+
+func TestBuildDirOverride(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "builddir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	origWorkspaceDir, origPkgPath, origBuildDir :=
+		flags.workspaceDir, flags.pkgPath, flags.buildDir
+	defer func() {
+		flags.workspaceDir = origWorkspaceDir
+		flags.pkgPath = origPkgPath
+		flags.buildDir = origBuildDir
+	}()
+
+	flags.workspaceDir = path.Join(tempDir, "ws")
+	flags.pkgPath = tempDir
+	flags.buildDir = ""
+
+	if err = initWorkspace(); err != nil {
+		t.Fatal(err)
+	}
+
+	ws, err := loadWorkspace()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flags.buildDir = path.Join(tempDir, "ramdisk")
+	if err = ws.applyBuildDirFlag(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The override is saved for the commands
+	// that the generated makefile invokes.
+	flags.buildDir = ""
+	if ws, err = loadWorkspace(); err != nil {
+		t.Fatal(err)
+	}
+	if ws.buildDir() != path.Join(tempDir, "ramdisk") {
+		t.Error("Unexpected build directory: " + ws.buildDir())
+	}
+
+	pi, err := makePackageIndexForTesting([]string{"a", "b:a"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targetByName := make(map[string]target)
+	for _, mt := range collectTargetsForTesting(t, ws,
+		pi.orderedPackages, pi, newConftab()) {
+		targetByName[mt.Target] = mt
+	}
+
+	checkTargetDependencies(t, targetByName, "../ramdisk/b/Makefile",
+		".autoforge/conftab, .autoforge/packages/b/configure, "+
+			"../ramdisk/a/Makefile")
+	checkTargetDependencies(t, targetByName, "a", "../ramdisk/a/Makefile")
+
+	if script := targetByName["a"].MakeScript; !strings.Contains(script,
+		"\t@cd '../ramdisk/a' && \\\n") {
+		t.Error("Unexpected build script: " + script)
+	}
+}
+
+func TestPackageEnvironment(t *testing.T) {
+	env, err := getEnvironment("a.yaml", templateParams{
+		"env": map[interface{}]interface{}{
+			"PATH":     "/opt/tools/bin:$PATH",
+			"CFLAGS":   "-O0 -g",
+			"LDFLAGS":  "-L'/opt/my libs'",
+			"JOBS":     4,
+			"CXXFLAGS": "-std=c++11"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, invalid := range []interface{}{
+		"CFLAGS=-O0",
+		map[interface{}]interface{}{"1ST": "x"},
+		map[interface{}]interface{}{"MY-VAR": "x"},
+		map[interface{}]interface{}{"CFLAGS": []interface{}{"-O0"}},
+	} {
+		if _, err := getEnvironment("a.yaml",
+			templateParams{"env": invalid}); err == nil {
+			t.Errorf("Invalid environment accepted: %v", invalid)
+		}
+	}
+
+	targetByName := makeTargetsForTesting(t, []string{"a", "b:a"},
+		func(pi *packageIndex) {
+			pi.packageByName["a"].env = env
+		})
+
+	assignments := "CFLAGS='-O0 -g' CXXFLAGS='-std=c++11' JOBS='4' " +
+		`LDFLAGS='-L'\''/opt/my libs'\''' ` +
+		"PATH='/opt/tools/bin:$$PATH' "
+
+	script := targetByName[".autoforge/build/a/Makefile"].MakeScript
+	if !strings.HasPrefix(script, "\t@"+assignments) ||
+		!strings.HasSuffix(script, " configure a\n") {
+		t.Error("Unexpected configure script: " + script)
+	}
+	if script := targetByName["a"].MakeScript; !strings.HasSuffix(
+		script, "\t"+assignments+"$(MAKE) >> make.log\n") {
+		t.Error("Unexpected build script: " + script)
+	}
+
+	// Packages without an environment are not affected.
+	if script := targetByName["b"].MakeScript; !strings.HasSuffix(
+		script, "\t$(MAKE) >> make.log\n") {
+		t.Error("Unexpected build script: " + script)
+	}
+}
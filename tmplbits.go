@@ -11,6 +11,9 @@ var commonDefinitions = map[string]string{
 {{index .snippets .filename}}{{end}}{{end}}`,
 	"Multiline": `{{range .}} \
 	{{.}}{{end}}`,
+	"PkgConfigRequires": `{{if .requires}}Requires:
+{{- range $i, $pkg := .requires}}{{if $i}},{{end}} {{PkgConfigRequirement $pkg}}{{end}}
+{{end}}`,
 }
 
 var commonTemplateFiles = []embeddedTemplateFile{
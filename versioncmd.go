@@ -0,0 +1,58 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// Build metadata, which is meant to be set at link time, e.g.:
+//
+//	go build -ldflags "-X main.version=1.0 -X main.gitCommit=3f2a9c1"
+var (
+	version   string
+	gitCommit string
+	buildDate string
+)
+
+// toolVersion returns the version of the program
+// or "dev" if the version was not set at build time.
+func toolVersion() string {
+	if version == "" {
+		return "dev"
+	}
+	return version
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
+
+func printVersion(w io.Writer) {
+	fmt.Fprintln(w, appName+" version "+toolVersion())
+	fmt.Fprintln(w, "commit: "+valueOrUnknown(gitCommit))
+	fmt.Fprintln(w, "built: "+valueOrUnknown(buildDate))
+}
+
+// versionCmd represents the version command
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version of " + appName,
+	Args:  cobra.MaximumNArgs(0),
+	Run: func(_ *cobra.Command, _ []string) {
+		printVersion(os.Stdout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+}
@@ -0,0 +1,38 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	defer func(origVersion, origGitCommit, origBuildDate string) {
+		version, gitCommit, buildDate =
+			origVersion, origGitCommit, origBuildDate
+	}(version, gitCommit, buildDate)
+
+	checkOutput := func(expected string) {
+		var buffer bytes.Buffer
+		printVersion(&buffer)
+		if buffer.String() != expected {
+			t.Error("Unexpected output: " + buffer.String())
+		}
+	}
+
+	version, gitCommit, buildDate = "", "", ""
+
+	checkOutput(appName + " version dev\ncommit: unknown\n" +
+		"built: unknown\n")
+	runTemplateTest(t, `{{ToolVersion}}`, nil, "dev")
+
+	version, gitCommit, buildDate = "1.4.2", "3f2a9c1", "2018-05-01"
+
+	checkOutput(appName + " version 1.4.2\ncommit: 3f2a9c1\n" +
+		"built: 2018-05-01\n")
+	runTemplateTest(t, `# Generated by `+appName+` {{ToolVersion}}`,
+		nil, "# Generated by "+appName+" 1.4.2")
+}
@@ -6,6 +6,7 @@ package main
 
 import (
 	"io/ioutil"
+	"os"
 	"path"
 	"path/filepath"
 
@@ -13,12 +14,22 @@ import (
 )
 
 type workspaceParams struct {
-	Quiet             bool   `yaml:"quiet"`
-	PkgPath           string `yaml:"pkgpath"`
-	Makefile          string `yaml:"makefile,omitempty"`
-	DefaultMakeTarget string `yaml:"default-target,omitempty"`
-	BuildDir          string `yaml:"builddir,omitempty"`
-	InstallDir        string `yaml:"installdir,omitempty"`
+	Quiet             bool     `yaml:"quiet"`
+	PkgPath           string   `yaml:"pkgpath"`
+	Makefile          string   `yaml:"makefile,omitempty"`
+	Generator         string   `yaml:"generator,omitempty"`
+	DefaultMakeTarget string   `yaml:"default-target,omitempty"`
+	BuildDir          string   `yaml:"builddir,omitempty"`
+	InstallDir        string   `yaml:"installdir,omitempty"`
+	Command           string   `yaml:"command,omitempty"`
+	CopyrightHeader   string   `yaml:"copyright-header,omitempty"`
+	KeepGoing         bool     `yaml:"keep-going,omitempty"`
+	WorkspaceRelative bool     `yaml:"workspace-relative,omitempty"`
+	PackageDefName    string   `yaml:"package-def-name,omitempty"`
+	FormatCommand     string   `yaml:"format-command,omitempty"`
+	FormatPatterns    []string `yaml:"format-patterns,omitempty"`
+	BootstrapCommand  string   `yaml:"bootstrap-command,omitempty"`
+	SourceMode        string   `yaml:"source-mode,omitempty"`
 }
 
 type workspace struct {
@@ -41,6 +52,18 @@ func getPathToSettings(privateDir string) string {
 	return path.Join(privateDir, "settings.yaml")
 }
 
+// writeWorkspaceParams saves the workspace settings
+// in the private directory.
+func writeWorkspaceParams(privateDir string, wp *workspaceParams) error {
+	out, err := yaml.Marshal(wp)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(getPathToSettings(privateDir),
+		out, os.FileMode(0664))
+}
+
 func loadWorkspace() (*workspace, error) {
 	workspaceDir, err := getWorkspaceDir()
 	if err != nil {
@@ -55,9 +78,57 @@ func loadWorkspace() (*workspace, error) {
 	}
 
 	var wp workspaceParams
-	err = yaml.Unmarshal(in, &wp)
+	if err = yaml.Unmarshal(in, &wp); err != nil {
+		return nil, err
+	}
+
+	return &workspace{workspaceDir, privateDir, &wp}, nil
+}
+
+// definitionFilename returns the file name of the package definitions
+// in the package path directories.
+func (wp *workspaceParams) definitionFilename() string {
+	if wp.PackageDefName != "" {
+		return wp.PackageDefName
+	}
+	return packageDefinitionFilename
+}
 
-	return &workspace{workspaceDir, privateDir, &wp}, err
+// applyBuildDirFlag makes the directory given with --builddir
+// the build directory of the workspace and saves the change, so
+// that the commands invoked from the generated makefile use the
+// same directory.
+func (ws *workspace) applyBuildDirFlag() error {
+	buildDir, err := absIfNotEmpty(flags.buildDir)
+	if err != nil || buildDir == "" || buildDir == ws.wp.BuildDir {
+		return err
+	}
+
+	ws.wp.BuildDir = buildDir
+
+	return writeWorkspaceParams(ws.absPrivateDir, ws.wp)
+}
+
+// applySourceModeFlag saves the mode given with --source-mode in the
+// workspace settings. Without the flag, the saved mode is used, so
+// that a later refresh does not turn copied sources back into
+// symbolic links.
+func (ws *workspace) applySourceModeFlag() error {
+	if flags.sourceMode == "" {
+		flags.sourceMode = ws.wp.SourceMode
+		return nil
+	}
+
+	if err := checkSourceMode(flags.sourceMode); err != nil {
+		return err
+	}
+	if flags.sourceMode == ws.wp.SourceMode {
+		return nil
+	}
+
+	ws.wp.SourceMode = flags.sourceMode
+
+	return writeWorkspaceParams(ws.absPrivateDir, ws.wp)
 }
 
 var pkgDirName = "packages"
@@ -87,8 +158,15 @@ func (ws *workspace) installDir() string {
 }
 
 // relativeToWorkspace returns an equivalent of 'absPath'
-// that is relative to the workspace directory.
+// that is relative to the workspace directory. Unless the
+// workspace is configured to be relocatable, the relative
+// pathname is only used if it is shorter.
 func (ws *workspace) relativeToWorkspace(absPath string) string {
+	if ws.wp.WorkspaceRelative {
+		if relPath, err := filepath.Rel(ws.absDir, absPath); err == nil {
+			return relPath
+		}
+	}
 	return relativeIfShorter(ws.absDir, absPath)
 }
 
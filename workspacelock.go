@@ -0,0 +1,84 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path"
+	"syscall"
+)
+
+var lockFilename = "lock"
+
+// lockEnvVar is set for the commands that the process holding the
+// workspace lock runs, so that nested invocations of the tool (e.g.
+// 'configure' called from the makefile) do not try to lock again.
+var lockEnvVar = "AUTOFORGE_WORKSPACE_LOCK"
+
+type workspaceLock struct {
+	file *os.File
+}
+
+func (ws *workspace) lockPathname() string {
+	return path.Join(ws.absPrivateDir, lockFilename)
+}
+
+// lock acquires an exclusive lock on the workspace. It fails
+// immediately if another process holds the lock.
+func (ws *workspace) lock() (*workspaceLock, error) {
+	return ws.flock(syscall.LOCK_EX)
+}
+
+// sharedLock acquires a lock that other processes can share, but
+// that excludes the holders of the exclusive lock. It is used by the
+// commands like 'configure' that the generated makefile can run for
+// several packages in parallel.
+func (ws *workspace) sharedLock() (*workspaceLock, error) {
+	return ws.flock(syscall.LOCK_SH)
+}
+
+func (ws *workspace) flock(how int) (*workspaceLock, error) {
+	lockPathname := ws.lockPathname()
+
+	if os.Getenv(lockEnvVar) == lockPathname {
+		return &workspaceLock{}, nil
+	}
+
+	file, err := os.OpenFile(lockPathname, os.O_CREATE|os.O_RDWR, 0664)
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB)
+	if err != nil {
+		file.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, errors.New("workspace is locked by " +
+				"another " + appName + " process (" +
+				lockPathname + ")")
+		}
+		return nil, errors.New(lockPathname + ": " + err.Error())
+	}
+
+	return &workspaceLock{file}, nil
+}
+
+// lockEnv returns the environment for the commands
+// started while the workspace lock is held.
+func (ws *workspace) lockEnv() []string {
+	return append(os.Environ(), lockEnvVar+"="+ws.lockPathname())
+}
+
+func (wl *workspaceLock) unlock() {
+	if wl.file != nil {
+		syscall.Flock(int(wl.file.Fd()), syscall.LOCK_UN)
+		wl.file.Close()
+		wl.file = nil
+	}
+}
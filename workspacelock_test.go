@@ -0,0 +1,78 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWorkspaceLock(t *testing.T) {
+	workspaceDir, err := ioutil.TempDir("", "wslock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(workspaceDir)
+
+	ws := makeWorkspaceForTesting(workspaceDir)
+	if err = os.MkdirAll(ws.absPrivateDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	wl, err := ws.lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ws.lock(); err == nil {
+		t.Error("Second lock must fail while the first one is held")
+	} else if !strings.Contains(err.Error(), "workspace is locked") {
+		t.Error("Unexpected error: " + err.Error())
+	}
+
+	// Nested invocations inherit the lock.
+	os.Setenv(lockEnvVar, ws.lockPathname())
+	nested, err := ws.lock()
+	os.Unsetenv(lockEnvVar)
+	if err != nil {
+		t.Error("Nested lock failed: " + err.Error())
+	} else {
+		nested.unlock()
+	}
+
+	if _, err = ws.sharedLock(); err == nil {
+		t.Error("Shared lock must fail while the exclusive one is held")
+	}
+
+	wl.unlock()
+
+	// Shared locks do not exclude each other,
+	// only the exclusive one.
+	shared1, err := ws.sharedLock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared2, err := ws.sharedLock()
+	if err != nil {
+		t.Error("Second shared lock failed: " + err.Error())
+	} else {
+		shared2.unlock()
+	}
+	if _, err = ws.lock(); err == nil {
+		t.Error("Exclusive lock must fail while a shared one is held")
+	}
+	shared1.unlock()
+
+	if wl, err = ws.lock(); err != nil {
+		t.Error("Lock is not released: " + err.Error())
+	} else {
+		wl.unlock()
+	}
+}
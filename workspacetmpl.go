@@ -4,20 +4,58 @@
 
 package main
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
 var filenameForSelectedPackages = "selected"
 
 var conftabFilename = "conftab"
 
+var conftabTemplate = embeddedTemplateFile{
+	privateDirName + "/" + conftabFilename, 0644,
+	[]byte(`{{.conftab.GlobalSection.Definition -}}
+{{range .conftab.PackageSections}}[{{.PkgName}}]
+{{.Definition -}}{{end}}`)}
+
 var workspaceTemplate = []embeddedTemplateFile{
 	{privateDirName + "/" + filenameForSelectedPackages, 0644,
 		[]byte(`{{range .selection}}{{.PackageName}}
 {{end}}`)},
-	{privateDirName + "/" + conftabFilename, 0644,
-		[]byte(`{{.conftab.GlobalSection.Definition -}}
-{{range .conftab.PackageSections}}[{{.PkgName}}]
-{{.Definition -}}{{end}}`)},
-	{"{makefile}", 0644,
-		[]byte(`.PHONY: default all
+	conftabTemplate,
+}
+
+// writeConftab serializes the conftab into the private
+// directory of the specified workspace.
+func writeConftab(workspaceDir string, conftab *Conftab) error {
+	_, err := updateConftab(workspaceDir, conftab)
+	return err
+}
+
+// updateConftab is writeConftab that also returns true
+// if the contents of the conftab file have changed.
+func updateConftab(workspaceDir string, conftab *Conftab) (bool, error) {
+	fileParams := expandPathnameTemplate(conftabTemplate.pathname,
+		templateParams{"conftab": conftab})
+
+	outputFiles, err := parseAndExecuteTemplate(conftabTemplate.pathname,
+		conftabTemplate.contents, nil, nil, fileParams)
+	if err != nil {
+		return false, err
+	}
+
+	return writeGeneratedFiles(workspaceDir, outputFiles,
+		conftabTemplate.mode)
+}
+
+var makefileTemplate = embeddedTemplateFile{"{makefile}", 0644,
+	[]byte(`{{if .keep_going}}# Continue with other packages if one of them fails.
+MAKEFLAGS += -k
+
+{{end}}.PHONY: default all
 
 default: {{.default_target}}
 
@@ -28,17 +66,114 @@ all: build
 {{end}}{{.Target}}:{{range .Dependencies}} \
 	{{.}}{{end}}
 {{.MakeScript}}
-{{end}}`)},
+{{end}}`)}
+
+// buildFileTemplate returns the template of the top-level build file
+// for the selected generator along with the default build file name.
+func buildFileTemplate(generator string) (*embeddedTemplateFile,
+	string, error) {
+	switch generator {
+	case "", "make":
+		return &makefileTemplate, "Makefile", nil
+	case "ninja":
+		return &ninjaTemplate, "build.ninja", nil
+	case "cmake":
+		return &cmakeTemplate, "CMakeLists.txt", nil
+	}
+	return nil, "", errors.New("unknown generator '" + generator +
+		"' (must be 'make', 'ninja', or 'cmake')")
 }
 
-func generateWorkspaceFiles(ws *workspace, pi *packageIndex,
-	selection packageDefinitionList, conftab *Conftab) error {
+// buildFileSettings returns the generator name, the template,
+// and the file name of the top-level build file of the workspace.
+func (ws *workspace) buildFileSettings() (string, *embeddedTemplateFile,
+	string, error) {
+	generator := ws.wp.Generator
+	if flags.generator != "" {
+		generator = flags.generator
+	}
+
+	buildFile, defaultMakefile, err := buildFileTemplate(generator)
+	if err != nil {
+		return "", nil, "", err
+	}
 
 	makefile := ws.wp.Makefile
 	if flags.makefile != "" {
 		makefile = flags.makefile
 	} else if makefile == "" {
-		makefile = "Makefile"
+		makefile = defaultMakefile
+	}
+
+	return generator, buildFile, makefile, nil
+}
+
+// checkMakefileRecipes makes sure that all recipe lines in the
+// generated makefile start with a tab character. A recipe line that
+// starts with spaces would otherwise cause a cryptic "missing
+// separator" error when make is run.
+func checkMakefileRecipes(filename string, contents []byte) error {
+	var ruleTarget string
+	continued := false
+
+	for i, line := range strings.Split(string(contents), "\n") {
+		if continued {
+			continued = strings.HasSuffix(line, "\\")
+			continue
+		}
+		continued = strings.HasSuffix(line, "\\")
+
+		switch {
+		case line == "" || line[0] == '\t' || line[0] == '#':
+		case line[0] == ' ':
+			if ruleTarget != "" && strings.TrimSpace(line) != "" {
+				return fmt.Errorf("%s:%d: recipe line for "+
+					"target '%s' starts with spaces "+
+					"instead of a tab:\n%s", filename,
+					i+1, ruleTarget, line)
+			}
+		default:
+			ruleTarget = ""
+			colon := strings.Index(line, ":")
+			if colon > 0 && !strings.ContainsAny(
+				line[:colon], "=") &&
+				!strings.HasPrefix(line[colon:], ":=") {
+				ruleTarget = line[:colon]
+			}
+		}
+	}
+
+	return nil
+}
+
+// workspaceFuncMap returns the functions available
+// to the templates that generate workspace files.
+func workspaceFuncMap(selection packageDefinitionList) template.FuncMap {
+	funcMap := template.FuncMap{
+		"Selection": func() []string {
+			pkgNames := []string{}
+			for _, pd := range selection {
+				pkgNames = append(pkgNames, pd.PackageName)
+			}
+			return pkgNames
+		}}
+
+	for name, function := range ninjaFuncMap {
+		funcMap[name] = function
+	}
+	for name, function := range cmakeFuncMap {
+		funcMap[name] = function
+	}
+
+	return funcMap
+}
+
+func generateWorkspaceFiles(ws *workspace, pi *packageIndex,
+	selection packageDefinitionList, conftab *Conftab) error {
+
+	generator, buildFile, makefile, err := ws.buildFileSettings()
+	if err != nil {
+		return err
 	}
 
 	defaultTarget := ws.wp.DefaultMakeTarget
@@ -48,29 +183,51 @@ func generateWorkspaceFiles(ws *workspace, pi *packageIndex,
 		defaultTarget = "help"
 	}
 
+	targets, err := createMakefileTargets(ws, selection, pi, conftab)
+	if err != nil {
+		return err
+	}
+
 	params := templateParams{
 		"makefile":       makefile,
 		"default_target": defaultTarget,
+		"keep_going":     ws.wp.KeepGoing,
 		"selection":      selection,
 		"conftab":        conftab,
-		"targets":        createMakefileTargets(ws, selection, pi),
+		"targets":        targets,
+	}
+
+	if generator == "cmake" {
+		params["projects"] = cmakeProjects(ws, selection, pi, conftab)
 	}
 
-	for _, templateFile := range workspaceTemplate {
+	funcMap := workspaceFuncMap(selection)
+
+	for _, templateFile := range append(workspaceTemplate, *buildFile) {
 		fileParams := expandPathnameTemplate(templateFile.pathname,
 			params)
 
 		outputFiles, err := parseAndExecuteTemplate(
 			templateFile.pathname, templateFile.contents,
-			nil, nil, fileParams)
+			funcMap, nil, fileParams)
 		if err != nil {
 			return err
 		}
+		if templateFile.pathname == makefileTemplate.pathname &&
+			buildFile == &makefileTemplate {
+			for _, outputFile := range outputFiles {
+				err = checkMakefileRecipes(outputFile.filename,
+					outputFile.contents)
+				if err != nil {
+					return err
+				}
+			}
+		}
 		_, err = writeGeneratedFiles(ws.absDir, outputFiles,
 			templateFile.mode)
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	return updateGitignore(ws.absDir, gitignoreEntries(ws, makefile))
 }
@@ -0,0 +1,251 @@
+// Copyright (C) 2017, 2018 Damon Revoe. All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+)
+
+// executeMakefileTemplateForTesting renders the workspace
+// makefile for all packages of the given package index.
+func executeMakefileTemplateForTesting(t *testing.T, ws *workspace,
+	pi *packageIndex) []byte {
+	result, err := parseAndExecuteTemplate(makefileTemplate.pathname,
+		makefileTemplate.contents, nil, nil,
+		[]outputFileParams{{"Makefile", templateParams{
+			"default_target": "help",
+			"keep_going":     ws.wp.KeepGoing,
+			"targets": collectTargetsForTesting(t, ws,
+				pi.orderedPackages, pi, newConftab())}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return result[0].contents
+}
+
+func TestCheckMakefileRecipes(t *testing.T) {
+	pi, err := makePackageIndexForTesting([]string{"a", "b:a"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	makefile := executeMakefileTemplateForTesting(t,
+		makeWorkspaceForTesting("/ws"), pi)
+
+	if err = checkMakefileRecipes("Makefile", makefile); err != nil {
+		t.Error("Unexpected error: " + err.Error())
+	}
+
+	err = checkMakefileRecipes("Makefile", []byte(`VAR := value
+  INDENTED = ok
+
+all: a \
+  b
+	@echo all
+
+docs:
+	@echo docs
+    doxygen
+`))
+
+	if err == nil {
+		t.Error("Recipe line starting with spaces was not detected")
+	} else if !strings.HasPrefix(err.Error(), "Makefile:10: ") ||
+		!strings.Contains(err.Error(), "target 'docs'") ||
+		!strings.HasSuffix(err.Error(), "\n    doxygen") {
+		t.Error("Unexpected error message: " + err.Error())
+	}
+}
+
+func TestSelectionFunction(t *testing.T) {
+	pi, err := makePackageIndexForTesting([]string{
+		"d:b,c", "c:a", "b:a", "a"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := parseAndExecuteTemplate("index",
+		[]byte(`{{range Selection}}{{.}};{{end}}`),
+		workspaceFuncMap(pi.orderedPackages), nil,
+		[]outputFileParams{{"index", templateParams{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ""
+	for _, pd := range pi.orderedPackages {
+		expected += pd.PackageName + ";"
+	}
+
+	if string(result[0].contents) != expected {
+		t.Error("Unexpected selection: " + string(result[0].contents) +
+			"; expected: " + expected)
+	}
+	if !strings.HasPrefix(expected, "a;") ||
+		!strings.HasSuffix(expected, "d;") {
+		t.Error("Selection is not in index order: " + expected)
+	}
+}
+
+func TestKeepGoing(t *testing.T) {
+	pi, err := makePackageIndexForTesting([]string{"a", "b:a"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ws := makeWorkspaceForTesting("/ws")
+
+	makefile := string(executeMakefileTemplateForTesting(t, ws, pi))
+	if strings.Contains(makefile, "-k") {
+		t.Error("Keep-going mode is enabled by default")
+	}
+
+	ws.wp.KeepGoing = true
+
+	makefile = string(executeMakefileTemplateForTesting(t, ws, pi))
+	if !strings.HasPrefix(makefile, "# Continue with other packages "+
+		"if one of them fails.\nMAKEFLAGS += -k\n") {
+		t.Error("MAKEFLAGS does not include -k")
+	}
+
+	for _, cmd := range []string{
+		"\t$(MAKE) -k >> make.log\n",
+		"\t$(MAKE) -k check\n",
+		"\t$(MAKE) -k install DESTDIR=$(DESTDIR) >> make_install.log\n",
+		"\t$(MAKE) -k dist >> make_dist.log\n"} {
+		if !strings.Contains(makefile, cmd) {
+			t.Error("Sub-make command not found: " + cmd)
+		}
+	}
+}
+
+func TestStableWorkspaceFiles(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	ws := makeWorkspaceForTesting(tempDir)
+
+	// Custom targets come from a YAML map, whose iteration
+	// order is random. Each run builds a new package index.
+	generate := func() map[string][]byte {
+		var packages packageDefinitionList
+		var dependencies [][]string
+
+		for _, name := range []string{"c", "a", "b"} {
+			targets := map[interface{}]interface{}{}
+			for _, target := range []string{
+				"docs", "bench", "coverage", "profile", "deploy"} {
+				targets[target] = "$(MAKE) " + target
+			}
+			pd, requires, err := newPackageDefinition(
+				name+"/"+packageDefinitionFilename,
+				templateParams{"name": name,
+					"description": "Package " + name,
+					"type":        "library",
+					"version":     "1.0.0",
+					"targets":     targets})
+			if err != nil {
+				t.Fatal(err)
+			}
+			packages = append(packages, pd)
+			dependencies = append(dependencies, requires)
+		}
+
+		pi, err := buildPackageIndex(true, packages, dependencies)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = generateWorkspaceFiles(ws, pi, pi.orderedPackages,
+			newConftab()); err != nil {
+			t.Fatal(err)
+		}
+
+		files := make(map[string][]byte)
+		for _, pathname := range []string{"Makefile",
+			path.Join(privateDirName, conftabFilename),
+			path.Join(privateDirName,
+				filenameForSelectedPackages)} {
+			contents, err := ioutil.ReadFile(
+				path.Join(tempDir, pathname))
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[pathname] = contents
+		}
+		return files
+	}
+
+	expected := generate()
+
+	for i := 0; i < 10; i++ {
+		for pathname, contents := range generate() {
+			if !bytes.Equal(contents, expected[pathname]) {
+				t.Fatal(pathname + " changed on regeneration")
+			}
+		}
+	}
+}
+
+func TestWorkspaceRelativeMakefile(t *testing.T) {
+	if _, err := exec.LookPath("make"); err != nil {
+		t.Skip("make is not available")
+	}
+
+	tempDir, err := ioutil.TempDir("", "relative")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	ws := makeWorkspaceForTesting(path.Join(tempDir, "ws"))
+	ws.wp.WorkspaceRelative = true
+
+	pi, err := makePackageIndexForTesting([]string{"a", "b:a"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = generateWorkspaceFiles(ws, pi, pi.orderedPackages,
+		newConftab()); err != nil {
+		t.Fatal(err)
+	}
+
+	makefile, err := ioutil.ReadFile(path.Join(ws.absDir, "Makefile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(makefile, []byte(tempDir)) {
+		t.Error("Makefile contains an absolute pathname:\n" +
+			string(makefile))
+	}
+
+	// Move the workspace to a directory at a different depth.
+	newDir := path.Join(tempDir, "moved", "elsewhere")
+	if err = os.MkdirAll(newDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	newWorkspaceDir := path.Join(newDir, "ws")
+	if err = os.Rename(ws.absDir, newWorkspaceDir); err != nil {
+		t.Fatal(err)
+	}
+
+	makeCmd := exec.Command("make", "-n", "help", "describe")
+	makeCmd.Dir = newWorkspaceDir
+	if out, err := makeCmd.CombinedOutput(); err != nil {
+		t.Error("make failed in the moved workspace: " +
+			err.Error() + "\n" + string(out))
+	}
+}